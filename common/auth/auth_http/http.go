@@ -1,13 +1,18 @@
 package auth_http
 
 import (
+	"context"
 	"encoding/json"
+	"net"
+	"net/url"
+	"strings"
 
 	"github.com/pavlo67/common/common/httplib"
 
 	"github.com/pkg/errors"
 
 	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/health"
 	"github.com/pavlo67/common/common/server/server_http"
 )
 
@@ -63,3 +68,37 @@ func (authOp *authHTTP) Authenticate(toAuth auth.Creds) (*auth.Identity, error)
 
 	return identity, nil
 }
+
+var _ health.HealthChecker = &authHTTP{}
+
+const onHealthCheck = "on authHTTP.HealthCheck()"
+
+// HealthCheck verifies the upstream auth server accepts connections
+func (authOp *authHTTP) HealthCheck(ctx context.Context) error {
+	serverAddress := authOp.serverConfig.Host + authOp.serverConfig.Port
+	if !strings.Contains(serverAddress, "://") {
+		serverAddress = "http://" + serverAddress
+	}
+
+	serverURL, err := url.Parse(serverAddress)
+	if err != nil {
+		return errors.Wrapf(err, onHealthCheck+": wrong server address (%s%s)", authOp.serverConfig.Host, authOp.serverConfig.Port)
+	}
+
+	address := serverURL.Host
+	if serverURL.Port() == "" {
+		if serverURL.Scheme == "https" {
+			address += ":443"
+		} else {
+			address += ":80"
+		}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return errors.Wrapf(err, onHealthCheck+": can't connect to %s", address)
+	}
+
+	return conn.Close()
+}
@@ -1,6 +1,7 @@
 package auth_server_http
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -41,7 +42,7 @@ var authenticateEndpoint = server_http.Endpoint{
 	},
 
 	//BodyParams: bodyParams,
-	WorkerHTTP: func(serverOp server_http.Operator, req *http.Request, _ server_http.PathParams, _ *auth.Identity) (server.Response, error) {
+	WorkerHTTP: func(_ context.Context, serverOp server_http.Operator, req *http.Request, _ server_http.PathParams, _ *auth.Identity) (server.Response, error) {
 
 		credsJSON, err := ioutil.ReadAll(req.Body)
 		if err != nil {
@@ -70,7 +71,7 @@ var setCredsEndpoint = server_http.Endpoint{
 	},
 
 	//BodyParams: bodyParams,
-	WorkerHTTP: func(serverOp server_http.Operator, req *http.Request, _ server_http.PathParams, identity *auth.Identity) (server.Response, error) {
+	WorkerHTTP: func(_ context.Context, serverOp server_http.Operator, req *http.Request, _ server_http.PathParams, identity *auth.Identity) (server.Response, error) {
 
 		credsJSON, err := ioutil.ReadAll(req.Body)
 		if err != nil {
@@ -0,0 +1,83 @@
+package auth_server_http
+
+import (
+	"crypto/x509"
+	"net/http"
+
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/rbac"
+	"github.com/pavlo67/common/common/server/server_http"
+)
+
+// IdentityFromCertificate maps the client certificate to auth.Identity
+type IdentityFromCertificate func(cert *x509.Certificate) (*auth.Identity, error)
+
+// IdentityFromCertificateDefault uses the first URI SAN (or DNS SAN, or email SAN, or subject's common name) as ID,
+// subject's common name as Nickname and subject's organizational units as Roles
+func IdentityFromCertificateDefault(cert *x509.Certificate) (*auth.Identity, error) {
+	if cert == nil {
+		return nil, nil
+	}
+
+	identity := auth.Identity{Nickname: cert.Subject.CommonName}
+
+	switch {
+	case len(cert.URIs) > 0:
+		identity.ID = auth.ID(cert.URIs[0].String())
+	case len(cert.DNSNames) > 0:
+		identity.ID = auth.ID(cert.DNSNames[0])
+	case len(cert.EmailAddresses) > 0:
+		identity.ID = auth.ID(cert.EmailAddresses[0])
+	case cert.Subject.CommonName != "":
+		identity.ID = auth.ID(cert.Subject.CommonName)
+	default:
+		return nil, nil
+	}
+
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		identity.Roles = append(identity.Roles, rbac.Role(ou))
+	}
+
+	return &identity, nil
+}
+
+var _ server_http.OnRequestMiddleware = &onRequestMiddlewareTLS{}
+
+// OnRequestMiddlewareTLS gets the identity from verified client certificate (if any), otherwise it uses next middleware (if it isn't nil)
+func OnRequestMiddlewareTLS(identityFromCertificate IdentityFromCertificate, next server_http.OnRequestMiddleware) server_http.OnRequestMiddleware {
+	if identityFromCertificate == nil {
+		identityFromCertificate = IdentityFromCertificateDefault
+	}
+
+	return &onRequestMiddlewareTLS{
+		identityFromCertificate: identityFromCertificate,
+		next:                    next,
+	}
+}
+
+type onRequestMiddlewareTLS struct {
+	identityFromCertificate IdentityFromCertificate
+	next                    server_http.OnRequestMiddleware
+}
+
+const onIdentityTLS = "on onRequestMiddlewareTLS.Identity()"
+
+func (ormTLS *onRequestMiddlewareTLS) Identity(r *http.Request) (*auth.Identity, error) {
+	// only certificates verified with the server's client CA bundle are accepted
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		identity, err := ormTLS.identityFromCertificate(r.TLS.VerifiedChains[0][0])
+		if err != nil {
+			return nil, errors.CommonError(err, onIdentityTLS)
+		}
+		if identity != nil {
+			return identity, nil
+		}
+	}
+
+	if ormTLS.next != nil {
+		return ormTLS.next.Identity(r)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,167 @@
+package auth_server_http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/rbac"
+	"github.com/pavlo67/common/common/server"
+	"github.com/pavlo67/common/common/server/server_http"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCert{cert: cert, key: key}
+}
+
+func (tc *testCert) write(t *testing.T, certFile, keyFile string) {
+	err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tc.cert.Raw}), 0644)
+	require.NoError(t, err)
+
+	if keyFile != "" {
+		keyDER, err := x509.MarshalECPrivateKey(tc.key)
+		require.NoError(t, err)
+		err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+		require.NoError(t, err)
+	}
+}
+
+func (tc *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{tc.cert.Raw}, PrivateKey: tc.key, Leaf: tc.cert}
+}
+
+func TestOnRequestMiddlewareTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth_server_http_tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil)
+	ca.write(t, filepath.Join(dir, "ca.pem"), "")
+
+	serverTemplate := func() *x509.Certificate {
+		return &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "test server"},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+	}
+	serverCert := newTestCert(t, serverTemplate(), ca)
+	serverCert.write(t, filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"))
+
+	clientURI, err := url.Parse("spiffe://test/client")
+	require.NoError(t, err)
+	clientCert := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "test client", OrganizationalUnit: []string{"admin"}},
+		URIs:        []*url.URL{clientURI},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	cfg := server.Config{
+		KeyPath:         dir,
+		TLSCertFile:     "server.pem",
+		TLSKeyFile:      "server.key",
+		TLSMinVersion:   "1.3",
+		TLSClientCAFile: "ca.pem",
+		TLSClientAuth:   server.TLSClientAuthVerifyIfGiven,
+	}
+	tlsConfig, err := server_http.TLSConfig(cfg, func(err error) { t.Error(err) })
+	require.NoError(t, err)
+
+	middleware := OnRequestMiddlewareTLS(nil, nil)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, err := middleware.Identity(r)
+		require.NoError(t, err)
+		json.NewEncoder(w).Encode(identity)
+	}))
+	srv.Listener = tls.NewListener(srv.Listener, tlsConfig)
+	srv.Start()
+	defer srv.Close()
+	srvURL := "https://" + srv.Listener.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	request := func(clientCerts ...tls.Certificate) (*auth.Identity, *x509.Certificate) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: clientCerts},
+			DisableKeepAlives: true,
+		}}
+		resp, err := client.Get(srvURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var identity *auth.Identity
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&identity))
+
+		return identity, resp.TLS.PeerCertificates[0]
+	}
+
+	identity, peerCert := request(clientCert.tlsCertificate())
+	require.Equal(t, &auth.Identity{ID: "spiffe://test/client", Nickname: "test client", Roles: rbac.Roles{"admin"}}, identity)
+	require.Equal(t, serverCert.cert.SerialNumber, peerCert.SerialNumber)
+
+	identity, _ = request()
+	require.Nil(t, identity)
+
+	// certificate reload
+
+	serverCertNew := newTestCert(t, serverTemplate(), ca)
+	serverCertNew.write(t, filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"))
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "server.pem"), modTime, modTime))
+
+	server_http.TLSReloadCheckInterval = 0
+	defer func() { server_http.TLSReloadCheckInterval = time.Second }()
+
+	_, peerCert = request()
+	require.Equal(t, serverCertNew.cert.SerialNumber, peerCert.SerialNumber)
+}
@@ -28,6 +28,8 @@ type authServerHTTPStarter struct {
 	authJWTKey joiner.InterfaceKey
 
 	interfaceKey joiner.InterfaceKey
+
+	tlsClientCerts bool
 }
 
 // ------------------------------------------------------------------------------------------------
@@ -44,6 +46,7 @@ func (ashs *authServerHTTPStarter) Prepare(_ *config.Config, options common.Map)
 	ashs.authKey = joiner.InterfaceKey(options.StringDefault("auth_key", string(auth.InterfaceKey)))
 	ashs.authJWTKey = joiner.InterfaceKey(options.StringDefault("auth_jwt_key", string(auth_jwt.InterfaceKey)))
 	ashs.interfaceKey = joiner.InterfaceKey(options.StringDefault("interface_key", string(InterfaceKey)))
+	ashs.tlsClientCerts = options.IsTrue("tls_client_certs")
 
 	return nil
 }
@@ -64,6 +67,9 @@ func (ashs *authServerHTTPStarter) Run(joinerOp joiner.Operator) error {
 	if err != nil || middleware == nil {
 		return fmt.Errorf("can't create server_http.OnRequestMiddleware(authJWTOp), got %#v, %s", middleware, err)
 	}
+	if ashs.tlsClientCerts {
+		middleware = OnRequestMiddlewareTLS(nil, middleware)
+	}
 
 	if err := joinerOp.Join(middleware, server_http.OnRequestMiddlewareInterfaceKey); err != nil {
 		return errors.Wrapf(err, "can't join RequestOptions as server_http.onRequestMiddleware with key '%s'", server_http.OnRequestMiddlewareInterfaceKey)
@@ -7,6 +7,7 @@ import (
 	"github.com/pavlo67/common/common"
 	"github.com/pavlo67/common/common/config"
 	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/health"
 	"github.com/pavlo67/common/common/joiner"
 	"github.com/pavlo67/common/common/logger"
 	"github.com/pavlo67/common/common/sqllib/sqllib_pg"
@@ -62,5 +63,10 @@ func (cps *connectPgStarter) Run(joinerOp joiner.Operator) error {
 		return errors.CommonError(err, fmt.Sprintf("can't join *sql.DB with key '%s'", cps.interfaceKey))
 	}
 
+	healthKey := cps.interfaceKey + health.InterfaceKeySuffix
+	if err = joinerOp.Join(health.DB(db), healthKey); err != nil {
+		return errors.CommonError(err, fmt.Sprintf("can't join health.HealthChecker with key '%s'", healthKey))
+	}
+
 	return nil
 }
@@ -1,11 +1,14 @@
 package common
 
-import (
-	"errors"
-)
-
 type ErrorKey string
 
+// KeyError is the sentinel error for the key, errors.Is() matches it with all keyed errors having the same key
+type KeyError ErrorKey
+
+func (ke KeyError) Error() string {
+	return string(ke)
+}
+
 const CantPerformKey ErrorKey = "cant_perform"
 
 const NoCredsKey ErrorKey = "no_creds"
@@ -22,16 +25,16 @@ const WrongJSONKey ErrorKey = "wrong_json"
 
 const NotFoundKey ErrorKey = "not_found"
 
-var ErrNotFound = errors.New(string(NotFoundKey))
+var ErrNotFound error = KeyError(NotFoundKey)
 
 const NullItemKey ErrorKey = "null_item"
 
-var ErrNullItem = errors.New(string(NullItemKey))
+var ErrNullItem error = KeyError(NullItemKey)
 
 const NotImplementedKey ErrorKey = "not_implemented"
 
-var ErrNotImplemented = errors.New(string(NotImplementedKey))
+var ErrNotImplemented error = KeyError(NotImplementedKey)
 
 const NotSupportedKey ErrorKey = "not_supported"
 
-var ErrNotSupported = errors.New(string(NotSupportedKey))
+var ErrNotSupported error = KeyError(NotSupportedKey)
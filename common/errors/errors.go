@@ -19,6 +19,10 @@ type Error interface {
 	Append(interface{}) Error
 }
 
+// CaptureStack turns on the stack capture for errors created with CommonError() (it's expensive, so it's off by default),
+// the stack is printed with %+v
+var CaptureStack bool
+
 func CommonError(any ...interface{}) Error {
 	var err *commonError
 	for _, anything := range any {
@@ -29,6 +33,10 @@ func CommonError(any ...interface{}) Error {
 		return nil
 	}
 
+	if CaptureStack && err.stack == nil {
+		err.stack = callers()
+	}
+
 	return err
 }
 
@@ -40,12 +48,10 @@ func CommonError(any ...interface{}) Error {
 //	}
 //}
 
+// Keyed returns the key of the first Error in err's chain
 func Keyed(err error) Key {
-	if err == nil {
-		return ""
-	}
-
-	if errs, _ := err.(Error); errs != nil {
+	var errs Error
+	if errors.As(err, &errs) && errs != nil {
 		return errs.Key()
 	}
 	return ""
@@ -62,12 +68,10 @@ func Cause(err error) error {
 	return nil
 }
 
+// Data returns the data of the first Error in err's chain
 func Data(err error) common.Map {
-	if err == nil {
-		return nil
-	}
-
-	if errs, _ := err.(Error); errs != nil {
+	var errs Error
+	if errors.As(err, &errs) && errs != nil {
 		return errs.Data()
 	}
 	return nil
@@ -78,9 +82,10 @@ func Data(err error) common.Map {
 var _ Error = &commonError{}
 
 type commonError struct {
-	errs multipleErrors
-	key  Key
-	data common.Map
+	errs  multipleErrors
+	key   Key
+	data  common.Map
+	stack []uintptr
 }
 
 func (ce *commonError) Cause() error {
@@ -88,14 +93,46 @@ func (ce *commonError) Cause() error {
 		if len(ce.errs) > 0 {
 			return ce.errs[0]
 		} else if ce.key != "" {
-			// ???
-			New(string(ce.key))
+			return New(string(ce.key))
 		}
 	}
 
 	return nil
 }
 
+// Unwrap allows errors.Is() and errors.As() to check all appended errors
+func (ce *commonError) Unwrap() []error {
+	if ce == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, err := range ce.errs {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// Is matches the target by key: the target can be the keyed error without any other content (like CommonError(key))
+// or the sentinel common.KeyError (like common.ErrNotFound)
+func (ce *commonError) Is(target error) bool {
+	if ce == nil || ce.key == "" || target == nil {
+		return false
+	}
+
+	switch t := target.(type) {
+	case *commonError:
+		return t != nil && t.key == ce.key && len(t.errs) == 0 && len(t.data) == 0
+	case common.KeyError:
+		return Key(t) == ce.key
+	}
+
+	return false
+}
+
 func (ce *commonError) Error() string {
 	if ce == nil {
 		return ""
@@ -107,6 +144,8 @@ func (ce *commonError) Error() string {
 
 	if len(ce.data) > 0 {
 		errStr += fmt.Sprintf(" (%v) ", ce.data)
+	} else if ce.key != "" && len(ce.errs) > 0 {
+		errStr += " "
 	}
 
 	return errStr + ce.errs.String()
@@ -160,13 +199,15 @@ func (ce *commonError) append(anything interface{}) *commonError {
 		switch v := anything.(type) {
 		case commonError:
 			v1 := v //  to prevent recursion in the case: ke1 := CommonError(...); ke2 := CommonError(ke1, ke1)
+			v1.errs = append(multipleErrors(nil), v.errs...)
 			return &v1
 		case *commonError:
 			v1 := *v // to prevent recursion in the case: ke1 := CommonError(...); ke2 := CommonError(ke1, ke1)
+			v1.errs = append(multipleErrors(nil), v.errs...)
 			return &v1
 		case Error:
 			return &commonError{
-				errs: multipleErrors{errors.New(v.Error())},
+				errs: multipleErrors{v},
 				key:  v.Key(),
 				data: v.Data(),
 			}
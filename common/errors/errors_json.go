@@ -0,0 +1,45 @@
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/pavlo67/common/common"
+)
+
+type errorJSON struct {
+	Key     Key           `json:"key,omitempty"`
+	Data    common.Map    `json:"data,omitempty"`
+	Message string        `json:"message,omitempty"`
+	Causes  []interface{} `json:"causes,omitempty"`
+}
+
+// MarshalJSON keeps the key, the data and the chain of appended errors
+func (ce *commonError) MarshalJSON() ([]byte, error) {
+	if ce == nil {
+		return []byte("null"), nil
+	}
+
+	errJSON := errorJSON{Key: ce.key, Data: ce.data}
+	for _, err := range ce.errs {
+		if err != nil {
+			errJSON.Causes = append(errJSON.Causes, causeJSON(err))
+		}
+	}
+
+	return json.Marshal(errJSON)
+}
+
+// causeJSON represents the error isn't marshalled itself with its message and (if it's wrapped with %w) its cause
+func causeJSON(err error) interface{} {
+	if _, ok := err.(json.Marshaler); ok {
+		return err
+	}
+
+	errJSON := errorJSON{Message: err.Error()}
+	if cause := errors.Unwrap(err); cause != nil {
+		errJSON.Causes = []interface{}{causeJSON(cause)}
+	}
+
+	return errJSON
+}
@@ -0,0 +1,67 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+const stackDepth = 32
+
+// callers skips runtime.Callers(), callers() itself and CommonError()
+func callers() []uintptr {
+	pcs := make([]uintptr, stackDepth)
+	n := runtime.Callers(3, pcs)
+	return pcs[:n]
+}
+
+// StackTrace returns the stack captured on the error creation (if CaptureStack was on)
+func (ce *commonError) StackTrace() []runtime.Frame {
+	if ce == nil || len(ce.stack) < 1 {
+		return nil
+	}
+
+	var stackTrace []runtime.Frame
+	frames := runtime.CallersFrames(ce.stack)
+	for {
+		frame, more := frames.Next()
+		stackTrace = append(stackTrace, frame)
+		if !more {
+			break
+		}
+	}
+
+	return stackTrace
+}
+
+// Format prints the stack trace (and the stack traces of appended errors) with %+v
+func (ce *commonError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('#') {
+			if ce == nil {
+				io.WriteString(s, "(*errors.commonError)(nil)")
+			} else {
+				fmt.Fprintf(s, "&%#v", *ce)
+			}
+			return
+		}
+		io.WriteString(s, ce.Error())
+		if s.Flag('+') && ce != nil {
+			for _, frame := range ce.StackTrace() {
+				fmt.Fprintf(s, "\n%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+			}
+			for _, err := range ce.errs {
+				if errCommon, ok := err.(*commonError); ok && len(errCommon.stack) < 1 {
+					continue
+				} else if _, ok = err.(fmt.Formatter); ok {
+					fmt.Fprintf(s, "\ncaused by: %+v", err)
+				}
+			}
+		}
+	case 's':
+		io.WriteString(s, ce.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", ce.Error())
+	}
+}
@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"testing"
@@ -69,6 +70,58 @@ func TestWrapf(t *testing.T) {
 	log.Print(fmt.Errorf("error calling .Run() for component (%s): %s", "name", err1))
 }
 
+func TestIsAs(t *testing.T) {
+	errNotFound := CommonError(common.NotFoundKey, common.Map{"id": 1})
+	wrapped := fmt.Errorf("on test: %w", CommonError(errNotFound, "can't read"))
+
+	require.True(t, Is(wrapped, common.ErrNotFound))
+	require.True(t, Is(wrapped, CommonError(common.NotFoundKey)))
+	require.False(t, Is(wrapped, common.ErrNullItem))
+	require.False(t, Is(wrapped, CommonError(common.NotFoundKey, "another")))
+	require.False(t, Is(wrapped, fmt.Errorf(string(common.NotFoundKey))))
+	require.False(t, Is(wrapped, errors.New(string(common.NotFoundKey))))
+
+	sentinel := errors.New("sentinel")
+	require.True(t, Is(CommonError(common.CantPerformKey, CommonError(sentinel, "on test")), sentinel))
+
+	var errCommon Error
+	require.True(t, As(wrapped, &errCommon))
+	require.Equal(t, common.NotFoundKey, errCommon.Key())
+	require.Equal(t, common.NotFoundKey, Keyed(wrapped))
+	require.Equal(t, common.Map{"id": 1}, Data(wrapped))
+}
+
+func TestStack(t *testing.T) {
+	CaptureStack = true
+	defer func() { CaptureStack = false }()
+
+	err := CommonError(common.CantPerformKey, "test")
+	require.Equal(t, "cant_perform test", fmt.Sprintf("%v", err))
+
+	withStack := fmt.Sprintf("%+v", err)
+	require.Contains(t, withStack, "cant_perform test\n")
+	require.Contains(t, withStack, "errors.TestStack")
+	require.Contains(t, withStack, "errors_test.go:")
+
+	CaptureStack = false
+	require.Equal(t, "cant_perform test", fmt.Sprintf("%+v", CommonError(common.CantPerformKey, "test")))
+}
+
+func TestMarshalJSON(t *testing.T) {
+	err := CommonError(common.NotFoundKey, common.Map{"id": "1"}, fmt.Errorf("on read: %w", errors.New("no file")), CommonError(common.WrongIDKey))
+
+	errJSON, errMarshal := json.Marshal(err)
+	require.NoError(t, errMarshal)
+	require.JSONEq(t, `{
+		"key": "not_found",
+		"data": {"id": "1"},
+		"causes": [
+			{"message": "on read: no file", "causes": [{"message": "no file"}]},
+			{"key": "wrong_id"}
+		]
+	}`, string(errJSON))
+}
+
 //func TestCommonErrorKey(t *testing.T) {
 //	testKey1 := Key("test_key1")
 //	ke1 := CommonError(testKey1, nil)
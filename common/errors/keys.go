@@ -0,0 +1,46 @@
+package errors
+
+import (
+	"net/http"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/logger"
+)
+
+// common keys are registered here because package common can't import errors
+func init() {
+	for key, description := range map[Key]KeyDescription{
+		common.CantPerformKey: {http.StatusInternalServerError, logger.ErrorLevel, map[string]string{
+			"en": "Can't perform the request", "uk": "Не вдалося виконати запит"}},
+		common.NoCredsKey: {http.StatusUnauthorized, logger.WarnLevel, map[string]string{
+			"en": "No credentials", "uk": "Не надано облікових даних"}},
+		common.InvalidCredsKey: {http.StatusUnauthorized, logger.WarnLevel, map[string]string{
+			"en": "Invalid credentials", "uk": "Неправильні облікові дані"}},
+		common.NoUserKey: {http.StatusForbidden, logger.WarnLevel, map[string]string{
+			"en": "No user", "uk": "Користувача не знайдено"}},
+		common.DuplicateUserKey: {http.StatusConflict, logger.WarnLevel, map[string]string{
+			"en": "The user exists already", "uk": "Такий користувач уже існує"}},
+		common.NoRightsKey: {http.StatusForbidden, logger.WarnLevel, map[string]string{
+			"en": "No rights for the operation", "uk": "Немає прав на цю операцію"}},
+		common.NotUniqueEmailKey: {http.StatusConflict, logger.WarnLevel, map[string]string{
+			"en": "The email is used already", "uk": "Цей email уже використовується"}},
+		common.WrongPathKey: {http.StatusBadRequest, logger.WarnLevel, map[string]string{
+			"en": "Wrong path", "uk": "Неправильний шлях"}},
+		common.WrongBodyKey: {http.StatusBadRequest, logger.WarnLevel, map[string]string{
+			"en": "Wrong request body", "uk": "Неправильне тіло запиту"}},
+		common.WrongIDKey: {http.StatusBadRequest, logger.WarnLevel, map[string]string{
+			"en": "Wrong ID", "uk": "Неправильний ідентифікатор"}},
+		common.WrongJSONKey: {http.StatusBadRequest, logger.WarnLevel, map[string]string{
+			"en": "Wrong JSON", "uk": "Неправильний JSON"}},
+		common.NotFoundKey: {http.StatusNotFound, logger.InfoLevel, map[string]string{
+			"en": "Not found", "uk": "Не знайдено"}},
+		common.NullItemKey: {http.StatusBadRequest, logger.WarnLevel, map[string]string{
+			"en": "No item", "uk": "Немає об'єкта"}},
+		common.NotImplementedKey: {http.StatusNotImplemented, logger.ErrorLevel, map[string]string{
+			"en": "Not implemented", "uk": "Не реалізовано"}},
+		common.NotSupportedKey: {http.StatusBadRequest, logger.WarnLevel, map[string]string{
+			"en": "Not supported", "uk": "Не підтримується"}},
+	} {
+		Register(key, description)
+	}
+}
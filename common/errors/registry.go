@@ -0,0 +1,95 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pavlo67/common/common/logger"
+)
+
+const LanguageDefault = "en"
+
+// KeyDescription is registered for the error key to respond and log the keyed errors in the same way everywhere
+type KeyDescription struct {
+	Status   int               // HTTP status
+	LogLevel logger.Level      // the level keyed errors are logged with
+	Messages map[string]string // human readable messages by language ("en", "uk", ...)
+}
+
+var registry = map[Key]KeyDescription{}
+var registryMutex sync.RWMutex
+
+// Register describes the error key, it's called by packages at init and panics if the key is registered already
+func Register(key Key, description KeyDescription) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if key == "" {
+		panic("on errors.Register(): empty error key")
+	} else if _, ok := registry[key]; ok {
+		panic(fmt.Sprintf("on errors.Register(): error key '%s' is registered already", key))
+	}
+
+	registry[key] = description
+}
+
+func Describe(key Key) (KeyDescription, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	description, ok := registry[key]
+	return description, ok
+}
+
+// RegisteredKeys returns all registered keys sorted
+func RegisteredKeys() []Key {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	keys := make([]Key, 0, len(registry))
+	for key := range registry {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	return keys
+}
+
+// Message returns the message in the first available language ("uk-UA" is replaced with "uk" if it's necessary),
+// LanguageDefault is used if no one is available
+func (kd KeyDescription) Message(languages ...string) string {
+	for _, language := range append(languages[:len(languages):len(languages)], LanguageDefault) {
+		language = strings.ToLower(strings.TrimSpace(language))
+		if message, ok := kd.Messages[language]; ok {
+			return message
+		}
+		if i := strings.IndexAny(language, "-_"); i > 0 {
+			if message, ok := kd.Messages[language[:i]]; ok {
+				return message
+			}
+		}
+	}
+
+	return ""
+}
+
+// Status returns HTTP status registered for the key of err (or http.StatusInternalServerError if there is no one)
+func Status(err error) int {
+	if description, ok := Describe(Keyed(err)); ok && description.Status > 0 {
+		return description.Status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// LogLevel returns the level registered for the key of err (or logger.ErrorLevel if there is no one)
+func LogLevel(err error) logger.Level {
+	if description, ok := Describe(Keyed(err)); ok {
+		return description.LogLevel
+	}
+
+	return logger.ErrorLevel
+}
@@ -0,0 +1,36 @@
+package errors
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/logger"
+)
+
+func TestRegistry(t *testing.T) {
+	const testKey Key = "test_registry"
+
+	Register(testKey, KeyDescription{
+		Status:   http.StatusTeapot,
+		LogLevel: logger.WarnLevel,
+		Messages: map[string]string{"en": "test", "uk": "тест"},
+	})
+	require.Panics(t, func() { Register(testKey, KeyDescription{}) })
+	require.Contains(t, RegisteredKeys(), testKey)
+
+	description, ok := Describe(testKey)
+	require.True(t, ok)
+	require.Equal(t, "тест", description.Message("uk-UA", "en"))
+	require.Equal(t, "test", description.Message("de"))
+	require.Equal(t, "test", description.Message())
+
+	require.Equal(t, http.StatusTeapot, Status(CommonError(testKey, "details")))
+	require.Equal(t, logger.WarnLevel, LogLevel(CommonError(testKey)))
+
+	require.Equal(t, http.StatusNotFound, Status(CommonError(common.NotFoundKey)))
+	require.Equal(t, http.StatusInternalServerError, Status(New("unkeyed")))
+	require.Equal(t, logger.ErrorLevel, LogLevel(New("unkeyed")))
+}
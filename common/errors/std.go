@@ -22,3 +22,17 @@ func Wrapf(err error, format string, args ...interface{}) error {
 func Wrap(err error, msg string) error {
 	return CommonError(err, msg)
 }
+
+// Is, As and Unwrap are the same as in the standard package, they are here to be used without its import
+
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
+
+func As(err error, target interface{}) bool {
+	return errors.As(err, target)
+}
+
+func Unwrap(err error) error {
+	return errors.Unwrap(err)
+}
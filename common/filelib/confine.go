@@ -0,0 +1,169 @@
+package filelib
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/logger"
+)
+
+const PathOutsideRootKey common.ErrorKey = "path_outside_root"
+
+func init() {
+	errors.Register(PathOutsideRootKey, errors.KeyDescription{Status: http.StatusForbidden, LogLevel: logger.WarnLevel, Messages: map[string]string{
+		"en": "The path is outside of the root directory", "uk": "Шлях виходить за межі кореневого каталогу"}})
+}
+
+// reVolume matches Windows drive letters (they are rejected on any OS as Windows-backslashed pathes are accepted)
+var reVolume = regexp.MustCompile(`^[a-zA-Z]:`)
+
+const onCleanPath = "on filelib.CleanPath()"
+
+// CleanPath cleans the relative (user supplied) path ("" is returned for the root itself). It rejects absolute paths,
+// NUL bytes and ".." escapes, so it's enough to confine paths in storages without symlinks.
+func CleanPath(relativePath string) (string, error) {
+	if strings.IndexByte(relativePath, 0) >= 0 {
+		return "", errors.CommonError(PathOutsideRootKey, onCleanPath+": NUL byte in path")
+	}
+
+	// converting Windows-backslashed pathes to the normal ones
+	relativePath = reBackslash.ReplaceAllString(relativePath, "/")
+	if path.IsAbs(relativePath) || filepath.IsAbs(relativePath) || reVolume.MatchString(relativePath) {
+		return "", errors.CommonError(PathOutsideRootKey, common.Map{"path": relativePath}, onCleanPath+": absolute path")
+	}
+
+	cleaned := path.Clean(relativePath)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", errors.CommonError(PathOutsideRootKey, common.Map{"path": relativePath}, onCleanPath+": path escapes the root")
+	} else if cleaned == "." {
+		return "", nil
+	}
+
+	return cleaned, nil
+}
+
+const onConfine = "on filelib.Confine()"
+
+// maxSymlinks limits symlinks followed on resolving one path (like the OS does to detect loops)
+const maxSymlinks = 40
+
+// Confine resolves the relative (user supplied) path inside the root directory. Besides CleanPath() checks it follows
+// symlinks in the path component by component and rejects ones whose targets can't be proven to stay inside the root
+// (dangling ones too: the path itself and its parents may not exist yet, but they are created through the symlinks).
+// The result is root (with trailing "/") + the cleaned path.
+func Confine(root, relativePath string) (string, error) {
+	cleaned, err := CleanPath(relativePath)
+	if err != nil {
+		return "", errors.CommonError(err, onConfine)
+	}
+
+	root = reBackslash.ReplaceAllString(root, "/")
+	if root == "" {
+		return "", errors.New(onConfine + ": empty root")
+	} else if root[len(root)-1] != '/' {
+		root += "/"
+	}
+
+	fullPath := root + cleaned
+
+	rootReal, err := realPath(root)
+	if os.IsNotExist(err) {
+		// nothing exists inside the root, so there is no symlink to escape with
+		return fullPath, nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, onConfine+": can't resolve root '%s'", root)
+	}
+
+	resolved, err := resolve(rootReal, cleaned)
+	if err != nil {
+		return "", errors.Wrapf(err, onConfine+": can't resolve '%s'", fullPath)
+	} else if !inside(rootReal, resolved) {
+		return "", errors.CommonError(PathOutsideRootKey, common.Map{"path": relativePath}, onConfine+": symlink escapes the root")
+	}
+
+	return fullPath, nil
+}
+
+// resolve follows symlinks in the relative path below rootReal (it should be symlink-free) and returns the real path the
+// OS would use for it: the missing rest of the path is appended as is or "" is returned if it can't be proven to stay
+// where it's appended (it's ".." after the missing component or the missing component is outside of rootReal)
+func resolve(rootReal, relativePath string) (string, error) {
+	current := rootReal
+	components := splitPath(relativePath)
+
+	for followed := 0; len(components) > 0; {
+		name := components[0]
+		components = components[1:]
+
+		if name == "" || name == "." {
+			continue
+		} else if name == ".." {
+			// current has no symlinks, so its parent is the real one
+			current = filepath.Dir(current)
+			continue
+		}
+
+		next := filepath.Join(current, name)
+		fi, err := os.Lstat(next)
+		if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+			// nothing to follow here: the rest will be created (or not found) below current
+			if !inside(rootReal, current) {
+				return "", nil
+			}
+			for _, rest := range components {
+				if rest == ".." {
+					return "", nil
+				}
+			}
+			return filepath.Join(append([]string{next}, components...)...), nil
+		} else if err != nil {
+			return "", err
+		} else if fi.Mode()&os.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+
+		if followed++; followed > maxSymlinks {
+			return "", nil
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			volume := filepath.VolumeName(target)
+			current, target = volume+string(filepath.Separator), target[len(volume):]
+		}
+		components = append(splitPath(target), components...)
+	}
+
+	return current, nil
+}
+
+func splitPath(path string) []string {
+	return strings.Split(filepath.ToSlash(path), "/")
+}
+
+func inside(rootReal, path string) bool {
+	if path == "" {
+		return false
+	}
+	rel, err := filepath.Rel(rootReal, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(filepath.ToSlash(rel), "../")
+}
+
+func realPath(path string) (string, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Abs(path)
+}
@@ -0,0 +1,144 @@
+package filelib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common/errors"
+)
+
+// confineCorpus contains paths escaping the root, they are used as the seed corpus for FuzzConfine too
+var confineCorpus = []string{
+	"..",
+	"../",
+	"../secret",
+	"../../etc/passwd",
+	"a/../../secret",
+	"a/b/../../../secret",
+	"./../secret",
+	"a/./../.././secret",
+	`..\secret`,
+	`a\..\..\secret`,
+	"/etc/passwd",
+	"//etc/passwd",
+	`\etc\passwd`,
+	`C:\Windows\win.ini`,
+	"C:/Windows/win.ini",
+	"a\x00b",
+	"\x00",
+	"../\x00",
+	"link_out",
+	"link_out/secret",
+	"link_out/new/file",
+	"a/link_up/secret",
+	"link_dangling_out",
+	"link_dangling_out/new/file",
+	"link_dangling_up",
+	"a/link_dangling_rel",
+	"link_loop",
+}
+
+var confineAllowed = map[string]string{
+	"":             "",
+	".":            "",
+	"a":            "a",
+	"a/":           "a",
+	"./a/b":        "a/b",
+	"a/../b":       "b",
+	"a//b":         "a/b",
+	`a\b`:          "a/b",
+	"a/new/file":   "a/new/file",
+	"..a":          "..a",
+	"a..":          "a..",
+	"a/..b/c":      "a/..b/c",
+	"link_in/file": "link_in/file",
+	"a/b/../../c":  "c",
+
+	"link_dangling_in": "link_dangling_in",
+	"a/file/child":     "a/file/child",
+}
+
+func prepareConfineRoot(t testing.TB) string {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "a"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "outside"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "outside", "secret"), []byte("secret"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a", "file"), []byte("file"), 0644))
+
+	if err := os.Symlink(filepath.Join(dir, "outside"), filepath.Join(root, "link_out")); err != nil {
+		t.Skipf("can't create symlink: %s", err)
+	}
+	require.NoError(t, os.Symlink("../..", filepath.Join(root, "a", "link_up")))
+	require.NoError(t, os.Symlink("a", filepath.Join(root, "link_in")))
+
+	// dangling symlinks: the files would be created through them
+	require.NoError(t, os.Symlink(filepath.Join(dir, "outside", "missing"), filepath.Join(root, "link_dangling_out")))
+	require.NoError(t, os.Symlink("../outside_missing", filepath.Join(root, "link_dangling_up")))
+	require.NoError(t, os.Symlink("missing/../../../outside/secret", filepath.Join(root, "a", "link_dangling_rel")))
+	require.NoError(t, os.Symlink("a/missing", filepath.Join(root, "link_dangling_in")))
+	require.NoError(t, os.Symlink("link_loop", filepath.Join(root, "link_loop")))
+
+	return root
+}
+
+func TestConfine(t *testing.T) {
+	root := prepareConfineRoot(t)
+
+	for _, path := range confineCorpus {
+		fullPath, err := Confine(root, path)
+		require.Errorf(t, err, "%q --> %s", path, fullPath)
+		require.Equalf(t, PathOutsideRootKey, errors.Keyed(err), "%q: %s", path, err)
+	}
+
+	for path, expected := range confineAllowed {
+		fullPath, err := Confine(root, path)
+		require.NoErrorf(t, err, "%q", path)
+		require.Equalf(t, root+"/"+expected, fullPath, "%q", path)
+	}
+
+	// nothing exists in the removed root, so only the path itself is checked
+	require.NoError(t, os.RemoveAll(root))
+	fullPath, err := Confine(root, "a/b")
+	require.NoError(t, err)
+	require.Equal(t, root+"/a/b", fullPath)
+	_, err = Confine(root, "../b")
+	require.Error(t, err)
+}
+
+func FuzzConfine(f *testing.F) {
+	for _, path := range confineCorpus {
+		f.Add(path)
+	}
+	for path := range confineAllowed {
+		f.Add(path)
+	}
+
+	f.Fuzz(func(t *testing.T, path string) {
+		root := prepareConfineRoot(t)
+		rootReal, err := filepath.EvalSymlinks(root)
+		require.NoError(t, err)
+
+		fullPath, err := Confine(root, path)
+		if err != nil {
+			return
+		}
+
+		require.Truef(t, strings.HasPrefix(fullPath, root+"/"), "%q --> %s", path, fullPath)
+		require.NotContains(t, fullPath, "\x00")
+
+		// the nearest existing parent of the confined path must be inside the root
+		existing := fullPath
+		for {
+			if existingReal, err := filepath.EvalSymlinks(existing); err == nil {
+				require.Truef(t, existingReal == rootReal || strings.HasPrefix(existingReal, rootReal+"/"), "%q --> %s --> %s", path, fullPath, existingReal)
+				break
+			}
+			existing = filepath.Dir(existing)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package files
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+
+	"github.com/pavlo67/common/common/filelib"
+)
+
+const mimeHeaderLength = 512
+
+// Digest accumulates SHA-256 and the header (for MIME type detection) of the file data written by Operator implementations
+type Digest struct {
+	hash   hash.Hash
+	header []byte
+}
+
+func NewDigest() *Digest {
+	return &Digest{hash: sha256.New(), header: make([]byte, 0, mimeHeaderLength)}
+}
+
+func (d *Digest) Write(p []byte) (int, error) {
+	if rest := mimeHeaderLength - len(d.header); rest > 0 {
+		if rest > len(p) {
+			rest = len(p)
+		}
+		d.header = append(d.header, p[:rest]...)
+	}
+
+	return d.hash.Write(p)
+}
+
+func (d *Digest) Hash() string {
+	return hex.EncodeToString(d.hash.Sum(nil))
+}
+
+// MIMEType returns meta.MIMEType if it's set or detects it with filelib.MIME() (meta.OriginalName is preferred to filename
+// for the detection by extension)
+func (d *Digest) MIMEType(filename string, meta *Meta) string {
+	if meta != nil {
+		if meta.MIMEType != "" {
+			return meta.MIMEType
+		} else if meta.OriginalName != "" {
+			filename = meta.OriginalName
+		}
+	}
+
+	mimeType, _ := filelib.MIME(filename, d.header)
+	return mimeType
+}
@@ -0,0 +1,378 @@
+package files_dedup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/filelib"
+	"github.com/pavlo67/common/common/files"
+)
+
+// Operator is files.Operator storing each content once (as the blob addressed by its SHA-256) whatever number of paths
+// refer to it
+type Operator interface {
+	files.Operator
+
+	// OpenByHash opens the content by its hash (see files.Item.Hash), the returned item describes the blob in the storage
+	OpenByHash(hash string) (io.ReadCloser, *files.Item, error)
+
+	// GC removes blobs no path refers to and returns their number
+	GC() (int, error)
+}
+
+// the storage layout: blobs/<hash[:2]>/<hash> keep contents, refs/<path> keep hashes (with the files metadata), temp/
+// keeps blobs being written
+const (
+	blobsDir = "blobs"
+	refsDir  = "refs"
+	tempDir  = "temp"
+
+	tagHash = "dedup_hash" // reserved tags of refs, they aren't visible for users
+	tagSize = "dedup_size"
+)
+
+var reHash = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+var _ Operator = &filesDedup{}
+
+type filesDedup struct {
+	storageOp files.Operator
+	counts    map[string]int // references to blobs by their hashes
+
+	mutex sync.Mutex
+}
+
+const onNew = "on filesDedup.New()"
+
+// New creates the deduplicating layer over storageOp (it should be used with the only one layer at the same time:
+// references are counted in memory, they are recounted here)
+func New(storageOp files.Operator) (Operator, files.Cleaner, error) {
+	if storageOp == nil {
+		return nil, nil, errors.New(onNew + ": no files.Operator for storage")
+	}
+
+	filesOp := filesDedup{
+		storageOp: storageOp,
+		counts:    map[string]int{},
+	}
+
+	hashes, err := filesOp.hashes(refsDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, errors.CommonError(err, onNew+": can't count references")
+	}
+	filesOp.reference(hashes, 1)
+
+	return &filesOp, &filesOp, nil
+}
+
+func (filesOp *filesDedup) Save(path, newFilePattern string, data []byte, meta *files.Meta) (string, error) {
+	return files.SaveData(filesOp, path, newFilePattern, data, meta)
+}
+
+func (filesOp *filesDedup) Read(path string) ([]byte, error) {
+	return files.ReadData(filesOp, path)
+}
+
+const onOpen = "on filesDedup.Open()"
+
+func (filesOp *filesDedup) Open(path string) (io.ReadCloser, *files.Item, error) {
+	refPath, err := refPathOf(path)
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onOpen)
+	}
+
+	refItem, err := filesOp.storageOp.Stat(refPath, 0)
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onOpen)
+	} else if refItem.IsDir {
+		return nil, nil, fmt.Errorf(onOpen+": %s is a directory", path)
+	}
+
+	fileItem, err := item(*refItem)
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onOpen)
+	}
+
+	reader, _, err := filesOp.storageOp.Open(blobPath(fileItem.Hash))
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onOpen)
+	}
+
+	return reader, fileItem, nil
+}
+
+const onCreate = "on filesDedup.Create()"
+
+func (filesOp *filesDedup) Create(path, newFilePattern string, meta *files.Meta) (files.Writer, error) {
+	refPath, err := refPathOf(path)
+	if err != nil {
+		return nil, errors.CommonError(err, onCreate)
+	} else if newFilePattern == "" && refPath == refsDir {
+		return nil, errors.CommonError(common.WrongPathKey, common.Map{"path": path}, onCreate+": the root isn't a file")
+	}
+
+	if meta != nil {
+		for _, tag := range []string{tagHash, tagSize} {
+			if _, ok := meta.Tags[tag]; ok {
+				return nil, fmt.Errorf(onCreate+": reserved tag '%s'", tag)
+			}
+		}
+	}
+
+	blobWriter, err := filesOp.storageOp.Create(tempDir, "blob_*", nil)
+	if err != nil {
+		return nil, errors.CommonError(err, onCreate)
+	}
+
+	filename := newFilePattern
+	if filename == "" {
+		filename = refPath
+	}
+
+	return &fileWriter{
+		filesOp:        filesOp,
+		blobWriter:     blobWriter,
+		refPath:        refPath,
+		newFilePattern: newFilePattern,
+		filename:       filename,
+		meta:           meta,
+		digest:         files.NewDigest(),
+	}, nil
+}
+
+const onRemove = "on filesDedup.Remove()"
+
+func (filesOp *filesDedup) Remove(path string) error {
+	refPath, err := refPathOf(path)
+	if err != nil {
+		return errors.CommonError(err, onRemove)
+	} else if refPath == refsDir {
+		return errors.CommonError(common.WrongPathKey, common.Map{"path": path}, onRemove+": the root can't be removed")
+	}
+
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	refItem, err := filesOp.storageOp.Stat(refPath, 0)
+	if err != nil {
+		return errors.CommonError(err, onRemove)
+	}
+
+	if err = filesOp.storageOp.Remove(refPath); err != nil {
+		return errors.CommonError(err, onRemove)
+	}
+	if !refItem.IsDir {
+		filesOp.reference([]string{refItem.Tags[tagHash]}, -1)
+	}
+
+	return nil
+}
+
+const onList = "on filesDedup.List()"
+
+func (filesOp *filesDedup) List(path string, depth int, options *files.ListOptions) (files.Items, string, error) {
+	if err := files.CheckListOptions(options); err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	refPath, err := refPathOf(path)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	// options are applied here: refs have another paths, sizes and hashes than the files have
+	items, err := filesOp.items(refPath, depth)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	items, cursor, err := items.Filter(options).Page(options)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	return items, cursor, nil
+}
+
+const onStat = "on filesDedup.Stat()"
+
+func (filesOp *filesDedup) Stat(path string, depth int) (*files.Item, error) {
+	refPath, err := refPathOf(path)
+	if err != nil {
+		return nil, errors.CommonError(err, onStat)
+	}
+
+	refItem, err := filesOp.storageOp.Stat(refPath, 0)
+	if err != nil {
+		return nil, errors.CommonError(err, onStat)
+	}
+
+	fileItem, err := item(*refItem)
+	if err != nil {
+		return nil, errors.CommonError(err, onStat)
+	}
+
+	if depth != 0 && fileItem.IsDir {
+		items, err := filesOp.items(refPath, depth)
+		if err != nil {
+			return nil, errors.CommonError(err, onStat)
+		}
+		for _, item := range items {
+			if !item.IsDir {
+				fileItem.Size += item.Size
+			}
+		}
+	}
+
+	return fileItem, nil
+}
+
+const onOpenByHash = "on filesDedup.OpenByHash()"
+
+func (filesOp *filesDedup) OpenByHash(hash string) (io.ReadCloser, *files.Item, error) {
+	if !reHash.MatchString(hash) {
+		return nil, nil, errors.CommonError(common.WrongPathKey, common.Map{"hash": hash}, onOpenByHash+": wrong hash")
+	}
+
+	reader, blobItem, err := filesOp.storageOp.Open(blobPath(hash))
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onOpenByHash)
+	}
+
+	return reader, blobItem, nil
+}
+
+const onGC = "on filesDedup.GC()"
+
+// GC doesn't touch temp/ (blobs being written are there)
+func (filesOp *filesDedup) GC() (int, error) {
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	blobItems, _, err := filesOp.storageOp.List(blobsDir, -1, nil)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.CommonError(err, onGC)
+	}
+
+	var removed int
+	for _, blobItem := range blobItems {
+		if blobItem.IsDir || filesOp.counts[path.Base(blobItem.Path)] > 0 {
+			continue
+		}
+		if err = filesOp.storageOp.Remove(blobItem.Path); err != nil {
+			return removed, errors.CommonError(err, onGC)
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// helpers ------------------------------------------------------------------------------------------------------------
+
+// refPathOf returns the storage path of the ref for the path relative to the root (refsDir for the root itself)
+func refPathOf(filePath string) (string, error) {
+	cleaned, err := filelib.CleanPath(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(refsDir, cleaned), nil
+}
+
+func blobPath(hash string) string {
+	return path.Join(blobsDir, hash[:2], hash)
+}
+
+// item converts the ref item to the file one
+func item(refItem files.Item) (*files.Item, error) {
+	fileItem := refItem
+	fileItem.Path = strings.TrimPrefix(refItem.Path, refsDir+"/")
+	if refItem.IsDir {
+		return &fileItem, nil
+	}
+
+	fileItem.Hash = refItem.Tags[tagHash]
+	if !reHash.MatchString(fileItem.Hash) {
+		return nil, fmt.Errorf("wrong hash in ref %s: '%s'", refItem.Path, fileItem.Hash)
+	}
+
+	var err error
+	if fileItem.Size, err = strconv.ParseInt(refItem.Tags[tagSize], 10, 64); err != nil {
+		return nil, fmt.Errorf("wrong size in ref %s: '%s'", refItem.Path, refItem.Tags[tagSize])
+	}
+
+	fileItem.Tags = nil
+	for key, value := range refItem.Tags {
+		if key == tagHash || key == tagSize {
+			continue
+		} else if fileItem.Tags == nil {
+			fileItem.Tags = files.Tags{}
+		}
+		fileItem.Tags[key] = value
+	}
+
+	return &fileItem, nil
+}
+
+// items returns file items below refPath
+func (filesOp *filesDedup) items(refPath string, depth int) (files.Items, error) {
+	refItems, _, err := filesOp.storageOp.List(refPath, depth, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(files.Items, 0, len(refItems))
+	for _, refItem := range refItems {
+		fileItem, err := item(refItem)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *fileItem)
+	}
+
+	return items, nil
+}
+
+// hashes returns the hashes referred by the ref file or by all ref files below the ref directory
+func (filesOp *filesDedup) hashes(refPath string) ([]string, error) {
+	refItem, err := filesOp.storageOp.Stat(refPath, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	refItems := files.Items{*refItem}
+	if refItem.IsDir {
+		if refItems, _, err = filesOp.storageOp.List(refPath, -1, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	var hashes []string
+	for _, refItem := range refItems {
+		if !refItem.IsDir {
+			hashes = append(hashes, refItem.Tags[tagHash])
+		}
+	}
+
+	return hashes, nil
+}
+
+// reference adds delta to the references counts (it should be called under the lock)
+func (filesOp *filesDedup) reference(hashes []string, delta int) {
+	for _, hash := range hashes {
+		if filesOp.counts[hash] += delta; filesOp.counts[hash] <= 0 {
+			delete(filesOp.counts, hash)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package files_dedup
+
+import (
+	"os"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/selectors"
+)
+
+var _ files.Cleaner = &filesDedup{}
+
+const onClean = "on filesDedup.Clean()"
+
+func (filesOp *filesDedup) Clean(term *selectors.Term) error {
+	if _, err := filesOp.CleanItems(term, false); err != nil {
+		return errors.CommonError(err, onClean)
+	}
+
+	return nil
+}
+
+const onCleanItems = "on filesDedup.CleanItems()"
+
+// CleanItems removes refs only (blobs are removed with GC) if the term isn't nil
+func (filesOp *filesDedup) CleanItems(term *selectors.Term, dryRun bool) (files.Items, error) {
+	selected, err := files.Selected(term)
+	if err != nil {
+		return nil, errors.CommonError(err, onCleanItems)
+	}
+
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	var items files.Items
+	allItems, err := filesOp.items(refsDir, -1)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.CommonError(err, onCleanItems)
+	}
+	for _, item := range allItems {
+		if !item.IsDir && selected(item) {
+			items = append(items, item)
+		}
+	}
+
+	if dryRun {
+		return items, nil
+	}
+
+	if term == nil {
+		for _, dir := range []string{refsDir, blobsDir} {
+			if err = filesOp.storageOp.RemoveAll(dir); err != nil && errors.Keyed(err) != common.NotFoundKey {
+				return nil, errors.CommonError(err, onCleanItems)
+			}
+		}
+		filesOp.counts = map[string]int{}
+		return items, nil
+	}
+
+	for _, item := range items {
+		if err = filesOp.storageOp.Remove(refsDir + "/" + item.Path); err != nil {
+			return nil, errors.CommonError(err, onCleanItems)
+		}
+		filesOp.reference([]string{item.Hash}, -1)
+	}
+
+	return items, nil
+}
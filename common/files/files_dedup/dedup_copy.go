@@ -0,0 +1,112 @@
+package files_dedup
+
+import (
+	"os"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+)
+
+const onRemoveAll = "on filesDedup.RemoveAll()"
+
+func (filesOp *filesDedup) RemoveAll(path string) error {
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	refPath, err := existing(path)
+	if err != nil {
+		return errors.CommonError(err, onRemoveAll)
+	}
+
+	hashes, err := filesOp.hashes(refPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.CommonError(common.NotFoundKey, common.Map{"path": path}, onRemoveAll+": no such file or directory")
+	} else if err != nil {
+		return errors.CommonError(err, onRemoveAll)
+	}
+
+	if err = filesOp.storageOp.RemoveAll(refPath); err != nil {
+		return errors.CommonError(err, onRemoveAll)
+	}
+	filesOp.reference(hashes, -1)
+
+	return nil
+}
+
+const onCopy = "on filesDedup.Copy()"
+
+// Copy copies refs only, blobs are shared
+func (filesOp *filesDedup) Copy(from, to string) error {
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	fromPath, toPath, replaced, err := filesOp.fromTo(from, to)
+	if err != nil {
+		return errors.CommonError(err, onCopy)
+	}
+
+	if err = filesOp.storageOp.Copy(fromPath, toPath); err != nil {
+		return errors.CommonError(err, onCopy)
+	}
+
+	copied, err := filesOp.hashes(toPath)
+	if err != nil {
+		return errors.CommonError(err, onCopy)
+	}
+	filesOp.reference(copied, 1)
+	filesOp.reference(replaced, -1)
+
+	return nil
+}
+
+const onMove = "on filesDedup.Move()"
+
+func (filesOp *filesDedup) Move(from, to string) error {
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	fromPath, toPath, replaced, err := filesOp.fromTo(from, to)
+	if err != nil {
+		return errors.CommonError(err, onMove)
+	}
+
+	if err = filesOp.storageOp.Move(fromPath, toPath); err != nil {
+		return errors.CommonError(err, onMove)
+	}
+	filesOp.reference(replaced, -1)
+
+	return nil
+}
+
+// helpers (they should be called under the lock) ---------------------------------------------------------------------
+
+// existing returns the ref path for the path (but not for the root)
+func existing(path string) (string, error) {
+	refPath, err := refPathOf(path)
+	if err != nil {
+		return "", err
+	} else if refPath == refsDir {
+		return "", errors.CommonError(common.WrongPathKey, common.Map{"path": path}, "the root can't be used")
+	}
+
+	return refPath, nil
+}
+
+// fromTo returns ref paths for Copy() and Move() with hashes referred by the target file (it's replaced)
+func (filesOp *filesDedup) fromTo(from, to string) (string, string, []string, error) {
+	fromPath, err := existing(from)
+	if err != nil {
+		return "", "", nil, err
+	}
+	toPath, err := existing(to)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	replaced, err := filesOp.hashes(toPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", "", nil, err
+	}
+
+	return fromPath, toPath, replaced, nil
+}
@@ -0,0 +1,79 @@
+package files_dedup
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/logger/logger_test"
+	"github.com/pavlo67/common/common/starter"
+
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/files/files_mem"
+)
+
+func TestFilesDedup(t *testing.T) {
+	components := []starter.Starter{
+		{Operator: files_mem.Starter(), Options: common.Map{"interface_key": string(files.InterfaceKeyStorage), "cleaner_key": "files_storage_cleaner"}},
+		{Operator: Starter(), Options: common.Map{}},
+	}
+
+	joinerOp, err := starter.Run(components, nil, "CLI BUILD FOR TEST", logger_test.New(t))
+	require.NoError(t, err)
+	require.NotNil(t, joinerOp)
+	defer joinerOp.CloseAll()
+
+	files.FilesTestScenario(t, joinerOp, files.InterfaceKey, files.InterfaceKeyCleaner)
+}
+
+func TestFilesDedupBlobs(t *testing.T) {
+	storageOp, _, err := files_mem.New()
+	require.NoError(t, err)
+
+	filesOp, _, err := New(storageOp)
+	require.NoError(t, err)
+
+	data := []byte("the same attachment")
+	for _, path := range []string{"aaa/1.txt", "bbb/2.txt", "bbb/3.txt"} {
+		_, err = filesOp.Save(path, "", data, &files.Meta{OriginalName: path})
+		require.NoError(t, err)
+	}
+	fi, err := filesOp.Stat("aaa/1.txt", 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), fi.Size)
+
+	blobs, _, err := storageOp.List(blobsDir, -1, &files.ListOptions{Include: []string{fi.Hash}})
+	require.NoError(t, err)
+	require.Len(t, blobs, 1)
+
+	reader, _, err := filesOp.OpenByHash(fi.Hash)
+	require.NoError(t, err)
+	dataByHash, err := ioutil.ReadAll(reader)
+	require.NoError(t, reader.Close())
+	require.NoError(t, err)
+	require.Equal(t, data, dataByHash)
+
+	_, _, err = filesOp.OpenByHash("../" + fi.Hash)
+	require.Equal(t, common.WrongPathKey, errors.Keyed(err))
+
+	// references are recounted by the new layer over the same storage
+	filesOp, _, err = New(storageOp)
+	require.NoError(t, err)
+
+	require.NoError(t, filesOp.Remove("aaa/1.txt"))
+	require.NoError(t, filesOp.Move("bbb/2.txt", "bbb/3.txt"))
+	removed, err := filesOp.GC()
+	require.NoError(t, err)
+	require.Equal(t, 0, removed)
+
+	require.NoError(t, filesOp.RemoveAll("bbb"))
+	removed, err = filesOp.GC()
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+
+	_, _, err = filesOp.OpenByHash(fi.Hash)
+	require.Error(t, err)
+}
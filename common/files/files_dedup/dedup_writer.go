@@ -0,0 +1,112 @@
+package files_dedup
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+)
+
+var _ files.Writer = &fileWriter{}
+
+type fileWriter struct {
+	filesOp        *filesDedup
+	blobWriter     files.Writer
+	refPath        string
+	newFilePattern string
+	filename       string
+	meta           *files.Meta
+	digest         *files.Digest
+	size           int64
+	path           string
+	finished       bool
+}
+
+const onWrite = "on fileWriter.Write()"
+
+func (fw *fileWriter) Write(p []byte) (int, error) {
+	if fw.finished {
+		return 0, fmt.Errorf(onWrite + ": the writer is closed already")
+	}
+
+	n, err := fw.blobWriter.Write(p)
+	fw.digest.Write(p[:n])
+	fw.size += int64(n)
+
+	return n, err
+}
+
+const onClose = "on fileWriter.Close()"
+
+// Close stores the blob (if there is no the same one already) and the ref to it
+func (fw *fileWriter) Close() error {
+	if fw.finished {
+		return fmt.Errorf(onClose + ": the writer is closed already")
+	}
+	fw.finished = true
+
+	if err := fw.blobWriter.Close(); err != nil {
+		return errors.CommonError(err, onClose)
+	}
+	tempPath, hash := fw.blobWriter.Path(), fw.digest.Hash()
+
+	refMeta := files.Meta{
+		MIMEType: fw.digest.MIMEType(fw.filename, fw.meta),
+		Tags:     files.Tags{tagHash: hash, tagSize: strconv.FormatInt(fw.size, 10)},
+	}
+	if fw.meta != nil {
+		refMeta.OriginalName = fw.meta.OriginalName
+		for key, value := range fw.meta.Tags {
+			refMeta.Tags[key] = value
+		}
+	}
+
+	filesOp := fw.filesOp
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	// GC can't remove the blob before the ref is saved: it's locked too
+	if _, err := filesOp.storageOp.Stat(blobPath(hash), 0); err == nil {
+		if err = filesOp.storageOp.Remove(tempPath); err != nil {
+			return errors.CommonError(err, onClose)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		filesOp.storageOp.Remove(tempPath)
+		return errors.CommonError(err, onClose)
+	} else if err = filesOp.storageOp.Move(tempPath, blobPath(hash)); err != nil {
+		filesOp.storageOp.Remove(tempPath)
+		return errors.CommonError(err, onClose)
+	}
+
+	var replaced []string
+	if fw.newFilePattern == "" {
+		var err error
+		if replaced, err = filesOp.hashes(fw.refPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errors.CommonError(err, onClose)
+		}
+	}
+
+	refPath, err := filesOp.storageOp.Save(fw.refPath, fw.newFilePattern, []byte(hash), &refMeta)
+	if err != nil {
+		return errors.CommonError(err, onClose)
+	}
+
+	filesOp.reference([]string{hash}, 1)
+	filesOp.reference(replaced, -1)
+	fw.path = strings.TrimPrefix(refPath, refsDir+"/")
+
+	return nil
+}
+
+func (fw *fileWriter) Abort() error {
+	fw.finished = true
+
+	return fw.blobWriter.Abort()
+}
+
+func (fw *fileWriter) Path() string {
+	return fw.path
+}
@@ -0,0 +1,64 @@
+package files_dedup
+
+import (
+	"fmt"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/config"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/joiner"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/starter"
+)
+
+func Starter() starter.Operator {
+	return &filesDedupStarter{}
+}
+
+var l logger.Operator
+var _ starter.Operator = &filesDedupStarter{}
+
+type filesDedupStarter struct {
+	storageKey   joiner.InterfaceKey
+	interfaceKey joiner.InterfaceKey
+	cleanerKey   joiner.InterfaceKey
+}
+
+func (fds *filesDedupStarter) Name() string {
+	return logger.GetCallInfo().PackageName
+}
+
+func (fds *filesDedupStarter) Prepare(cfg *config.Config, options common.Map) error {
+	fds.storageKey = joiner.InterfaceKey(options.StringDefault("storage_key", string(files.InterfaceKeyStorage)))
+	fds.interfaceKey = joiner.InterfaceKey(options.StringDefault("interface_key", string(files.InterfaceKey)))
+	fds.cleanerKey = joiner.InterfaceKey(options.StringDefault("cleaner_key", string(files.InterfaceKeyCleaner)))
+
+	return nil
+}
+
+func (fds *filesDedupStarter) Run(joinerOp joiner.Operator) error {
+	if l, _ = joinerOp.Interface(logger.InterfaceKey).(logger.Operator); l == nil {
+		return fmt.Errorf("no logger.Operator with key %s", logger.InterfaceKey)
+	}
+
+	storageOp, _ := joinerOp.Interface(fds.storageKey).(files.Operator)
+	if storageOp == nil {
+		return fmt.Errorf("no files.Operator with key %s", fds.storageKey)
+	}
+
+	filesOp, filesCleanerOp, err := New(storageOp)
+	if err != nil {
+		return errors.Wrap(err, "can't init *filesDedup{} as files.Operator")
+	}
+
+	if err = joinerOp.Join(filesOp, fds.interfaceKey); err != nil {
+		return errors.Wrapf(err, "can't join *filesDedup{} as files.Operator with key '%s'", fds.interfaceKey)
+	}
+
+	if err = joinerOp.Join(filesCleanerOp, fds.cleanerKey); err != nil {
+		return errors.Wrapf(err, "can't join *filesDedup{} as db.Cleaner with key '%s'", fds.cleanerKey)
+	}
+
+	return nil
+}
@@ -0,0 +1,328 @@
+package files_encrypted
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/filelib"
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/logger"
+)
+
+// Operator is files.Operator keeping contents and metadata encrypted in the storage
+type Operator interface {
+	files.Operator
+
+	// Rewrap wraps data keys of the file (or of all files below the directory) with the current master key if they were
+	// wrapped with another one, contents aren't re-encrypted (and they aren't rewritten if the storage is files.Tagger),
+	// it returns the number of rewrapped files
+	Rewrap(path string) (int, error)
+}
+
+const DefaultChunkSize = 64 << 10
+
+// TamperedKey is the key of errors returned if the content or the metadata doesn't pass the authentication
+const TamperedKey common.ErrorKey = "tampered"
+
+func init() {
+	errors.Register(TamperedKey, errors.KeyDescription{Status: http.StatusInternalServerError, LogLevel: logger.ErrorLevel, Messages: map[string]string{
+		"en": "The data is damaged or tampered with", "uk": "Дані пошкоджено або підроблено"}})
+}
+
+// the storage layout: data/<path> keep files, temp/ keeps ones being written; each file is the sequence of AES-GCM sealed
+// chunks, its data key (wrapped with the master key) and its metadata (sealed with the data key) are in storage tags
+const (
+	dataDir = "data"
+	tempDir = "temp"
+
+	tagKeyID = "enc_key_id"
+	tagKey   = "enc_key"
+	tagMeta  = "enc_meta"
+
+	storageMIMEType = "application/octet-stream"
+)
+
+// fileMeta is sealed in tagMeta
+type fileMeta struct {
+	OriginalName string     `json:"original_name,omitempty"`
+	MIMEType     string     `json:"mime_type"`
+	Tags         files.Tags `json:"tags,omitempty"`
+	Size         int64      `json:"size"`
+	Hash         string     `json:"hash"`
+	ChunkSize    int        `json:"chunk_size"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+var _ Operator = &filesEncrypted{}
+
+type filesEncrypted struct {
+	storageOp files.Operator
+	keys      *keyring
+	chunkSize int
+
+	mutex sync.RWMutex // Rewrap() locks it to swap keys of each file, other changes are read-locked
+}
+
+const onNew = "on filesEncrypted.New()"
+
+// New creates the encrypting layer over storageOp, the first of masterKeys is current (see Config)
+func New(storageOp files.Operator, masterKeys []MasterKey, chunkSize int) (Operator, files.Cleaner, error) {
+	if storageOp == nil {
+		return nil, nil, errors.New(onNew + ": no files.Operator for storage")
+	} else if chunkSize <= 0 {
+		return nil, nil, fmt.Errorf(onNew+": wrong chunk size (%d)", chunkSize)
+	}
+
+	keys, err := newKeyring(masterKeys)
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onNew)
+	}
+
+	filesOp := filesEncrypted{
+		storageOp: storageOp,
+		keys:      keys,
+		chunkSize: chunkSize,
+	}
+
+	return &filesOp, &filesOp, nil
+}
+
+func (filesOp *filesEncrypted) Save(path, newFilePattern string, data []byte, meta *files.Meta) (string, error) {
+	return files.SaveData(filesOp, path, newFilePattern, data, meta)
+}
+
+// Read returns the error with TamperedKey if the content or the metadata doesn't pass the authentication
+func (filesOp *filesEncrypted) Read(path string) ([]byte, error) {
+	return files.ReadData(filesOp, path)
+}
+
+const onOpen = "on filesEncrypted.Open()"
+
+func (filesOp *filesEncrypted) Open(path string) (io.ReadCloser, *files.Item, error) {
+	dataPath, err := dataPathOf(path)
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onOpen)
+	}
+
+	reader, storageItem, err := filesOp.storageOp.Open(dataPath)
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onOpen)
+	}
+
+	fileItem, meta, aead, err := filesOp.item(*storageItem)
+	if err != nil {
+		reader.Close()
+		return nil, nil, errors.CommonError(err, onOpen)
+	}
+
+	return &fileReader{
+		reader:   reader,
+		buffered: bufio.NewReader(reader),
+		aead:     aead,
+		sealed:   make([]byte, meta.ChunkSize+aead.Overhead()),
+		path:     path,
+	}, fileItem, nil
+}
+
+const onCreate = "on filesEncrypted.Create()"
+
+func (filesOp *filesEncrypted) Create(path, newFilePattern string, meta *files.Meta) (files.Writer, error) {
+	dataPath, err := dataPathOf(path)
+	if err != nil {
+		return nil, errors.CommonError(err, onCreate)
+	} else if newFilePattern == "" && dataPath == dataDir {
+		return nil, errors.CommonError(common.WrongPathKey, common.Map{"path": path}, onCreate+": the root isn't a file")
+	}
+
+	dataKey := make([]byte, masterKeyLength)
+	if _, err = rand.Read(dataKey); err != nil {
+		return nil, errors.CommonError(err, onCreate)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, errors.CommonError(err, onCreate)
+	}
+
+	tempWriter, err := filesOp.storageOp.Create(tempDir, "enc_*", &files.Meta{MIMEType: storageMIMEType})
+	if err != nil {
+		return nil, errors.CommonError(err, onCreate)
+	}
+
+	filename := newFilePattern
+	if filename == "" {
+		filename = dataPath
+	}
+
+	return &fileWriter{
+		filesOp:        filesOp,
+		tempWriter:     tempWriter,
+		dataPath:       dataPath,
+		newFilePattern: newFilePattern,
+		filename:       filename,
+		meta:           meta,
+		dataKey:        dataKey,
+		aead:           aead,
+		digest:         files.NewDigest(),
+	}, nil
+}
+
+const onRemove = "on filesEncrypted.Remove()"
+
+func (filesOp *filesEncrypted) Remove(path string) error {
+	dataPath, err := dataPathOf(path)
+	if err != nil {
+		return errors.CommonError(err, onRemove)
+	} else if dataPath == dataDir {
+		return errors.CommonError(common.WrongPathKey, common.Map{"path": path}, onRemove+": the root can't be removed")
+	}
+
+	filesOp.mutex.RLock()
+	defer filesOp.mutex.RUnlock()
+
+	if err = filesOp.storageOp.Remove(dataPath); err != nil {
+		return errors.CommonError(err, onRemove)
+	}
+
+	return nil
+}
+
+const onList = "on filesEncrypted.List()"
+
+func (filesOp *filesEncrypted) List(path string, depth int, options *files.ListOptions) (files.Items, string, error) {
+	if err := files.CheckListOptions(options); err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	dataPath, err := dataPathOf(path)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	// options are applied here: the storage has another paths and no plain metadata
+	items, err := filesOp.items(dataPath, depth)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	items, cursor, err := items.Filter(options).Page(options)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	return items, cursor, nil
+}
+
+const onStat = "on filesEncrypted.Stat()"
+
+func (filesOp *filesEncrypted) Stat(path string, depth int) (*files.Item, error) {
+	dataPath, err := dataPathOf(path)
+	if err != nil {
+		return nil, errors.CommonError(err, onStat)
+	}
+
+	storageItem, err := filesOp.storageOp.Stat(dataPath, 0)
+	if err != nil {
+		return nil, errors.CommonError(err, onStat)
+	}
+
+	fileItem, _, _, err := filesOp.item(*storageItem)
+	if err != nil {
+		return nil, errors.CommonError(err, onStat)
+	}
+
+	if depth != 0 && fileItem.IsDir {
+		items, err := filesOp.items(dataPath, depth)
+		if err != nil {
+			return nil, errors.CommonError(err, onStat)
+		}
+		for _, item := range items {
+			if !item.IsDir {
+				fileItem.Size += item.Size
+			}
+		}
+	}
+
+	return fileItem, nil
+}
+
+// helpers ------------------------------------------------------------------------------------------------------------
+
+// dataPathOf returns the storage path for the path relative to the root (dataDir for the root itself)
+func dataPathOf(filePath string) (string, error) {
+	cleaned, err := filelib.CleanPath(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dataDir, cleaned), nil
+}
+
+// item converts the storage item to the file one, it returns the file metadata and the cipher for the content too
+func (filesOp *filesEncrypted) item(storageItem files.Item) (*files.Item, *fileMeta, cipher.AEAD, error) {
+	fileItem := storageItem
+	fileItem.Path = strings.TrimPrefix(storageItem.Path, dataDir+"/")
+	if storageItem.IsDir {
+		return &fileItem, nil, nil, nil
+	}
+
+	dataKey, err := filesOp.keys.unwrap(storageItem.Tags[tagKeyID], storageItem.Tags[tagKey])
+	if err != nil {
+		return nil, nil, nil, errors.CommonError(err, common.Map{"path": fileItem.Path})
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(storageItem.Tags[tagMeta])
+	if err != nil {
+		return nil, nil, nil, errors.CommonError(TamperedKey, common.Map{"path": fileItem.Path}, "wrong sealed metadata")
+	}
+	metaJSON, err := aead.Open(nil, metaNonce(), sealed, nil)
+	if err != nil {
+		return nil, nil, nil, errors.CommonError(TamperedKey, common.Map{"path": fileItem.Path}, "can't open sealed metadata")
+	}
+
+	var meta fileMeta
+	if err = json.Unmarshal(metaJSON, &meta); err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "can't json.Unmarshal metadata of %s", fileItem.Path)
+	} else if meta.ChunkSize <= 0 {
+		return nil, nil, nil, fmt.Errorf("wrong chunk size (%d) in metadata of %s", meta.ChunkSize, fileItem.Path)
+	}
+
+	fileItem.Size, fileItem.Hash, fileItem.MIMEType = meta.Size, meta.Hash, meta.MIMEType
+	fileItem.OriginalName, fileItem.Tags, fileItem.CreatedAt = meta.OriginalName, meta.Tags, meta.CreatedAt
+
+	return &fileItem, &meta, aead, nil
+}
+
+// items returns file items below dataPath
+func (filesOp *filesEncrypted) items(dataPath string, depth int) (files.Items, error) {
+	storageItems, _, err := filesOp.storageOp.List(dataPath, depth, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(files.Items, 0, len(storageItems))
+	for _, storageItem := range storageItems {
+		fileItem, _, _, err := filesOp.item(storageItem)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *fileItem)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,64 @@
+package files_encrypted
+
+import (
+	"os"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/selectors"
+)
+
+var _ files.Cleaner = &filesEncrypted{}
+
+const onClean = "on filesEncrypted.Clean()"
+
+func (filesOp *filesEncrypted) Clean(term *selectors.Term) error {
+	if _, err := filesOp.CleanItems(term, false); err != nil {
+		return errors.CommonError(err, onClean)
+	}
+
+	return nil
+}
+
+const onCleanItems = "on filesEncrypted.CleanItems()"
+
+func (filesOp *filesEncrypted) CleanItems(term *selectors.Term, dryRun bool) (files.Items, error) {
+	selected, err := files.Selected(term)
+	if err != nil {
+		return nil, errors.CommonError(err, onCleanItems)
+	}
+
+	filesOp.mutex.RLock()
+	defer filesOp.mutex.RUnlock()
+
+	var items files.Items
+	allItems, err := filesOp.items(dataDir, -1)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.CommonError(err, onCleanItems)
+	}
+	for _, item := range allItems {
+		if !item.IsDir && selected(item) {
+			items = append(items, item)
+		}
+	}
+
+	if dryRun {
+		return items, nil
+	}
+
+	if term == nil {
+		if err = filesOp.storageOp.RemoveAll(dataDir); err != nil && errors.Keyed(err) != common.NotFoundKey {
+			return nil, errors.CommonError(err, onCleanItems)
+		}
+		return items, nil
+	}
+
+	for _, item := range items {
+		if err = filesOp.storageOp.Remove(dataDir + "/" + item.Path); err != nil {
+			return nil, errors.CommonError(err, onCleanItems)
+		}
+	}
+
+	return items, nil
+}
@@ -0,0 +1,192 @@
+package files_encrypted
+
+import (
+	"os"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+)
+
+const onRemoveAll = "on filesEncrypted.RemoveAll()"
+
+func (filesOp *filesEncrypted) RemoveAll(path string) error {
+	dataPath, err := existing(path)
+	if err != nil {
+		return errors.CommonError(err, onRemoveAll)
+	}
+
+	filesOp.mutex.RLock()
+	defer filesOp.mutex.RUnlock()
+
+	if err = filesOp.storageOp.RemoveAll(dataPath); err != nil {
+		return errors.CommonError(err, onRemoveAll)
+	}
+
+	return nil
+}
+
+const onCopy = "on filesEncrypted.Copy()"
+
+// Copy copies sealed contents, so copies share data keys with their originals
+func (filesOp *filesEncrypted) Copy(from, to string) error {
+	fromPath, toPath, err := fromTo(from, to)
+	if err != nil {
+		return errors.CommonError(err, onCopy)
+	}
+
+	filesOp.mutex.RLock()
+	defer filesOp.mutex.RUnlock()
+
+	if err = filesOp.storageOp.Copy(fromPath, toPath); err != nil {
+		return errors.CommonError(err, onCopy)
+	}
+
+	return nil
+}
+
+const onMove = "on filesEncrypted.Move()"
+
+func (filesOp *filesEncrypted) Move(from, to string) error {
+	fromPath, toPath, err := fromTo(from, to)
+	if err != nil {
+		return errors.CommonError(err, onMove)
+	}
+
+	filesOp.mutex.RLock()
+	defer filesOp.mutex.RUnlock()
+
+	if err = filesOp.storageOp.Move(fromPath, toPath); err != nil {
+		return errors.CommonError(err, onMove)
+	}
+
+	return nil
+}
+
+const onRewrap = "on filesEncrypted.Rewrap()"
+
+func (filesOp *filesEncrypted) Rewrap(path string) (int, error) {
+	dataPath, err := dataPathOf(path)
+	if err != nil {
+		return 0, errors.CommonError(err, onRewrap)
+	}
+
+	storageItem, err := filesOp.storageOp.Stat(dataPath, 0)
+	if err != nil {
+		return 0, errors.CommonError(err, onRewrap)
+	}
+
+	storageItems := files.Items{*storageItem}
+	if storageItem.IsDir {
+		if storageItems, _, err = filesOp.storageOp.List(dataPath, -1, nil); err != nil {
+			return 0, errors.CommonError(err, onRewrap)
+		}
+	}
+
+	var rewrapped int
+	for _, storageItem := range storageItems {
+		if storageItem.IsDir || storageItem.Tags[tagKeyID] == filesOp.keys.currentID {
+			continue
+		}
+		if ok, err := filesOp.rewrap(storageItem.Path); err != nil {
+			return rewrapped, errors.CommonError(err, onRewrap)
+		} else if ok {
+			rewrapped++
+		}
+	}
+
+	return rewrapped, nil
+}
+
+// helpers ------------------------------------------------------------------------------------------------------------
+
+// existing returns the storage path for the path (but not for the root)
+func existing(path string) (string, error) {
+	dataPath, err := dataPathOf(path)
+	if err != nil {
+		return "", err
+	} else if dataPath == dataDir {
+		return "", errors.CommonError(common.WrongPathKey, common.Map{"path": path}, "the root can't be used")
+	}
+
+	return dataPath, nil
+}
+
+func fromTo(from, to string) (string, string, error) {
+	fromPath, err := existing(from)
+	if err != nil {
+		return "", "", err
+	}
+	toPath, err := existing(to)
+	if err != nil {
+		return "", "", err
+	}
+
+	return fromPath, toPath, nil
+}
+
+// rewrap wraps the data key of the storage file with the current master key: only storage tags are replaced if the storage
+// is files.Tagger, otherwise the sealed content is copied to the temporary file with new tags to be moved to the storage
+// file. The lock is held for the swap only, the file is checked again under it (it could be changed or removed already).
+func (filesOp *filesEncrypted) rewrap(storagePath string) (bool, error) {
+	storageItem, err := filesOp.storageOp.Stat(storagePath, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	} else if storageItem.Tags[tagKeyID] == filesOp.keys.currentID {
+		return false, nil
+	}
+
+	dataKey, err := filesOp.keys.unwrap(storageItem.Tags[tagKeyID], storageItem.Tags[tagKey])
+	if err != nil {
+		return false, errors.CommonError(err, common.Map{"path": storagePath})
+	}
+	keyID, wrappedKey, err := filesOp.keys.wrap(dataKey)
+	if err != nil {
+		return false, err
+	}
+	tags := files.Tags{tagKeyID: keyID, tagKey: wrappedKey, tagMeta: storageItem.Tags[tagMeta]}
+
+	taggerOp, _ := filesOp.storageOp.(files.Tagger)
+
+	var tempPath string
+	if taggerOp == nil {
+		reader, _, err := filesOp.storageOp.Open(storagePath)
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		tempPath, err = filesOp.rewrite(reader, tempDir, "rewrap_*", &files.Meta{MIMEType: storageMIMEType, Tags: tags})
+		reader.Close()
+		if err != nil {
+			return false, err
+		}
+		defer filesOp.storageOp.Remove(tempPath)
+	}
+
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	// each version of the file has its own data key, so the same wrapped key means the same content
+	storageItemLocked, err := filesOp.storageOp.Stat(storagePath, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	} else if storageItemLocked.Tags[tagKey] != storageItem.Tags[tagKey] || storageItemLocked.Tags[tagMeta] != storageItem.Tags[tagMeta] {
+		return false, nil
+	}
+
+	if taggerOp != nil {
+		err = taggerOp.SetTags(storagePath, tags)
+	} else {
+		err = filesOp.storageOp.Move(tempPath, storagePath)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,66 @@
+package files_encrypted
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"io"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+)
+
+var _ io.ReadCloser = &fileReader{}
+
+// fileReader opens sealed chunks one by one, the error with TamperedKey is returned if any of them (or their order,
+// or the content end) doesn't pass the authentication
+type fileReader struct {
+	reader   io.ReadCloser
+	buffered *bufio.Reader
+	aead     cipher.AEAD
+	sealed   []byte
+	plain    []byte
+	chunks   uint64
+	last     bool
+	path     string
+}
+
+func (fr *fileReader) Read(p []byte) (int, error) {
+	for len(fr.plain) < 1 {
+		if fr.last {
+			return 0, io.EOF
+		} else if err := fr.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, fr.plain)
+	fr.plain = fr.plain[n:]
+
+	return n, nil
+}
+
+func (fr *fileReader) Close() error {
+	return fr.reader.Close()
+}
+
+const onNext = "on fileReader.next()"
+
+func (fr *fileReader) next() error {
+	n, err := io.ReadFull(fr.buffered, fr.sealed)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		fr.last = true
+	} else if err != nil {
+		return errors.CommonError(err, onNext)
+	} else if _, err = fr.buffered.Peek(1); err == io.EOF {
+		fr.last = true
+	} else if err != nil {
+		return errors.CommonError(err, onNext)
+	}
+
+	if fr.plain, err = fr.aead.Open(fr.plain[:0], chunkNonce(fr.chunks, fr.last), fr.sealed[:n], nil); err != nil {
+		return errors.CommonError(TamperedKey, common.Map{"path": fr.path, "chunk": fr.chunks}, onNext+": can't open sealed chunk")
+	}
+	fr.chunks++
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package files_encrypted
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/config"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/logger/logger_test"
+	"github.com/pavlo67/common/common/starter"
+
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/files/files_mem"
+)
+
+func TestFilesEncrypted(t *testing.T) {
+	masterKey, err := NewMasterKey("test")
+	require.NoError(t, err)
+
+	keysJSON, err := json.Marshal([]MasterKey{*masterKey})
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, ioutil.WriteFile(keyFile, keysJSON, 0600))
+
+	cfgFile := filepath.Join(t.TempDir(), "test.yaml")
+	require.NoError(t, ioutil.WriteFile(cfgFile, []byte("files_encrypted:\n  key_file: "+keyFile+"\n"), 0600))
+	cfg, err := config.Get(cfgFile, config.MarshalerYAML)
+	require.NoError(t, err)
+
+	components := []starter.Starter{
+		{Operator: files_mem.Starter(), Options: common.Map{"interface_key": string(files.InterfaceKeyStorage), "cleaner_key": "files_storage_cleaner"}},
+		{Operator: Starter(), Options: common.Map{"chunk_size": 4}},
+	}
+
+	joinerOp, err := starter.Run(components, cfg, "CLI BUILD FOR TEST", logger_test.New(t))
+	require.NoError(t, err)
+	require.NotNil(t, joinerOp)
+	defer joinerOp.CloseAll()
+
+	files.FilesTestScenario(t, joinerOp, files.InterfaceKey, files.InterfaceKeyCleaner)
+}
+
+func TestFilesEncryptedTampering(t *testing.T) {
+	masterKey, err := NewMasterKey("test")
+	require.NoError(t, err)
+
+	storageOp, _, err := files_mem.New()
+	require.NoError(t, err)
+	filesOp, _, err := New(storageOp, []MasterKey{*masterKey}, 4)
+	require.NoError(t, err)
+
+	data := []byte("the contract text")
+	_, err = filesOp.Save("aaa/contract.txt", "", data, &files.Meta{OriginalName: "contract.txt"})
+	require.NoError(t, err)
+
+	sealed, err := storageOp.Read(dataDir + "/aaa/contract.txt")
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(sealed, data[:4]))
+	storageItem, err := storageOp.Stat(dataDir+"/aaa/contract.txt", 0)
+	require.NoError(t, err)
+
+	checkTampered := func(sealedTampered []byte, tags files.Tags) {
+		_, err := storageOp.Save(dataDir+"/aaa/tampered.txt", "", sealedTampered, &files.Meta{Tags: tags})
+		require.NoError(t, err)
+		_, err = filesOp.Read("aaa/tampered.txt")
+		require.Equal(t, TamperedKey, errors.Keyed(err), err)
+	}
+
+	changed := append([]byte(nil), sealed...)
+	changed[len(changed)/2] ^= 1
+	checkTampered(changed, storageItem.Tags)
+
+	chunkSealed := 4 + 16
+	checkTampered(sealed[:len(sealed)-chunkSealed], storageItem.Tags)
+	checkTampered(append(sealed[chunkSealed:chunkSealed*2:chunkSealed*2], sealed[:chunkSealed]...), storageItem.Tags)
+
+	tags := files.Tags{}
+	for key, value := range storageItem.Tags {
+		tags[key] = value
+	}
+	tags[tagMeta] = tags[tagMeta][:len(tags[tagMeta])-4] + "AAA="
+	checkTampered(sealed, tags)
+
+	dataReaded, err := filesOp.Read("aaa/contract.txt")
+	require.NoError(t, err)
+	require.Equal(t, data, dataReaded)
+}
+
+type storageFailing struct {
+	files.Operator
+}
+
+func (sf storageFailing) Create(path, newFilePattern string, meta *files.Meta) (files.Writer, error) {
+	writer, err := sf.Operator.Create(path, newFilePattern, meta)
+	if err != nil {
+		return nil, err
+	}
+	return writerFailing{writer}, nil
+}
+
+type writerFailing struct {
+	files.Writer
+}
+
+func (writerFailing) Write([]byte) (int, error) {
+	return 0, errors.New("test failure")
+}
+
+func TestFilesEncryptedWriteFailed(t *testing.T) {
+	masterKey, err := NewMasterKey("test")
+	require.NoError(t, err)
+
+	storageOp, _, err := files_mem.New()
+	require.NoError(t, err)
+	filesOp, _, err := New(storageFailing{storageOp}, []MasterKey{*masterKey}, 4)
+	require.NoError(t, err)
+
+	writer, err := filesOp.Create("aaa/failed.txt", "", nil)
+	require.NoError(t, err)
+
+	n, err := writer.Write([]byte("the contract text"))
+	require.Error(t, err)
+	require.Equal(t, 0, n)
+
+	// the failed writer isn't continued
+	_, errWrite := writer.Write([]byte("more"))
+	require.Equal(t, err, errWrite)
+	require.Equal(t, err, writer.Close())
+
+	_, err = filesOp.Stat("aaa/failed.txt", 0)
+	require.Error(t, err)
+}
+
+func TestFilesEncryptedRewrap(t *testing.T) {
+	masterKey1, err := NewMasterKey("key1")
+	require.NoError(t, err)
+	masterKey2, err := NewMasterKey("key2")
+	require.NoError(t, err)
+
+	storageMem, _, err := files_mem.New()
+	require.NoError(t, err)
+	storageMemNoTagger, _, err := files_mem.New()
+	require.NoError(t, err)
+
+	// the storage without files.Tagger gets rewritten contents
+	for _, storageOp := range []files.Operator{storageMem, struct{ files.Operator }{storageMemNoTagger}} {
+		filesOp, _, err := New(storageOp, []MasterKey{*masterKey1}, DefaultChunkSize)
+		require.NoError(t, err)
+
+		data := []byte("the scan")
+		for _, path := range []string{"aaa/1.txt", "aaa/bbb/2.txt"} {
+			_, err = filesOp.Save(path, "", data, nil)
+			require.NoError(t, err)
+		}
+		sealed, err := storageOp.Read(dataDir + "/aaa/bbb/2.txt")
+		require.NoError(t, err)
+
+		filesOp, _, err = New(storageOp, []MasterKey{*masterKey2, *masterKey1}, DefaultChunkSize)
+		require.NoError(t, err)
+		rewrapped, err := filesOp.Rewrap("aaa/bbb")
+		require.NoError(t, err)
+		require.Equal(t, 1, rewrapped)
+		rewrapped, err = filesOp.Rewrap("")
+		require.NoError(t, err)
+		require.Equal(t, 1, rewrapped)
+		rewrapped, err = filesOp.Rewrap("")
+		require.NoError(t, err)
+		require.Equal(t, 0, rewrapped)
+
+		// the content isn't re-encrypted
+		sealedRewrapped, err := storageOp.Read(dataDir + "/aaa/bbb/2.txt")
+		require.NoError(t, err)
+		require.Equal(t, sealed, sealedRewrapped)
+
+		tempItems, _, err := storageOp.List(tempDir, -1, nil)
+		require.NoError(t, err)
+		require.Empty(t, tempItems)
+
+		filesOp, _, err = New(storageOp, []MasterKey{*masterKey2}, DefaultChunkSize)
+		require.NoError(t, err)
+		for _, path := range []string{"aaa/1.txt", "aaa/bbb/2.txt"} {
+			dataReaded, err := filesOp.Read(path)
+			require.NoError(t, err)
+			require.Equal(t, data, dataReaded)
+		}
+	}
+}
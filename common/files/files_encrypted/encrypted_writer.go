@@ -0,0 +1,170 @@
+package files_encrypted
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+)
+
+var _ files.Writer = &fileWriter{}
+
+type fileWriter struct {
+	filesOp        *filesEncrypted
+	tempWriter     files.Writer
+	dataPath       string
+	newFilePattern string
+	filename       string
+	meta           *files.Meta
+	dataKey        []byte
+	aead           cipher.AEAD
+	digest         *files.Digest
+	buffer         []byte
+	chunks         uint64
+	size           int64
+	path           string
+	finished       bool
+	err            error // the writer is failed: the partially sealed content can't be completed
+}
+
+const onWrite = "on fileWriter.Write()"
+
+// Write seals each full chunk (but the last one is sealed on Close), after the failure the same error is returned by
+// all the next calls of Write and Close
+func (fw *fileWriter) Write(p []byte) (int, error) {
+	if fw.finished {
+		return 0, fmt.Errorf(onWrite + ": the writer is closed already")
+	} else if fw.err != nil {
+		return 0, fw.err
+	}
+
+	fw.digest.Write(p)
+	fw.size += int64(len(p))
+	fw.buffer = append(fw.buffer, p...)
+
+	chunkSize := fw.filesOp.chunkSize
+	for len(fw.buffer) > chunkSize {
+		if err := fw.seal(fw.buffer[:chunkSize], false); err != nil {
+			fw.err = errors.CommonError(err, onWrite)
+			return 0, fw.err
+		}
+		fw.buffer = append(fw.buffer[:0], fw.buffer[chunkSize:]...)
+	}
+
+	return len(p), nil
+}
+
+const onClose = "on fileWriter.Close()"
+
+// Close copies the sealed content from the temporary file to the target one: the metadata (with the hash and the size)
+// is known now only
+func (fw *fileWriter) Close() error {
+	if fw.finished {
+		return fmt.Errorf(onClose + ": the writer is closed already")
+	}
+	fw.finished = true
+
+	if fw.err != nil {
+		fw.tempWriter.Abort()
+		return fw.err
+	}
+	if err := fw.seal(fw.buffer, true); err != nil {
+		fw.tempWriter.Abort()
+		return errors.CommonError(err, onClose)
+	}
+	if err := fw.tempWriter.Close(); err != nil {
+		return errors.CommonError(err, onClose)
+	}
+
+	filesOp := fw.filesOp
+	tempPath := fw.tempWriter.Path()
+	defer filesOp.storageOp.Remove(tempPath)
+
+	meta := fileMeta{
+		MIMEType:  fw.digest.MIMEType(fw.filename, fw.meta),
+		Size:      fw.size,
+		Hash:      fw.digest.Hash(),
+		ChunkSize: filesOp.chunkSize,
+		CreatedAt: time.Now(),
+	}
+	if fw.meta != nil {
+		meta.OriginalName, meta.Tags = fw.meta.OriginalName, fw.meta.Tags
+	}
+
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return errors.Wrapf(err, onClose+": can't json.Marshal(%#v)", meta)
+	}
+	keyID, wrappedKey, err := filesOp.keys.wrap(fw.dataKey)
+	if err != nil {
+		return errors.CommonError(err, onClose)
+	}
+	storageMeta := files.Meta{
+		MIMEType: storageMIMEType,
+		Tags: files.Tags{
+			tagKeyID: keyID,
+			tagKey:   wrappedKey,
+			tagMeta:  base64.StdEncoding.EncodeToString(fw.aead.Seal(nil, metaNonce(), metaJSON, nil)),
+		},
+	}
+
+	filesOp.mutex.RLock()
+	defer filesOp.mutex.RUnlock()
+
+	reader, _, err := filesOp.storageOp.Open(tempPath)
+	if err != nil {
+		return errors.CommonError(err, onClose)
+	}
+	defer reader.Close()
+
+	storagePath, err := filesOp.rewrite(reader, fw.dataPath, fw.newFilePattern, &storageMeta)
+	if err != nil {
+		return errors.CommonError(err, onClose)
+	}
+
+	fw.path = strings.TrimPrefix(storagePath, dataDir+"/")
+	return nil
+}
+
+func (fw *fileWriter) Abort() error {
+	fw.finished = true
+	fw.buffer = nil
+
+	return fw.tempWriter.Abort()
+}
+
+func (fw *fileWriter) Path() string {
+	return fw.path
+}
+
+func (fw *fileWriter) seal(plain []byte, last bool) error {
+	sealed := fw.aead.Seal(nil, chunkNonce(fw.chunks, last), plain, nil)
+	fw.chunks++
+
+	_, err := fw.tempWriter.Write(sealed)
+	return err
+}
+
+// rewrite copies the storage content from reader to the storage file (it's used for sealed contents only)
+func (filesOp *filesEncrypted) rewrite(reader io.Reader, path, newFilePattern string, storageMeta *files.Meta) (string, error) {
+	writer, err := filesOp.storageOp.Create(path, newFilePattern, storageMeta)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = io.Copy(writer, reader); err != nil {
+		writer.Abort()
+		return "", err
+	}
+	if err = writer.Close(); err != nil {
+		return "", err
+	}
+
+	return writer.Path(), nil
+}
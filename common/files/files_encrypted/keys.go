@@ -0,0 +1,154 @@
+package files_encrypted
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pavlo67/common/common/errors"
+)
+
+const masterKeyLength = 32 // AES-256
+
+// MasterKey wraps (encrypts) data keys of files, Key is base64 of 32 random bytes
+type MasterKey struct {
+	ID  string `json:"id"  yaml:"id"`
+	Key string `json:"key" yaml:"key"`
+}
+
+func NewMasterKey(id string) (*MasterKey, error) {
+	key := make([]byte, masterKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, errors.Wrap(err, "on files_encrypted.NewMasterKey()")
+	}
+
+	return &MasterKey{ID: id, Key: base64.StdEncoding.EncodeToString(key)}, nil
+}
+
+// Config lists master keys in the config or in KeyFile (JSON list of MasterKey), the first one is current (new data keys
+// are wrapped with it), others unwrap data keys wrapped before the rotation (see Operator.Rewrap())
+type Config struct {
+	Keys    []MasterKey `json:"keys"     yaml:"keys"`
+	KeyFile string      `json:"key_file" yaml:"key_file"`
+}
+
+const onMasterKeys = "on files_encrypted.Config.MasterKeys()"
+
+func (cfg Config) MasterKeys() ([]MasterKey, error) {
+	if cfg.KeyFile == "" {
+		return cfg.Keys, nil
+	} else if len(cfg.Keys) > 0 {
+		return nil, errors.New(onMasterKeys + ": both keys and key file are set")
+	}
+
+	data, err := ioutil.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, onMasterKeys+": can't read key file (%s)", cfg.KeyFile)
+	}
+
+	var masterKeys []MasterKey
+	if err = json.Unmarshal(data, &masterKeys); err != nil {
+		return nil, errors.Wrapf(err, onMasterKeys+": can't json.Unmarshal key file (%s)", cfg.KeyFile)
+	}
+
+	return masterKeys, nil
+}
+
+// keyring ------------------------------------------------------------------------------------------------------------
+
+type keyring struct {
+	currentID string
+	aeads     map[string]cipher.AEAD
+}
+
+func newKeyring(masterKeys []MasterKey) (*keyring, error) {
+	if len(masterKeys) < 1 {
+		return nil, errors.New("no master keys")
+	}
+
+	keys := keyring{currentID: masterKeys[0].ID, aeads: map[string]cipher.AEAD{}}
+	for _, masterKey := range masterKeys {
+		if masterKey.ID == "" {
+			return nil, errors.New("empty master key id")
+		} else if _, ok := keys.aeads[masterKey.ID]; ok {
+			return nil, fmt.Errorf("duplicate master key id '%s'", masterKey.ID)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(masterKey.Key)
+		if err != nil || len(key) != masterKeyLength {
+			return nil, fmt.Errorf("master key '%s' should be base64 of %d bytes", masterKey.ID, masterKeyLength)
+		}
+		if keys.aeads[masterKey.ID], err = newAEAD(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return &keys, nil
+}
+
+// wrap encrypts the data key with the current master key, its id is authenticated too
+func (keys *keyring) wrap(dataKey []byte) (id, wrapped string, err error) {
+	nonce := make([]byte, keys.aeads[keys.currentID].NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", "", err
+	}
+
+	sealed := keys.aeads[keys.currentID].Seal(nonce, nonce, dataKey, []byte(keys.currentID))
+	return keys.currentID, base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (keys *keyring) unwrap(id, wrapped string) ([]byte, error) {
+	aead := keys.aeads[id]
+	if aead == nil {
+		return nil, fmt.Errorf("no master key '%s'", id)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, errors.CommonError(TamperedKey, "wrong wrapped data key")
+	}
+
+	dataKey, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, errors.CommonError(TamperedKey, err, "can't unwrap data key")
+	}
+
+	return dataKey, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// data keys are used once for each file, so nonces are fixed: the metadata is sealed with all 0xFF bytes and chunks are
+// sealed with their numbers (and the flag of the last one, so truncated content can't be authenticated)
+
+func metaNonce() []byte {
+	nonce := make([]byte, 12)
+	for i := range nonce {
+		nonce[i] = 0xFF
+	}
+
+	return nonce
+}
+
+func chunkNonce(n uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	for i := 10; i >= 3; i-- {
+		nonce[i], n = byte(n), n>>8
+	}
+	if last {
+		nonce[11] = 1
+	}
+
+	return nonce
+}
@@ -0,0 +1,83 @@
+package files_encrypted
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/config"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/joiner"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/starter"
+)
+
+func Starter() starter.Operator {
+	return &filesEncryptedStarter{}
+}
+
+var l logger.Operator
+var _ starter.Operator = &filesEncryptedStarter{}
+
+type filesEncryptedStarter struct {
+	masterKeys   []MasterKey
+	chunkSize    int
+	storageKey   joiner.InterfaceKey
+	interfaceKey joiner.InterfaceKey
+	cleanerKey   joiner.InterfaceKey
+}
+
+func (fes *filesEncryptedStarter) Name() string {
+	return logger.GetCallInfo().PackageName
+}
+
+func (fes *filesEncryptedStarter) Prepare(cfg *config.Config, options common.Map) error {
+	configKey := strings.TrimSpace(options.StringDefault("config_key", "files_encrypted"))
+	if configKey == "" {
+		return fmt.Errorf("no 'config_key' in options (%#v)", options)
+	}
+
+	var cfgEncrypted Config
+	if err := cfg.Value(configKey, &cfgEncrypted); err != nil {
+		return errors.CommonError(err, fmt.Sprintf("can't get config value '%s'", configKey))
+	}
+
+	var err error
+	if fes.masterKeys, err = cfgEncrypted.MasterKeys(); err != nil {
+		return err
+	}
+
+	fes.chunkSize = int(options.Int64Default("chunk_size", DefaultChunkSize))
+	fes.storageKey = joiner.InterfaceKey(options.StringDefault("storage_key", string(files.InterfaceKeyStorage)))
+	fes.interfaceKey = joiner.InterfaceKey(options.StringDefault("interface_key", string(files.InterfaceKey)))
+	fes.cleanerKey = joiner.InterfaceKey(options.StringDefault("cleaner_key", string(files.InterfaceKeyCleaner)))
+
+	return nil
+}
+
+func (fes *filesEncryptedStarter) Run(joinerOp joiner.Operator) error {
+	if l, _ = joinerOp.Interface(logger.InterfaceKey).(logger.Operator); l == nil {
+		return fmt.Errorf("no logger.Operator with key %s", logger.InterfaceKey)
+	}
+
+	storageOp, _ := joinerOp.Interface(fes.storageKey).(files.Operator)
+	if storageOp == nil {
+		return fmt.Errorf("no files.Operator with key %s", fes.storageKey)
+	}
+
+	filesOp, filesCleanerOp, err := New(storageOp, fes.masterKeys, fes.chunkSize)
+	if err != nil {
+		return errors.Wrap(err, "can't init *filesEncrypted{} as files.Operator")
+	}
+
+	if err = joinerOp.Join(filesOp, fes.interfaceKey); err != nil {
+		return errors.Wrapf(err, "can't join *filesEncrypted{} as files.Operator with key '%s'", fes.interfaceKey)
+	}
+
+	if err = joinerOp.Join(filesCleanerOp, fes.cleanerKey); err != nil {
+		return errors.Wrapf(err, "can't join *filesEncrypted{} as db.Cleaner with key '%s'", fes.cleanerKey)
+	}
+
+	return nil
+}
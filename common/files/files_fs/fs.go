@@ -1,20 +1,25 @@
 package files_fs
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/pavlo67/common/common"
 	"github.com/pavlo67/common/common/db"
 	"github.com/pavlo67/common/common/errors"
 	"github.com/pavlo67/common/common/filelib"
+	"github.com/pavlo67/common/common/health"
 
 	"github.com/pavlo67/common/common/files"
 )
 
 var _ files.Operator = &filesFS{}
+var _ files.Tagger = &filesFS{}
 
 type filesFS struct {
 	basePath string
@@ -35,123 +40,166 @@ func New(basePath string) (files.Operator, db.Cleaner, error) {
 	return &filesOp, &filesOp, nil
 }
 
-const onSave = "on filesFS.Save()"
-
-func (filesOp *filesFS) Save(path, newFilePattern string, data []byte) (string, error) {
-	basePath := filesOp.basePath
-	path = basePath + path
-
-	var err error
-	var dirPath string
-	var file *os.File
+func (filesOp *filesFS) Save(path, newFilePattern string, data []byte, meta *files.Meta) (string, error) {
+	return files.SaveData(filesOp, path, newFilePattern, data, meta)
+}
 
-	// TODO!!! check if dirPath doesn't contain "/../"
-	if newFilePattern != "" {
-		if dirPath, err = filelib.Dir(path); err != nil {
-			return "", errors.Wrapf(err, onSave+": wrong path (%s)", path)
-		}
-		if file, err = ioutil.TempFile(dirPath, newFilePattern); err != nil {
-			return "", errors.Wrapf(err, onSave+": can't ioutil.TempFile(%s, %s)", dirPath, newFilePattern)
-		}
-	} else {
-		var filename string
-		dirPath, filename = filepath.Dir(path), filepath.Base(path)
+func (filesOp *filesFS) Read(path string) ([]byte, error) {
+	return files.ReadData(filesOp, path)
+}
 
-		dirPath, err = filelib.Dir(dirPath)
+const onOpen = "on filesFS.Open()"
 
-		if file, err = os.OpenFile(dirPath+filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644); err != nil {
-			return "", errors.Wrapf(err, onSave+": can't os.OpenFile(%s, os.O_CREATE | os.O_TRUNC | os.O_WRONLY, 0644)", dirPath+filename)
-		}
+func (filesOp *filesFS) Open(path string) (io.ReadCloser, *files.Item, error) {
+	filePath, err := filelib.Confine(filesOp.basePath, path)
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onOpen)
 	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			l.Errorf(onSave+": on file.Close() got %s", err)
-		}
-	}()
 
-	filename := strings.ReplaceAll(file.Name(), "/./", "/")
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, onOpen+": can't os.Open(%s)", filePath)
+	}
 
-	if len(filename) <= len(basePath) {
-		return "", fmt.Errorf(onSave+": wrong filename (%s) on basePath = '%s'", filename, basePath)
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, errors.Wrapf(err, onOpen+": can't file.Stat(%s)", filePath)
+	} else if fi.IsDir() {
+		file.Close()
+		return nil, nil, fmt.Errorf(onOpen+": %s is a directory", filePath)
 	}
 
-	if _, err = file.Write(data); err != nil {
-		return "", errors.Wrapf(err, onSave+": can't file.Write(%s)", file.Name())
+	fileInfo, err := item(filesOp.basePath, filePath, fi)
+	if err != nil {
+		file.Close()
+		return nil, nil, errors.CommonError(err, onOpen)
 	}
 
-	return filename[len(basePath):], nil
+	return file, fileInfo, nil
 }
 
-const onRead = "on filesFS.Read()"
+const onCreate = "on filesFS.Create()"
 
-func (filesOp *filesFS) Read(path string) ([]byte, error) {
-	filePath := filesOp.basePath + path
+func (filesOp *filesFS) Create(path, newFilePattern string, meta *files.Meta) (files.Writer, error) {
+	path, err := filelib.Confine(filesOp.basePath, path)
+	if err != nil {
+		return nil, errors.CommonError(err, onCreate)
+	}
+
+	var dirPath, filename string
+	if newFilePattern == "" {
+		dirPath, filename = filepath.Dir(path), filepath.Base(path)
+		if isHidden(filename) {
+			return nil, errors.CommonError(common.WrongPathKey, common.Map{"path": path}, onCreate+": reserved file name")
+		}
+	} else {
+		dirPath = path
+	}
+
+	if dirPath, err = filelib.Dir(dirPath); err != nil {
+		return nil, errors.Wrapf(err, onCreate+": wrong path (%s)", path)
+	}
 
-	data, err := ioutil.ReadFile(filePath)
+	file, err := ioutil.TempFile(dirPath, tempPrefix+"*")
 	if err != nil {
-		return nil, errors.Wrapf(err, onRead+": can't ioutil.ReadFile(%s)", filePath)
+		return nil, errors.Wrapf(err, onCreate+": can't ioutil.TempFile(%s, %s*)", dirPath, tempPrefix)
 	}
 
-	return data, nil
+	return &fileWriter{
+		file:           file,
+		basePath:       filesOp.basePath,
+		dirPath:        dirPath,
+		filename:       filename,
+		newFilePattern: newFilePattern,
+		meta:           meta,
+		digest:         files.NewDigest(),
+	}, nil
 }
 
 const onRemove = "on filesFS.Remove()"
 
 func (filesOp *filesFS) Remove(path string) error {
-	filePath := filesOp.basePath + path
+	filePath, err := filelib.Confine(filesOp.basePath, path)
+	if err != nil {
+		return errors.CommonError(err, onRemove)
+	}
 
-	if err := os.Remove(filePath); err != nil {
+	if err = os.Remove(filePath); err != nil {
 		return errors.Wrapf(err, onRemove+": can't os.Remove(%s)", filePath)
 	}
+	if err = removeMeta(filePath); err != nil {
+		return errors.Wrapf(err, onRemove+": can't remove metadata of %s", filePath)
+	}
 
 	return nil
 }
 
-const onList = "on filesFS.Items()"
+const onList = "on filesFS.List()"
 
-func (filesOp *filesFS) List(path string, depth int) (files.Items, error) {
-	filePath := filesOp.basePath + path
+// List walks the whole subtree below path on each call (except directories excluded with options), the metadata sidecars
+// of files on the previous pages aren't read if items are sorted by name, all of them are read for other sort keys
 
-	var filesInfo files.Items
+func (filesOp *filesFS) List(path string, depth int, options *files.ListOptions) (files.Items, string, error) {
+	if err := files.CheckListOptions(options); err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	dirPath, err := filelib.Confine(filesOp.basePath, path)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
 
-	if depth == 0 {
-		fis, err := ioutil.ReadDir(filePath)
+	fi, err := os.Stat(dirPath)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, onList+": can't os.Stat(%s)", dirPath)
+	} else if !fi.IsDir() {
+		return nil, "", fmt.Errorf(onList+": %s isn't a directory", dirPath)
+	}
 
-		if err != nil {
-			return nil, errors.Wrapf(err, onList+": can't ioutil.ReadDir(%s)", filePath)
-		}
+	beforeCursor := files.BeforeNameCursor(options)
 
-		for _, fi := range fis {
-			filesInfo, err = filesInfo.Append("", fi) // basePath
+	var filesInfo files.Items
+	if err = walk(dirPath, depth, func(filePath string, fi os.FileInfo) error {
+		if beforeCursor != nil && !fi.IsDir() {
+			relPath, err := itemPath(filesOp.basePath, filePath, false)
 			if err != nil {
-				return nil, errors.Wrap(err, onList)
+				return err
+			} else if beforeCursor(relPath) {
+				return nil
 			}
 		}
 
-		return filesInfo, nil
-	}
-
-	// TODO: process depth > 0 more thoroughly here
-	err := filepath.Walk(filePath, func(path string, fi os.FileInfo, err error) error {
+		fileInfo, err := item(filesOp.basePath, filePath, fi)
 		if err != nil {
 			return err
-		}
-
-		filesInfo, err = filesInfo.Append("", fi) // basePath
-		if err != nil {
-			return errors.Wrap(err, onList)
+		} else if fileInfo.Matches(options) {
+			filesInfo = append(filesInfo, *fileInfo)
+		} else if fi.IsDir() && options != nil && !fileInfo.Matches(&files.ListOptions{Exclude: options.Exclude}) {
+			// excluded directories are skipped with their contents
+			return filepath.SkipDir
 		}
 
 		return nil
-	})
+	}); err != nil {
+		return nil, "", errors.Wrapf(err, onList+": can't walk %s", dirPath)
+	}
 
-	return filesInfo, err
+	filesInfo, cursor, err := filesInfo.Page(options)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	return filesInfo, cursor, nil
 }
 
 const onStat = "on filesFS.Stat()"
 
 func (filesOp *filesFS) Stat(path string, depth int) (*files.Item, error) {
-	filePath := filesOp.basePath + path
+	filePath, err := filelib.Confine(filesOp.basePath, path)
+	if err != nil {
+		return nil, errors.CommonError(err, onStat)
+	}
 
 	fi, err := os.Stat(filePath)
 	if err != nil {
@@ -161,28 +209,115 @@ func (filesOp *filesFS) Stat(path string, depth int) (*files.Item, error) {
 		return nil, errors.Wrapf(err, onStat+": can't  os.Stat(%s)", filePath)
 	}
 
-	filesInfo, err := files.Items{}.Append("", fi) // basePath
-	if err != nil || len(filesInfo) != 1 {
-		return nil, fmt.Errorf(onStat+": got %#v / %s", filesInfo, err)
+	fileInfo, err := item(filesOp.basePath, filePath, fi)
+	if err != nil {
+		return nil, errors.CommonError(err, onStat)
+	}
+
+	if depth != 0 && fileInfo.IsDir {
+		if err = walk(filePath, depth, func(_ string, fi os.FileInfo) error {
+			if !fi.IsDir() {
+				fileInfo.Size += fi.Size()
+			}
+			return nil
+		}); err != nil {
+			return nil, errors.Wrapf(err, onStat+": can't walk %s", filePath)
+		}
 	}
 
-	fileInfo := filesInfo[0]
+	return fileInfo, nil
+}
 
-	if depth != 0 && fileInfo.IsDir {
-		// TODO: process depth > 0 more thoroughly here
-		err = filepath.Walk(filePath, func(path string, fi os.FileInfo, err error) error {
+// walk calls walkFn for items below dirPath on the levels defined with depth (as for List) skipping hidden ones
+// (temporary files and metadata sidecars), walkFn can return filepath.SkipDir for a directory to skip its contents
+func walk(dirPath string, depth int, walkFn func(filePath string, fi os.FileInfo) error) error {
+	return filepath.Walk(dirPath, func(filePath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if filePath == dirPath {
+			return nil
+		} else if isHidden(fi.Name()) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if err = walkFn(filePath, fi); err != nil {
+			return err
+		}
+
+		if fi.IsDir() && depth >= 0 {
+			relPath, err := filepath.Rel(dirPath, filePath)
 			if err != nil {
 				return err
+			} else if strings.Count(filepath.ToSlash(relPath), "/") >= depth {
+				return filepath.SkipDir
 			}
+		}
 
-			if !fi.IsDir() {
-				fileInfo.Size += fi.Size()
-			}
+		return nil
+	})
+}
 
-			return nil
-		})
+const onSetTags = "on filesFS.SetTags()"
+
+// SetTags replaces the metadata sidecar of the file (it's created if the file has no metadata)
+func (filesOp *filesFS) SetTags(path string, tags files.Tags) error {
+	filePath, err := filelib.Confine(filesOp.basePath, path)
+	if err != nil {
+		return errors.CommonError(err, onSetTags)
+	}
+
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return errors.Wrapf(err, onSetTags+": can't os.Stat(%s)", filePath)
+	} else if fi.IsDir() {
+		return fmt.Errorf(onSetTags+": %s is a directory", filePath)
 	}
 
-	return &fileInfo, err
+	meta, err := readMeta(filePath)
+	if err != nil {
+		return errors.CommonError(err, onSetTags)
+	} else if meta == nil {
+		meta = &fileMeta{CreatedAt: fi.ModTime()}
+	}
+	meta.Tags = tags
 
+	metaTempName, err := writeMetaTemp(filepath.Dir(filePath), *meta)
+	if err != nil {
+		return errors.CommonError(err, onSetTags)
+	}
+	if err = rename(metaTempName, metaPath(filePath)); err != nil {
+		os.Remove(metaTempName)
+		return errors.Wrapf(err, onSetTags+": can't os.Rename(%s, %s)", metaTempName, metaPath(filePath))
+	}
+
+	return nil
+}
+
+var _ health.HealthChecker = &filesFS{}
+
+const onHealthCheck = "on filesFS.HealthCheck()"
+
+// HealthCheck verifies the base path is a writable directory
+func (filesOp *filesFS) HealthCheck(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filesOp.basePath, ".health_*")
+	if err != nil {
+		return errors.Wrapf(err, onHealthCheck+": can't ioutil.TempFile(%s, .health_*)", filesOp.basePath)
+	}
+
+	fileName := file.Name()
+	if err = file.Close(); err != nil {
+		return errors.Wrapf(err, onHealthCheck+": can't file.Close(%s)", fileName)
+	}
+	if err = os.Remove(fileName); err != nil {
+		return errors.Wrapf(err, onHealthCheck+": can't os.Remove(%s)", fileName)
+	}
+
+	return nil
 }
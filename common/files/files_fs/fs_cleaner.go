@@ -2,20 +2,77 @@ package files_fs
 
 import (
 	"os"
+	"path/filepath"
 
-	"github.com/pavlo67/common/common/db"
 	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
 	"github.com/pavlo67/common/common/selectors"
 )
 
-var _ db.Cleaner = &filesFS{}
+var _ files.Cleaner = &filesFS{}
 
 const onClean = "on filesFS.Clean()"
 
 func (filesOp *filesFS) Clean(term *selectors.Term) error {
-	if err := os.RemoveAll(filesOp.basePath); err != nil {
-		return errors.Wrapf(err, onClean+": removing %s", filesOp.basePath)
+	if _, err := filesOp.CleanItems(term, false); err != nil {
+		return errors.CommonError(err, onClean)
 	}
 
 	return nil
 }
+
+const onCleanItems = "on filesFS.CleanItems()"
+
+func (filesOp *filesFS) CleanItems(term *selectors.Term, dryRun bool) (files.Items, error) {
+	selected, err := files.Selected(term)
+	if err != nil {
+		return nil, errors.CommonError(err, onCleanItems)
+	}
+
+	var items files.Items
+	if err = filepath.Walk(filesOp.basePath, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == filesOp.basePath {
+				return filepath.SkipDir
+			}
+			return err
+		} else if fi.IsDir() || isHidden(fi.Name()) {
+			return nil
+		}
+
+		fileInfo, err := item(filesOp.basePath, path, fi)
+		if err != nil {
+			return err
+		}
+
+		if selected(*fileInfo) {
+			items = append(items, *fileInfo)
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, onCleanItems+": can't walk %s", filesOp.basePath)
+	}
+
+	if dryRun {
+		return items, nil
+	}
+
+	if term == nil {
+		if err = os.RemoveAll(filesOp.basePath); err != nil {
+			return nil, errors.Wrapf(err, onCleanItems+": removing %s", filesOp.basePath)
+		}
+		return items, nil
+	}
+
+	for i, fileInfo := range items {
+		filePath := filesOp.basePath + fileInfo.Path
+		if err = os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return items[:i], errors.Wrapf(err, onCleanItems+": can't os.Remove(%s)", filePath)
+		}
+		if err = removeMeta(filePath); err != nil {
+			return items[:i], errors.Wrapf(err, onCleanItems+": can't remove metadata of %s", filePath)
+		}
+	}
+
+	return items, nil
+}
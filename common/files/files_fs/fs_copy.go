@@ -0,0 +1,230 @@
+package files_fs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/filelib"
+)
+
+const onRemoveAll = "on filesFS.RemoveAll()"
+
+func (filesOp *filesFS) RemoveAll(path string) error {
+	filePath, err := filesOp.existing(path)
+	if err != nil {
+		return errors.CommonError(err, onRemoveAll)
+	}
+
+	if err = os.RemoveAll(filePath); err != nil {
+		return errors.Wrapf(err, onRemoveAll+": can't os.RemoveAll(%s)", filePath)
+	}
+	if err = removeMeta(filePath); err != nil {
+		return errors.Wrapf(err, onRemoveAll+": can't remove metadata of %s", filePath)
+	}
+
+	return nil
+}
+
+const onCopy = "on filesFS.Copy()"
+
+func (filesOp *filesFS) Copy(from, to string) error {
+	fromPath, toPath, err := filesOp.fromTo(from, to)
+	if err != nil {
+		return errors.CommonError(err, onCopy)
+	}
+
+	if err = copyAll(fromPath, toPath); err != nil {
+		return errors.CommonError(err, onCopy)
+	}
+
+	return nil
+}
+
+// rename is replaced in tests to check the moving across devices and the rollback of failed writes
+var rename = os.Rename
+
+const onMove = "on filesFS.Move()"
+
+func (filesOp *filesFS) Move(from, to string) error {
+	fromPath, toPath, err := filesOp.fromTo(from, to)
+	if err != nil {
+		return errors.CommonError(err, onMove)
+	}
+
+	if err = rename(fromPath, toPath); err == nil {
+		if err = os.Rename(metaPath(fromPath), metaPath(toPath)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, onMove+": can't move metadata of %s", fromPath)
+		} else if os.IsNotExist(err) {
+			if err = removeMeta(toPath); err != nil {
+				return errors.Wrapf(err, onMove+": can't remove old metadata of %s", toPath)
+			}
+		}
+		return nil
+	} else if !errors.Is(err, syscall.EXDEV) {
+		return errors.Wrapf(err, onMove+": can't os.Rename(%s, %s)", fromPath, toPath)
+	}
+
+	// the target is on another device
+	if err = copyAll(fromPath, toPath); err != nil {
+		return errors.CommonError(err, onMove)
+	}
+	if err = os.RemoveAll(fromPath); err != nil {
+		return errors.Wrapf(err, onMove+": can't os.RemoveAll(%s) after copying", fromPath)
+	}
+	if err = removeMeta(fromPath); err != nil {
+		return errors.Wrapf(err, onMove+": can't remove metadata of %s after copying", fromPath)
+	}
+
+	return nil
+}
+
+// existing returns the full path of the existing file or directory (but not the root)
+func (filesOp *filesFS) existing(path string) (string, error) {
+	filePath, err := filelib.Confine(filesOp.basePath, path)
+	if err != nil {
+		return "", err
+	} else if filePath == filesOp.basePath {
+		return "", errors.CommonError(common.WrongPathKey, common.Map{"path": path}, "the root can't be used")
+	} else if isHidden(filepath.Base(filePath)) {
+		return "", errors.CommonError(common.WrongPathKey, common.Map{"path": path}, "reserved file name")
+	}
+
+	if _, err = os.Lstat(filePath); os.IsNotExist(err) {
+		return "", errors.CommonError(common.NotFoundKey, common.Map{"path": path}, "no such file or directory")
+	} else if err != nil {
+		return "", errors.Wrapf(err, "can't os.Lstat(%s)", filePath)
+	}
+
+	return filePath, nil
+}
+
+// fromTo checks the source and the target for Copy() and Move(), the target directory is created if it's necessary
+func (filesOp *filesFS) fromTo(from, to string) (string, string, error) {
+	fromPath, err := filesOp.existing(from)
+	if err != nil {
+		return "", "", err
+	}
+
+	toPath, err := filelib.Confine(filesOp.basePath, to)
+	if err != nil {
+		return "", "", err
+	} else if toPath == filesOp.basePath {
+		return "", "", errors.CommonError(common.WrongPathKey, common.Map{"path": to}, "the root can't be used")
+	} else if isHidden(filepath.Base(toPath)) {
+		return "", "", errors.CommonError(common.WrongPathKey, common.Map{"path": to}, "reserved file name")
+	} else if toPath == fromPath || strings.HasPrefix(toPath, fromPath+"/") {
+		return "", "", errors.CommonError(common.WrongPathKey, common.Map{"from": from, "to": to}, "the target is inside the source")
+	}
+
+	fromInfo, err := os.Lstat(fromPath)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "can't os.Lstat(%s)", fromPath)
+	}
+	if toInfo, err := os.Lstat(toPath); err == nil && (fromInfo.IsDir() || toInfo.IsDir()) {
+		return "", "", errors.CommonError(common.WrongPathKey, common.Map{"path": to}, "the target directory exists already")
+	}
+
+	if _, err = filelib.Dir(filepath.Dir(toPath)); err != nil {
+		return "", "", errors.Wrapf(err, "can't create the target directory for %s", toPath)
+	}
+
+	return fromPath, toPath, nil
+}
+
+// copyAll copies the file or the directory with its contents and metadata, symlinks aren't followed (see copyFile)
+func copyAll(fromPath, toPath string) error {
+	// symlinks are checked before copying, so nothing is copied partially because of them
+	if err := filepath.Walk(fromPath, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if fi.Mode()&os.ModeSymlink != 0 {
+			return errors.CommonError(common.WrongPathKey, common.Map{"path": path}, "symlinks can't be copied")
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return filepath.Walk(fromPath, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if isTemp(fi.Name()) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(fromPath, path)
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(toPath, relPath)
+
+		if fi.IsDir() {
+			if err = os.MkdirAll(targetPath, os.ModePerm); err != nil {
+				return errors.Wrapf(err, "can't os.MkdirAll(%s)", targetPath)
+			}
+			return nil
+		}
+
+		if err = copyFile(path, targetPath); err != nil {
+			return err
+		}
+
+		if path == fromPath {
+			// the single file is copied, so its metadata should be copied too (they are copied as files in directories)
+			if err = copyFile(metaPath(path), metaPath(targetPath)); errors.Is(err, os.ErrNotExist) {
+				return removeMeta(targetPath)
+			}
+		}
+
+		return err
+	})
+}
+
+// copyFile writes the copy into the temporary file and renames it to toPath, only regular files are copied: symlinks are
+// rejected (their targets can be outside of the root and the relative ones would point elsewhere after copying)
+func copyFile(fromPath, toPath string) error {
+	fi, err := os.Lstat(fromPath)
+	if err != nil {
+		return errors.Wrapf(err, "can't os.Lstat(%s)", fromPath)
+	} else if !fi.Mode().IsRegular() {
+		return errors.CommonError(common.WrongPathKey, common.Map{"path": fromPath}, "only regular files can be copied")
+	}
+
+	from, err := os.Open(fromPath)
+	if err != nil {
+		return errors.Wrapf(err, "can't os.Open(%s)", fromPath)
+	}
+	defer from.Close()
+
+	to, err := ioutil.TempFile(filepath.Dir(toPath), tempPrefix+"*")
+	if err != nil {
+		return errors.Wrapf(err, "can't ioutil.TempFile(%s, %s*)", filepath.Dir(toPath), tempPrefix)
+	}
+	tempName := to.Name()
+
+	_, err = io.Copy(to, from)
+	if err == nil {
+		err = to.Sync()
+	}
+	if errClose := to.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Chmod(tempName, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tempName, toPath)
+	}
+	if err != nil {
+		os.Remove(tempName)
+		return errors.Wrapf(err, "can't copy %s to %s", fromPath, toPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package files_fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+)
+
+// metaPrefix marks sidecar files with the metadata of the file with the same name (without the prefix) in the same directory,
+// they aren't listed
+const metaPrefix = ".meta_"
+
+func isHidden(name string) bool {
+	return isTemp(name) || strings.HasPrefix(name, metaPrefix)
+}
+
+func metaPath(filePath string) string {
+	return filepath.Dir(filePath) + "/" + metaPrefix + filepath.Base(filePath)
+}
+
+type fileMeta struct {
+	CreatedAt    time.Time  `json:"created_at"`
+	MIMEType     string     `json:"mime_type,omitempty"`
+	Hash         string     `json:"hash,omitempty"`
+	OriginalName string     `json:"original_name,omitempty"`
+	Tags         files.Tags `json:"tags,omitempty"`
+}
+
+const onWriteMetaTemp = "on files_fs.writeMetaTemp()"
+
+// writeMetaTemp writes metadata to the temporary file in dirPath, it should be renamed to metaPath() of the data file
+func writeMetaTemp(dirPath string, meta fileMeta) (string, error) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return "", errors.Wrapf(err, onWriteMetaTemp+": can't json.Marshal(%#v)", meta)
+	}
+
+	file, err := ioutil.TempFile(dirPath, tempPrefix+"*")
+	if err != nil {
+		return "", errors.Wrapf(err, onWriteMetaTemp+": can't ioutil.TempFile(%s, %s*)", dirPath, tempPrefix)
+	}
+	tempName := file.Name()
+
+	_, err = file.Write(data)
+	if errSync := file.Sync(); err == nil {
+		err = errSync
+	}
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Chmod(tempName, 0644)
+	}
+	if err != nil {
+		os.Remove(tempName)
+		return "", errors.Wrapf(err, onWriteMetaTemp+": can't write the temporary file %s", tempName)
+	}
+
+	return tempName, nil
+}
+
+const onReadMeta = "on files_fs.readMeta()"
+
+// readMeta returns nil without error if the file has no metadata
+func readMeta(filePath string) (*fileMeta, error) {
+	data, err := ioutil.ReadFile(metaPath(filePath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, onReadMeta+": can't ioutil.ReadFile(%s)", metaPath(filePath))
+	}
+
+	var meta fileMeta
+	if err = json.Unmarshal(data, &meta); err != nil {
+		return nil, errors.Wrapf(err, onReadMeta+": can't json.Unmarshal(%s)", data)
+	}
+
+	return &meta, nil
+}
+
+func removeMeta(filePath string) error {
+	if err := os.Remove(metaPath(filePath)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// itemPath returns the path of the item relative to basePath (directories have "/" suffix)
+func itemPath(basePath, filePath string, isDir bool) (string, error) {
+	relPath, err := filepath.Rel(basePath, filePath)
+	if err != nil {
+		return "", errors.Wrapf(err, "can't filepath.Rel(%s, %s)", basePath, filePath)
+	}
+
+	if relPath = filepath.ToSlash(relPath); relPath == "." {
+		return "", nil
+	} else if isDir {
+		return relPath + "/", nil
+	}
+
+	return relPath, nil
+}
+
+const onItem = "on files_fs.item()"
+
+// item returns the item for the file info with its metadata (if there are any) and the path relative to basePath
+func item(basePath, filePath string, fi os.FileInfo) (*files.Item, error) {
+	items, err := files.Items{}.Append("", fi) // basePath
+	if err != nil || len(items) != 1 {
+		return nil, fmt.Errorf(onItem+": got %#v / %s", items, err)
+	}
+
+	item := items[0]
+	if item.Path, err = itemPath(basePath, filePath, item.IsDir); err != nil {
+		return nil, errors.CommonError(err, onItem)
+	} else if item.IsDir {
+		return &item, nil
+	}
+
+	meta, err := readMeta(filePath)
+	if err != nil {
+		return nil, errors.CommonError(err, onItem)
+	} else if meta != nil {
+		item.CreatedAt = meta.CreatedAt
+		item.MIMEType = meta.MIMEType
+		item.Hash = meta.Hash
+		item.OriginalName = meta.OriginalName
+		item.Tags = meta.Tags
+	}
+
+	return &item, nil
+}
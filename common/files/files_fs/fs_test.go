@@ -1,6 +1,10 @@
 package files_fs
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -8,6 +12,9 @@ import (
 	"github.com/pavlo67/common/common"
 	"github.com/pavlo67/common/common/apps"
 	"github.com/pavlo67/common/common/config"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/joiner/joiner_runtime"
+	"github.com/pavlo67/common/common/logger/logger_test"
 	"github.com/pavlo67/common/common/starter"
 
 	"github.com/pavlo67/common/common/files"
@@ -32,3 +39,124 @@ func TestFilesFS(t *testing.T) {
 
 	files.FilesTestScenario(t, joinerOp, files.InterfaceKey, files.InterfaceKeyCleaner)
 }
+
+func TestFilesFSTempDir(t *testing.T) {
+	l = logger_test.New(t)
+
+	filesOp, filesCleanerOp, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	joinerOp := joiner_runtime.New(nil, l)
+	require.NoError(t, joinerOp.Join(filesOp, files.InterfaceKey))
+	require.NoError(t, joinerOp.Join(filesCleanerOp, files.InterfaceKeyCleaner))
+
+	files.FilesTestScenario(t, joinerOp, files.InterfaceKey, files.InterfaceKeyCleaner)
+}
+
+func TestFilesFSMoveAcrossDevices(t *testing.T) {
+	l = logger_test.New(t)
+
+	filesOp, _, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	// temporary files of the writers are renamed in the same directory
+	rename = func(oldpath, newpath string) error {
+		if isTemp(filepath.Base(oldpath)) {
+			return os.Rename(oldpath, newpath)
+		}
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	defer func() { rename = os.Rename }()
+
+	files.CopyMoveTestScenario(t, filesOp)
+}
+
+func TestFilesFSWriteRollback(t *testing.T) {
+	l = logger_test.New(t)
+
+	filesOp, _, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = filesOp.Save("a.txt", "", []byte("old data"), nil)
+	require.NoError(t, err)
+	item, err := filesOp.Stat("a.txt", 0)
+	require.NoError(t, err)
+
+	rename = func(oldpath, newpath string) error {
+		if strings.HasPrefix(filepath.Base(newpath), metaPrefix) {
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EIO}
+		}
+		return os.Rename(oldpath, newpath)
+	}
+	defer func() { rename = os.Rename }()
+
+	_, err = filesOp.Save("a.txt", "", []byte("new data"), nil)
+	require.Error(t, err)
+
+	// the previous version is restored with its metadata
+	data, err := filesOp.Read("a.txt")
+	require.NoError(t, err)
+	require.Equal(t, "old data", string(data))
+
+	itemRestored, err := filesOp.Stat("a.txt", 0)
+	require.NoError(t, err)
+	require.Equal(t, item.Hash, itemRestored.Hash)
+
+	items, _, err := filesOp.List("", 0, nil)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+}
+
+func TestFilesFSListSkipsPreviousPages(t *testing.T) {
+	l = logger_test.New(t)
+
+	basePath := t.TempDir()
+	filesOp, _, err := New(basePath)
+	require.NoError(t, err)
+
+	for _, path := range []string{"a.txt", "b.txt", "c.txt"} {
+		_, err = filesOp.Save(path, "", []byte(path), nil)
+		require.NoError(t, err)
+	}
+
+	items, cursor, err := filesOp.List("", 0, &files.ListOptions{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, "a.txt", items[0].Path)
+
+	// the sidecar of the item on the previous page isn't read
+	require.NoError(t, os.WriteFile(metaPath(filepath.Join(basePath, "a.txt")), []byte("wrong json"), 0644))
+
+	items, _, err = filesOp.List("", 0, &files.ListOptions{Limit: 1, Cursor: cursor})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, "b.txt", items[0].Path)
+
+	_, _, err = filesOp.List("", 0, &files.ListOptions{Limit: 1})
+	require.Error(t, err)
+}
+
+func TestFilesFSCopySymlink(t *testing.T) {
+	l = logger_test.New(t)
+
+	basePath := t.TempDir()
+	filesOp, _, err := New(basePath)
+	require.NoError(t, err)
+
+	_, err = filesOp.Save("dir/a.txt", "", []byte("a"), nil)
+	require.NoError(t, err)
+	require.NoError(t, os.Symlink(filepath.Join(basePath, "dir", "a.txt"), filepath.Join(basePath, "dir", "link.txt")))
+
+	err = filesOp.Copy("dir", "dir_copy")
+	require.Error(t, err)
+	require.Equal(t, common.WrongPathKey, errors.Keyed(err), err)
+	_, err = os.Lstat(filepath.Join(basePath, "dir_copy"))
+	require.True(t, os.IsNotExist(err))
+
+	err = filesOp.Copy("dir/link.txt", "link_copy.txt")
+	require.Error(t, err)
+	require.Equal(t, common.WrongPathKey, errors.Keyed(err), err)
+
+	_, err = os.Lstat(filepath.Join(basePath, "link_copy.txt"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,171 @@
+package files_fs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+)
+
+// tempPrefix marks files that are written now, they aren't listed and are renamed to the target ones on fileWriter.Close()
+const tempPrefix = ".tmp_"
+
+func isTemp(name string) bool {
+	return strings.HasPrefix(name, tempPrefix)
+}
+
+var _ files.Writer = &fileWriter{}
+
+type fileWriter struct {
+	file           *os.File
+	basePath       string
+	dirPath        string
+	filename       string
+	newFilePattern string
+	meta           *files.Meta
+	digest         *files.Digest
+	path           string
+	finished       bool
+}
+
+const onWrite = "on fileWriter.Write()"
+
+func (fw *fileWriter) Write(p []byte) (int, error) {
+	if fw.finished {
+		return 0, fmt.Errorf(onWrite + ": the writer is closed already")
+	}
+
+	n, err := fw.file.Write(p)
+	fw.digest.Write(p[:n])
+	if err != nil {
+		return n, errors.Wrapf(err, onWrite+": can't file.Write(%s)", fw.file.Name())
+	}
+
+	return n, nil
+}
+
+const onClose = "on fileWriter.Close()"
+
+func (fw *fileWriter) Close() error {
+	if fw.finished {
+		return fmt.Errorf(onClose + ": the writer is closed already")
+	}
+	fw.finished = true
+
+	tempName := fw.file.Name()
+	err := fw.file.Sync()
+	if errClose := fw.file.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Chmod(tempName, 0644)
+	}
+	if err != nil {
+		os.Remove(tempName)
+		return errors.Wrapf(err, onClose+": can't finish the temporary file %s", tempName)
+	}
+
+	filePath := fw.dirPath + fw.filename
+	if fw.newFilePattern != "" {
+		// the random name is reserved with the empty file to be replaced atomically
+		file, err := ioutil.TempFile(fw.dirPath, fw.newFilePattern)
+		if err != nil {
+			os.Remove(tempName)
+			return errors.Wrapf(err, onClose+": can't ioutil.TempFile(%s, %s)", fw.dirPath, fw.newFilePattern)
+		}
+		filePath = file.Name()
+		file.Close()
+	}
+
+	// both the data and the metadata are written to temporary files before any of them replaces the previous version
+	meta := fileMeta{
+		CreatedAt: time.Now(),
+		MIMEType:  fw.digest.MIMEType(filePath, fw.meta),
+		Hash:      fw.digest.Hash(),
+	}
+	if fw.meta != nil {
+		meta.OriginalName, meta.Tags = fw.meta.OriginalName, fw.meta.Tags
+	}
+	metaTempName, err := writeMetaTemp(filepath.Dir(filePath), meta)
+	if err != nil {
+		os.Remove(tempName)
+		if fw.newFilePattern != "" {
+			os.Remove(filePath)
+		}
+		return errors.CommonError(err, onClose)
+	}
+
+	if err = replace(tempName, metaTempName, filePath); err != nil {
+		if fw.newFilePattern != "" {
+			os.Remove(filePath)
+		}
+		return errors.CommonError(err, onClose)
+	}
+
+	filePath = strings.ReplaceAll(filePath, "/./", "/")
+	if len(filePath) <= len(fw.basePath) {
+		return fmt.Errorf(onClose+": wrong filename (%s) on basePath = '%s'", filePath, fw.basePath)
+	}
+	fw.path = filePath[len(fw.basePath):]
+
+	return nil
+}
+
+// replace renames the temporary data and metadata files to filePath and its sidecar, the previous version of the data
+// is restored (if it's possible) if the metadata can't be renamed, so the sidecar always describes the data
+func replace(tempName, metaTempName, filePath string) error {
+	// the previous version is kept with the hard link until the metadata is renamed
+	backupName := tempName + "_backup"
+	if err := os.Link(filePath, backupName); err != nil {
+		backupName = ""
+	}
+	defer func() {
+		if backupName != "" {
+			os.Remove(backupName)
+		}
+	}()
+
+	if err := rename(tempName, filePath); err != nil {
+		os.Remove(tempName)
+		os.Remove(metaTempName)
+		return errors.Wrapf(err, "can't os.Rename(%s, %s)", tempName, filePath)
+	}
+
+	if err := rename(metaTempName, metaPath(filePath)); err != nil {
+		os.Remove(metaTempName)
+		if backupName != "" && rename(backupName, filePath) == nil {
+			backupName = ""
+		} else {
+			os.Remove(filePath)
+		}
+		return errors.Wrapf(err, "can't os.Rename(%s, %s)", metaTempName, metaPath(filePath))
+	}
+
+	return nil
+}
+
+const onAbort = "on fileWriter.Abort()"
+
+func (fw *fileWriter) Abort() error {
+	if fw.finished {
+		return nil
+	}
+	fw.finished = true
+
+	tempName := fw.file.Name()
+	fw.file.Close()
+	if err := os.Remove(tempName); err != nil {
+		return errors.Wrapf(err, onAbort+": can't os.Remove(%s)", tempName)
+	}
+
+	return nil
+}
+
+func (fw *fileWriter) Path() string {
+	return fw.path
+}
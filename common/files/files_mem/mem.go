@@ -0,0 +1,317 @@
+package files_mem
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/filelib"
+	"github.com/pavlo67/common/common/files"
+)
+
+var _ files.Operator = &filesMem{}
+var _ files.Tagger = &filesMem{}
+
+type fileMem struct {
+	data       []byte
+	createdAt  time.Time
+	modifiedAt time.Time
+	meta       files.Meta // MIMEType is the detected one
+	hash       string
+}
+
+// filesMem keeps files and directories by their cleaned paths relative to the root ("" is the root itself)
+type filesMem struct {
+	files map[string]*fileMem
+	dirs  map[string]time.Time
+
+	mutex sync.RWMutex
+}
+
+func New() (files.Operator, files.Cleaner, error) {
+	filesOp := filesMem{
+		files: map[string]*fileMem{},
+		dirs:  map[string]time.Time{},
+	}
+
+	return &filesOp, &filesOp, nil
+}
+
+func (filesOp *filesMem) Save(path, newFilePattern string, data []byte, meta *files.Meta) (string, error) {
+	return files.SaveData(filesOp, path, newFilePattern, data, meta)
+}
+
+func (filesOp *filesMem) Read(path string) ([]byte, error) {
+	return files.ReadData(filesOp, path)
+}
+
+const onOpen = "on filesMem.Open()"
+
+func (filesOp *filesMem) Open(path string) (io.ReadCloser, *files.Item, error) {
+	filePath, err := filelib.CleanPath(path)
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onOpen)
+	}
+
+	filesOp.mutex.RLock()
+	defer filesOp.mutex.RUnlock()
+
+	file := filesOp.files[filePath]
+	if file == nil {
+		if _, ok := filesOp.dirs[filePath]; ok || filePath == "" {
+			return nil, nil, fmt.Errorf(onOpen+": %s is a directory", path)
+		}
+		return nil, nil, errors.Wrap(files.NotExist("open", path), onOpen)
+	}
+
+	// file.data is never changed (it's replaced with the new slice on saving), so it can be read without the lock
+	return ioutil.NopCloser(bytes.NewReader(file.data)), file.item(filePath), nil
+}
+
+const onCreate = "on filesMem.Create()"
+
+func (filesOp *filesMem) Create(path, newFilePattern string, meta *files.Meta) (files.Writer, error) {
+	filePath, err := filelib.CleanPath(path)
+	if err != nil {
+		return nil, errors.CommonError(err, onCreate)
+	} else if newFilePattern == "" && filePath == "" {
+		return nil, errors.CommonError(common.WrongPathKey, common.Map{"path": path}, onCreate+": the root can't be a file")
+	} else if strings.Contains(newFilePattern, "/") {
+		return nil, errors.CommonError(common.WrongPathKey, common.Map{"pattern": newFilePattern}, onCreate+": wrong file pattern")
+	}
+
+	dirPath := filePath
+	if newFilePattern == "" {
+		dirPath = files.Parent(filePath)
+	}
+
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	if err = filesOp.makeDir(dirPath); err != nil {
+		return nil, errors.CommonError(err, onCreate)
+	}
+
+	var metaCopied *files.Meta
+	if meta != nil {
+		metaCopied = &files.Meta{OriginalName: meta.OriginalName, MIMEType: meta.MIMEType, Tags: copyTags(meta.Tags)}
+	}
+
+	return &fileWriter{
+		filesOp:        filesOp,
+		dirPath:        dirPath,
+		filePath:       filePath,
+		newFilePattern: newFilePattern,
+		meta:           metaCopied,
+		digest:         files.NewDigest(),
+	}, nil
+}
+
+const onRemove = "on filesMem.Remove()"
+
+// Remove removes the file or the empty directory
+func (filesOp *filesMem) Remove(path string) error {
+	filePath, err := filelib.CleanPath(path)
+	if err != nil {
+		return errors.CommonError(err, onRemove)
+	}
+
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	if _, ok := filesOp.files[filePath]; ok {
+		delete(filesOp.files, filePath)
+		return nil
+	} else if _, ok = filesOp.dirs[filePath]; !ok {
+		return errors.Wrap(files.NotExist("remove", path), onRemove)
+	} else if len(filesOp.below(filePath, -1)) > 0 {
+		return fmt.Errorf(onRemove+": directory %s isn't empty", path)
+	}
+
+	delete(filesOp.dirs, filePath)
+	return nil
+}
+
+const onList = "on filesMem.List()"
+
+func (filesOp *filesMem) List(path string, depth int, options *files.ListOptions) (files.Items, string, error) {
+	if err := files.CheckListOptions(options); err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	dirPath, err := filelib.CleanPath(path)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	filesOp.mutex.RLock()
+	defer filesOp.mutex.RUnlock()
+
+	if _, ok := filesOp.dirs[dirPath]; !ok && dirPath != "" {
+		if _, ok = filesOp.files[dirPath]; ok {
+			return nil, "", fmt.Errorf(onList+": %s isn't a directory", path)
+		}
+		return nil, "", errors.Wrap(files.NotExist("list", path), onList)
+	}
+
+	var items files.Items
+	for _, subPath := range filesOp.below(dirPath, depth) {
+		items = append(items, *filesOp.item(subPath))
+	}
+
+	items, cursor, err := items.Filter(options).Page(options)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	return items, cursor, nil
+}
+
+const onStat = "on filesMem.Stat()"
+
+func (filesOp *filesMem) Stat(path string, depth int) (*files.Item, error) {
+	filePath, err := filelib.CleanPath(path)
+	if err != nil {
+		return nil, errors.CommonError(err, onStat)
+	}
+
+	filesOp.mutex.RLock()
+	defer filesOp.mutex.RUnlock()
+
+	item := filesOp.item(filePath)
+	if item == nil {
+		return nil, errors.Wrap(files.NotExist("stat", path), onStat)
+	}
+
+	if depth != 0 && item.IsDir {
+		for _, subPath := range filesOp.below(filePath, depth) {
+			if file := filesOp.files[subPath]; file != nil {
+				item.Size += int64(len(file.data))
+			}
+		}
+	}
+
+	return item, nil
+}
+
+const onSetTags = "on filesMem.SetTags()"
+
+func (filesOp *filesMem) SetTags(path string, tags files.Tags) error {
+	filePath, err := filelib.CleanPath(path)
+	if err != nil {
+		return errors.CommonError(err, onSetTags)
+	}
+
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	file := filesOp.files[filePath]
+	if file == nil {
+		if _, ok := filesOp.dirs[filePath]; ok || filePath == "" {
+			return fmt.Errorf(onSetTags+": %s is a directory", path)
+		}
+		return errors.Wrap(files.NotExist("set tags", path), onSetTags)
+	}
+
+	fileTagged := *file
+	fileTagged.meta.Tags = copyTags(tags)
+	filesOp.files[filePath] = &fileTagged
+
+	return nil
+}
+
+// helpers (they should be called under the lock) ---------------------------------------------------------------------
+
+func copyTags(tags files.Tags) files.Tags {
+	if tags == nil {
+		return nil
+	}
+	tagsCopied := files.Tags{}
+	for k, v := range tags {
+		tagsCopied[k] = v
+	}
+	return tagsCopied
+}
+
+// makeDir creates the directory with all its parents (if they don't exist)
+func (filesOp *filesMem) makeDir(dirPath string) error {
+	var toCreate []string
+	for ; dirPath != ""; dirPath = files.Parent(dirPath) {
+		if _, ok := filesOp.files[dirPath]; ok {
+			return fmt.Errorf("%s is a file, not a directory", dirPath)
+		} else if _, ok = filesOp.dirs[dirPath]; ok {
+			break
+		}
+		toCreate = append(toCreate, dirPath)
+	}
+
+	now := time.Now()
+	for _, dirPath := range toCreate {
+		filesOp.dirs[dirPath] = now
+	}
+
+	return nil
+}
+
+// below returns sorted paths of files and directories inside dirPath with the nesting level up to depth (all levels
+// if depth < 0)
+func (filesOp *filesMem) below(dirPath string, depth int) []string {
+	prefix := dirPath
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var subPaths []string
+	add := func(subPath string) {
+		if strings.HasPrefix(subPath, prefix) && subPath != dirPath && (depth < 0 || strings.Count(subPath[len(prefix):], "/") <= depth) {
+			subPaths = append(subPaths, subPath)
+		}
+	}
+	for subPath := range filesOp.dirs {
+		add(subPath)
+	}
+	for subPath := range filesOp.files {
+		add(subPath)
+	}
+	sort.Strings(subPaths)
+
+	return subPaths
+}
+
+// item returns nil if there is no such file or directory
+func (filesOp *filesMem) item(filePath string) *files.Item {
+	if file := filesOp.files[filePath]; file != nil {
+		return file.item(filePath)
+	}
+
+	createdAt, ok := filesOp.dirs[filePath]
+	if !ok && filePath != "" {
+		return nil
+	}
+
+	itemPath := filePath
+	if itemPath != "" {
+		itemPath += "/"
+	}
+	return &files.Item{Path: itemPath, IsDir: true, CreatedAt: createdAt, ModifiedAt: createdAt}
+}
+
+func (file *fileMem) item(filePath string) *files.Item {
+	return &files.Item{
+		Path:         filePath,
+		Size:         int64(len(file.data)),
+		CreatedAt:    file.createdAt,
+		ModifiedAt:   file.modifiedAt,
+		MIMEType:     file.meta.MIMEType,
+		Hash:         file.hash,
+		OriginalName: file.meta.OriginalName,
+		Tags:         copyTags(file.meta.Tags),
+	}
+}
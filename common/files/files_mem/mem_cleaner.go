@@ -0,0 +1,57 @@
+package files_mem
+
+import (
+	"time"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/selectors"
+)
+
+var _ files.Cleaner = &filesMem{}
+
+const onClean = "on filesMem.Clean()"
+
+func (filesOp *filesMem) Clean(term *selectors.Term) error {
+	if _, err := filesOp.CleanItems(term, false); err != nil {
+		return errors.CommonError(err, onClean)
+	}
+
+	return nil
+}
+
+const onCleanItems = "on filesMem.CleanItems()"
+
+func (filesOp *filesMem) CleanItems(term *selectors.Term, dryRun bool) (files.Items, error) {
+	selected, err := files.Selected(term)
+	if err != nil {
+		return nil, errors.CommonError(err, onCleanItems)
+	}
+
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	var items files.Items
+	for _, filePath := range filesOp.below("", -1) {
+		if file := filesOp.files[filePath]; file != nil {
+			if item := file.item(filePath); selected(*item) {
+				items = append(items, *item)
+			}
+		}
+	}
+
+	if dryRun {
+		return items, nil
+	}
+
+	if term == nil {
+		filesOp.files, filesOp.dirs = map[string]*fileMem{}, map[string]time.Time{}
+		return items, nil
+	}
+
+	for _, item := range items {
+		delete(filesOp.files, item.Path)
+	}
+
+	return items, nil
+}
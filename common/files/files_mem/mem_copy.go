@@ -0,0 +1,125 @@
+package files_mem
+
+import (
+	"strings"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/filelib"
+	"github.com/pavlo67/common/common/files"
+)
+
+const onRemoveAll = "on filesMem.RemoveAll()"
+
+func (filesOp *filesMem) RemoveAll(path string) error {
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	filePath, err := filesOp.existing(path)
+	if err != nil {
+		return errors.CommonError(err, onRemoveAll)
+	}
+
+	filesOp.removeAll(filePath)
+	return nil
+}
+
+const onCopy = "on filesMem.Copy()"
+
+func (filesOp *filesMem) Copy(from, to string) error {
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	fromPath, toPath, err := filesOp.fromTo(from, to)
+	if err != nil {
+		return errors.CommonError(err, onCopy)
+	}
+
+	filesOp.copyAll(fromPath, toPath)
+	return nil
+}
+
+const onMove = "on filesMem.Move()"
+
+func (filesOp *filesMem) Move(from, to string) error {
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	fromPath, toPath, err := filesOp.fromTo(from, to)
+	if err != nil {
+		return errors.CommonError(err, onMove)
+	}
+
+	filesOp.copyAll(fromPath, toPath)
+	filesOp.removeAll(fromPath)
+	return nil
+}
+
+// helpers (they should be called under the lock) ---------------------------------------------------------------------
+
+// existing returns the cleaned path of the existing file or directory (but not the root)
+func (filesOp *filesMem) existing(path string) (string, error) {
+	filePath, err := filelib.CleanPath(path)
+	if err != nil {
+		return "", err
+	} else if filePath == "" {
+		return "", errors.CommonError(common.WrongPathKey, common.Map{"path": path}, "the root can't be used")
+	} else if filesOp.item(filePath) == nil {
+		return "", errors.CommonError(common.NotFoundKey, common.Map{"path": path}, "no such file or directory")
+	}
+
+	return filePath, nil
+}
+
+// fromTo checks the source and the target for Copy() and Move(), the target directory is created if it's necessary
+func (filesOp *filesMem) fromTo(from, to string) (string, string, error) {
+	fromPath, err := filesOp.existing(from)
+	if err != nil {
+		return "", "", err
+	}
+
+	toPath, err := filelib.CleanPath(to)
+	if err != nil {
+		return "", "", err
+	} else if toPath == "" {
+		return "", "", errors.CommonError(common.WrongPathKey, common.Map{"path": to}, "the root can't be used")
+	} else if toPath == fromPath || strings.HasPrefix(toPath, fromPath+"/") {
+		return "", "", errors.CommonError(common.WrongPathKey, common.Map{"from": from, "to": to}, "the target is inside the source")
+	}
+
+	_, fromIsDir := filesOp.dirs[fromPath]
+	_, toIsDir := filesOp.dirs[toPath]
+	_, toIsFile := filesOp.files[toPath]
+	if toIsDir || (fromIsDir && toIsFile) {
+		return "", "", errors.CommonError(common.WrongPathKey, common.Map{"path": to}, "the target directory exists already")
+	}
+
+	if err = filesOp.makeDir(files.Parent(toPath)); err != nil {
+		return "", "", errors.Wrapf(err, "can't create the target directory for %s", to)
+	}
+
+	return fromPath, toPath, nil
+}
+
+func (filesOp *filesMem) copyAll(fromPath, toPath string) {
+	if file := filesOp.files[fromPath]; file != nil {
+		fileCopied := *file
+		fileCopied.meta.Tags = copyTags(file.meta.Tags)
+		filesOp.files[toPath] = &fileCopied
+		return
+	}
+
+	filesOp.dirs[toPath] = filesOp.dirs[fromPath]
+	for _, subPath := range filesOp.below(fromPath, -1) {
+		filesOp.copyAll(subPath, toPath+subPath[len(fromPath):])
+	}
+}
+
+func (filesOp *filesMem) removeAll(filePath string) {
+	for _, subPath := range filesOp.below(filePath, -1) {
+		delete(filesOp.files, subPath)
+		delete(filesOp.dirs, subPath)
+	}
+	delete(filesOp.files, filePath)
+	delete(filesOp.dirs, filePath)
+}
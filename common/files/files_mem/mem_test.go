@@ -0,0 +1,26 @@
+package files_mem
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/logger/logger_test"
+	"github.com/pavlo67/common/common/starter"
+
+	"github.com/pavlo67/common/common/files"
+)
+
+func TestFilesMem(t *testing.T) {
+	components := []starter.Starter{
+		{Operator: Starter(), Options: common.Map{}},
+	}
+
+	joinerOp, err := starter.Run(components, nil, "CLI BUILD FOR TEST", logger_test.New(t))
+	require.NoError(t, err)
+	require.NotNil(t, joinerOp)
+	defer joinerOp.CloseAll()
+
+	files.FilesTestScenario(t, joinerOp, files.InterfaceKey, files.InterfaceKeyCleaner)
+}
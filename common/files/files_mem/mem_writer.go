@@ -0,0 +1,95 @@
+package files_mem
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+)
+
+var _ files.Writer = &fileWriter{}
+
+type fileWriter struct {
+	filesOp        *filesMem
+	dirPath        string
+	filePath       string
+	newFilePattern string
+	meta           *files.Meta
+	digest         *files.Digest
+	buffer         bytes.Buffer
+	path           string
+	finished       bool
+}
+
+const onWrite = "on fileWriter.Write()"
+
+func (fw *fileWriter) Write(p []byte) (int, error) {
+	if fw.finished {
+		return 0, fmt.Errorf(onWrite + ": the writer is closed already")
+	}
+
+	fw.digest.Write(p)
+	return fw.buffer.Write(p)
+}
+
+const onClose = "on fileWriter.Close()"
+
+func (fw *fileWriter) Close() error {
+	if fw.finished {
+		return fmt.Errorf(onClose + ": the writer is closed already")
+	}
+	fw.finished = true
+
+	filesOp := fw.filesOp
+	filesOp.mutex.Lock()
+	defer filesOp.mutex.Unlock()
+
+	// the directory could be removed after Create()
+	if err := filesOp.makeDir(fw.dirPath); err != nil {
+		return errors.CommonError(err, onClose)
+	}
+
+	filePath := fw.filePath
+	if fw.newFilePattern != "" {
+		for {
+			filePath = path.Join(fw.dirPath, files.RandomName(fw.newFilePattern))
+			if _, ok := filesOp.files[filePath]; !ok {
+				if _, ok = filesOp.dirs[filePath]; !ok {
+					break
+				}
+			}
+		}
+	} else if _, ok := filesOp.dirs[filePath]; ok {
+		return fmt.Errorf(onClose+": %s is a directory", filePath)
+	}
+
+	file := fileMem{
+		data:      fw.buffer.Bytes(),
+		createdAt: time.Now(),
+		hash:      fw.digest.Hash(),
+	}
+	file.modifiedAt = file.createdAt
+	if fw.meta != nil {
+		file.meta = *fw.meta
+	}
+	file.meta.MIMEType = fw.digest.MIMEType(filePath, fw.meta)
+
+	filesOp.files[filePath] = &file
+	fw.path = filePath
+
+	return nil
+}
+
+func (fw *fileWriter) Abort() error {
+	fw.finished = true
+	fw.buffer = bytes.Buffer{}
+
+	return nil
+}
+
+func (fw *fileWriter) Path() string {
+	return fw.path
+}
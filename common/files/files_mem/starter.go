@@ -0,0 +1,57 @@
+package files_mem
+
+import (
+	"fmt"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/config"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/joiner"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/starter"
+)
+
+func Starter() starter.Operator {
+	return &filesMemStarter{}
+}
+
+var l logger.Operator
+var _ starter.Operator = &filesMemStarter{}
+
+type filesMemStarter struct {
+	interfaceKey joiner.InterfaceKey
+	cleanerKey   joiner.InterfaceKey
+}
+
+func (fms *filesMemStarter) Name() string {
+	return logger.GetCallInfo().PackageName
+}
+
+func (fms *filesMemStarter) Prepare(cfg *config.Config, options common.Map) error {
+	fms.interfaceKey = joiner.InterfaceKey(options.StringDefault("interface_key", string(files.InterfaceKey)))
+	fms.cleanerKey = joiner.InterfaceKey(options.StringDefault("cleaner_key", string(files.InterfaceKeyCleaner)))
+
+	return nil
+}
+
+func (fms *filesMemStarter) Run(joinerOp joiner.Operator) error {
+	if l, _ = joinerOp.Interface(logger.InterfaceKey).(logger.Operator); l == nil {
+		return fmt.Errorf("no logger.Operator with key %s", logger.InterfaceKey)
+	}
+
+	filesOp, filesCleanerOp, err := New()
+	if err != nil {
+		return errors.Wrap(err, "can't init *filesMem{} as files.Operator")
+	}
+
+	if err = joinerOp.Join(filesOp, fms.interfaceKey); err != nil {
+		return errors.Wrapf(err, "can't join *filesMem{} as files.Operator with key '%s'", fms.interfaceKey)
+	}
+
+	if err = joinerOp.Join(filesCleanerOp, fms.cleanerKey); err != nil {
+		return errors.Wrapf(err, "can't join *filesMem{} as db.Cleaner with key '%s'", fms.cleanerKey)
+	}
+
+	return nil
+}
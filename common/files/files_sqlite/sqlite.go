@@ -0,0 +1,441 @@
+package files_sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/filelib"
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/health"
+	"github.com/pavlo67/common/common/sqllib"
+)
+
+const DefaultTable = "files"
+const DefaultChunkSize = 1 << 20
+
+var _ files.Operator = &filesSQLite{}
+var _ files.Tagger = &filesSQLite{}
+
+// filesSQLite keeps files and directories (by their cleaned paths relative to the root, the root itself isn't kept) in
+// the table and file data in the chunks table. Files are written as pending ones (with NULL path) and they become
+// available in one transaction on fileWriter.Close().
+type filesSQLite struct {
+	db          *sql.DB
+	table       string
+	tableChunks string
+	chunkSize   int
+}
+
+var reTable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+const onNew = "on files_sqlite.New()"
+
+// New creates tables (if they don't exist) for files and their data chunks (the chunks table is named table + "_chunks")
+func New(db *sql.DB, table string, chunkSize int) (files.Operator, files.Cleaner, error) {
+	if db == nil {
+		return nil, nil, errors.New(onNew + ": no *sql.DB")
+	}
+	if table == "" {
+		table = DefaultTable
+	} else if !reTable.MatchString(table) {
+		return nil, nil, fmt.Errorf(onNew+": wrong table name (%s)", table)
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	filesOp := filesSQLite{db: db, table: table, tableChunks: table + "_chunks", chunkSize: chunkSize}
+
+	for _, sqlCreate := range []string{
+		"CREATE TABLE IF NOT EXISTS " + filesOp.table + ` (
+			id            INTEGER PRIMARY KEY AUTOINCREMENT,
+			path          TEXT    UNIQUE,
+			is_dir        INTEGER NOT NULL DEFAULT 0,
+			size          INTEGER NOT NULL DEFAULT 0,
+			created_at    INTEGER NOT NULL,
+			modified_at   INTEGER NOT NULL,
+			mime_type     TEXT    NOT NULL DEFAULT '',
+			hash          TEXT    NOT NULL DEFAULT '',
+			original_name TEXT    NOT NULL DEFAULT '',
+			tags          TEXT    NOT NULL DEFAULT ''
+		)`,
+		"CREATE TABLE IF NOT EXISTS " + filesOp.tableChunks + ` (
+			file_id INTEGER NOT NULL,
+			n       INTEGER NOT NULL,
+			data    BLOB    NOT NULL,
+			PRIMARY KEY (file_id, n)
+		)`,
+	} {
+		if _, err := sqllib.Exec(db, sqlCreate); err != nil {
+			return nil, nil, errors.CommonError(err, onNew)
+		}
+	}
+
+	return &filesOp, &filesOp, nil
+}
+
+func (filesOp *filesSQLite) Save(path, newFilePattern string, data []byte, meta *files.Meta) (string, error) {
+	return files.SaveData(filesOp, path, newFilePattern, data, meta)
+}
+
+func (filesOp *filesSQLite) Read(path string) ([]byte, error) {
+	return files.ReadData(filesOp, path)
+}
+
+const onOpen = "on filesSQLite.Open()"
+
+func (filesOp *filesSQLite) Open(path string) (io.ReadCloser, *files.Item, error) {
+	filePath, err := filelib.CleanPath(path)
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onOpen)
+	}
+
+	id, item, err := filesOp.item(filesOp.db, filePath)
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onOpen)
+	} else if item == nil {
+		return nil, nil, errors.Wrap(files.NotExist("open", path), onOpen)
+	} else if item.IsDir {
+		return nil, nil, fmt.Errorf(onOpen+": %s is a directory", path)
+	}
+
+	return &fileReader{filesOp: filesOp, fileID: id, size: item.Size}, item, nil
+}
+
+const onCreate = "on filesSQLite.Create()"
+
+func (filesOp *filesSQLite) Create(path, newFilePattern string, meta *files.Meta) (files.Writer, error) {
+	filePath, err := filelib.CleanPath(path)
+	if err != nil {
+		return nil, errors.CommonError(err, onCreate)
+	} else if newFilePattern == "" && filePath == "" {
+		return nil, errors.CommonError(common.WrongPathKey, common.Map{"path": path}, onCreate+": the root can't be a file")
+	} else if strings.Contains(newFilePattern, "/") {
+		return nil, errors.CommonError(common.WrongPathKey, common.Map{"pattern": newFilePattern}, onCreate+": wrong file pattern")
+	}
+
+	dirPath := filePath
+	if newFilePattern == "" {
+		dirPath = files.Parent(filePath)
+	}
+
+	// the pending file isn't available until fileWriter.Close()
+	now := time.Now().UnixNano()
+	sqlInsert := "INSERT INTO " + filesOp.table + " (path, created_at, modified_at) VALUES (NULL, ?, ?)"
+	res, err := sqllib.Exec(filesOp.db, sqlInsert, now, now)
+	if err != nil {
+		return nil, errors.CommonError(err, onCreate)
+	}
+	id, err := (*res).LastInsertId()
+	if err != nil {
+		return nil, errors.Wrapf(err, onCreate+": "+sqllib.CantGetLastInsertId, sqlInsert, now)
+	}
+
+	return &fileWriter{
+		filesOp:        filesOp,
+		fileID:         id,
+		dirPath:        dirPath,
+		filePath:       filePath,
+		newFilePattern: newFilePattern,
+		meta:           meta,
+		digest:         files.NewDigest(),
+	}, nil
+}
+
+const onRemove = "on filesSQLite.Remove()"
+
+// Remove removes the file or the empty directory
+func (filesOp *filesSQLite) Remove(path string) error {
+	filePath, err := filelib.CleanPath(path)
+	if err != nil {
+		return errors.CommonError(err, onRemove)
+	}
+
+	if err = filesOp.transaction(func(tx *sql.Tx) error {
+		id, item, err := filesOp.item(tx, filePath)
+		if err != nil {
+			return err
+		} else if item == nil {
+			return files.NotExist("remove", path)
+		} else if item.IsDir {
+			var count int
+			lower, upper := below(filePath)
+			sqlCount := "SELECT COUNT(*) FROM " + filesOp.table + " WHERE path >= ? AND path < ?"
+			if err = tx.QueryRow(sqlCount, lower, upper).Scan(&count); err != nil {
+				return errors.Wrapf(err, sqllib.CantScanQueryRow, sqlCount, lower)
+			} else if count > 0 {
+				return fmt.Errorf("directory %s isn't empty", path)
+			}
+		}
+
+		return filesOp.remove(tx, "id = ?", id)
+	}); err != nil {
+		return errors.CommonError(err, onRemove)
+	}
+
+	return nil
+}
+
+const onList = "on filesSQLite.List()"
+
+func (filesOp *filesSQLite) List(path string, depth int, options *files.ListOptions) (files.Items, string, error) {
+	if err := files.CheckListOptions(options); err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	dirPath, err := filelib.CleanPath(path)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	_, item, err := filesOp.item(filesOp.db, dirPath)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	} else if item == nil {
+		return nil, "", errors.Wrap(files.NotExist("list", path), onList)
+	} else if !item.IsDir {
+		return nil, "", fmt.Errorf(onList+": %s isn't a directory", path)
+	}
+
+	lower, upper := below(dirPath)
+	itemsBelow, err := filesOp.items(filesOp.db, "path >= ? AND path < ?", lower, upper)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	var items files.Items
+	for _, item := range itemsBelow {
+		if depth < 0 || strings.Count(strings.TrimSuffix(item.Path, "/")[len(lower):], "/") <= depth {
+			items = append(items, item)
+		}
+	}
+
+	items, cursor, err := items.Filter(options).Page(options)
+	if err != nil {
+		return nil, "", errors.CommonError(err, onList)
+	}
+
+	return items, cursor, nil
+}
+
+const onStat = "on filesSQLite.Stat()"
+
+func (filesOp *filesSQLite) Stat(path string, depth int) (*files.Item, error) {
+	filePath, err := filelib.CleanPath(path)
+	if err != nil {
+		return nil, errors.CommonError(err, onStat)
+	}
+
+	_, item, err := filesOp.item(filesOp.db, filePath)
+	if err != nil {
+		return nil, errors.CommonError(err, onStat)
+	} else if item == nil {
+		return nil, errors.Wrap(files.NotExist("stat", path), onStat)
+	}
+
+	if depth < 0 && item.IsDir {
+		lower, upper := below(filePath)
+		sqlSum := "SELECT COALESCE(SUM(size), 0) FROM " + filesOp.table + " WHERE path >= ? AND path < ? AND is_dir = 0"
+		if err = filesOp.db.QueryRow(sqlSum, lower, upper).Scan(&item.Size); err != nil {
+			return nil, errors.Wrapf(err, onStat+": "+sqllib.CantScanQueryRow, sqlSum, lower)
+		}
+	} else if depth > 0 && item.IsDir {
+		lower, upper := below(filePath)
+		itemsBelow, err := filesOp.items(filesOp.db, "path >= ? AND path < ? AND is_dir = 0", lower, upper)
+		if err != nil {
+			return nil, errors.CommonError(err, onStat)
+		}
+		for _, itemBelow := range itemsBelow {
+			if strings.Count(itemBelow.Path[len(lower):], "/") <= depth {
+				item.Size += itemBelow.Size
+			}
+		}
+	}
+
+	return item, nil
+}
+
+const onSetTags = "on filesSQLite.SetTags()"
+
+func (filesOp *filesSQLite) SetTags(path string, tags files.Tags) error {
+	filePath, err := filelib.CleanPath(path)
+	if err != nil {
+		return errors.CommonError(err, onSetTags)
+	}
+
+	var tagsJSON []byte
+	if len(tags) > 0 {
+		if tagsJSON, err = json.Marshal(tags); err != nil {
+			return errors.Wrapf(err, onSetTags+": can't marshal tags (%#v)", tags)
+		}
+	}
+
+	sqlUpdate := "UPDATE " + filesOp.table + " SET tags = ? WHERE path = ? AND is_dir = 0"
+	res, err := filesOp.db.Exec(sqlUpdate, string(tagsJSON), filePath)
+	if err != nil {
+		return errors.Wrapf(err, onSetTags+": "+sqllib.CantExec, sqlUpdate, filePath)
+	}
+	if rowsAffected, err := res.RowsAffected(); err != nil {
+		return errors.Wrapf(err, onSetTags+": "+sqllib.CantGetRowsAffected, sqlUpdate, filePath)
+	} else if rowsAffected < 1 {
+		return errors.Wrap(files.NotExist("set tags", path), onSetTags)
+	}
+
+	return nil
+}
+
+var _ health.HealthChecker = &filesSQLite{}
+
+func (filesOp *filesSQLite) HealthCheck(ctx context.Context) error {
+	return filesOp.db.PingContext(ctx)
+}
+
+// helpers ------------------------------------------------------------------------------------------------------------
+
+// below returns the range of paths inside the directory: lower <= path < upper
+func below(dirPath string) (lower, upper string) {
+	if dirPath == "" {
+		return "", "\U0010FFFF"
+	}
+
+	// '0' follows '/'
+	return dirPath + "/", dirPath + "0"
+}
+
+// queryer is *sql.DB or *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+const fieldsToRead = "id, path, is_dir, size, created_at, modified_at, mime_type, hash, original_name, tags"
+
+func scanItem(scanner interface{ Scan(...interface{}) error }) (int64, *files.Item, error) {
+	var id, createdAt, modifiedAt int64
+	var isDir bool
+	var tags string
+	var item files.Item
+	if err := scanner.Scan(&id, &item.Path, &isDir, &item.Size, &createdAt, &modifiedAt, &item.MIMEType, &item.Hash, &item.OriginalName, &tags); err != nil {
+		return 0, nil, err
+	}
+
+	item.CreatedAt, item.ModifiedAt = time.Unix(0, createdAt), time.Unix(0, modifiedAt)
+	if item.IsDir = isDir; item.IsDir {
+		item.Path += "/"
+	}
+	if tags != "" {
+		if err := json.Unmarshal([]byte(tags), &item.Tags); err != nil {
+			return 0, nil, errors.Wrapf(err, "can't unmarshal tags (%s)", tags)
+		}
+	}
+
+	return id, &item, nil
+}
+
+// item returns nil item if there is no such file or directory (the root always exists)
+func (filesOp *filesSQLite) item(q queryer, filePath string) (int64, *files.Item, error) {
+	if filePath == "" {
+		return 0, &files.Item{IsDir: true}, nil
+	}
+
+	sqlSelect := "SELECT " + fieldsToRead + " FROM " + filesOp.table + " WHERE path = ?"
+	id, item, err := scanItem(q.QueryRow(sqlSelect, filePath))
+	if err == sql.ErrNoRows {
+		return 0, nil, nil
+	} else if err != nil {
+		return 0, nil, errors.Wrapf(err, sqllib.CantScanQueryRow, sqlSelect, filePath)
+	}
+
+	return id, item, nil
+}
+
+// items returns items sorted by path
+func (filesOp *filesSQLite) items(q queryer, condition string, values ...interface{}) (files.Items, error) {
+	sqlSelect := "SELECT " + fieldsToRead + " FROM " + filesOp.table + " WHERE path IS NOT NULL AND " + condition + " ORDER BY path"
+	rows, err := q.Query(sqlSelect, values...)
+	if err != nil {
+		return nil, errors.Wrapf(err, sqllib.CantQuery, sqlSelect, values)
+	}
+	defer rows.Close()
+
+	var items files.Items
+	for rows.Next() {
+		_, item, err := scanItem(rows)
+		if err != nil {
+			return nil, errors.Wrapf(err, sqllib.CantScanQueryRow, sqlSelect, values)
+		}
+		items = append(items, *item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, sqllib.RowsError, sqlSelect, values)
+	}
+
+	return items, nil
+}
+
+// remove deletes the rows selected with the condition and their chunks
+func (filesOp *filesSQLite) remove(q queryer, condition string, values ...interface{}) error {
+	sqlDeleteChunks := "DELETE FROM " + filesOp.tableChunks + " WHERE file_id IN (SELECT id FROM " + filesOp.table + " WHERE " + condition + ")"
+	if _, err := q.Exec(sqlDeleteChunks, values...); err != nil {
+		return errors.Wrapf(err, sqllib.CantExec, sqlDeleteChunks, values)
+	}
+
+	sqlDelete := "DELETE FROM " + filesOp.table + " WHERE " + condition
+	if _, err := q.Exec(sqlDelete, values...); err != nil {
+		return errors.Wrapf(err, sqllib.CantExec, sqlDelete, values)
+	}
+
+	return nil
+}
+
+// makeDir creates the directory with all its parents (if they don't exist)
+func (filesOp *filesSQLite) makeDir(tx *sql.Tx, dirPath string) error {
+	now := time.Now().UnixNano()
+	for ; dirPath != ""; dirPath = files.Parent(dirPath) {
+		_, item, err := filesOp.item(tx, dirPath)
+		if err != nil {
+			return err
+		} else if item != nil {
+			if !item.IsDir {
+				return fmt.Errorf("%s is a file, not a directory", dirPath)
+			}
+			break
+		}
+
+		sqlInsert := "INSERT INTO " + filesOp.table + " (path, is_dir, created_at, modified_at) VALUES (?, 1, ?, ?)"
+		if _, err = tx.Exec(sqlInsert, dirPath, now, now); err != nil {
+			return errors.Wrapf(err, sqllib.CantExec, sqlInsert, dirPath)
+		}
+	}
+
+	return nil
+}
+
+func (filesOp *filesSQLite) transaction(do func(tx *sql.Tx) error) error {
+	tx, err := filesOp.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "can't db.Begin()")
+	}
+
+	if err = do(tx); err != nil {
+		if errRollback := tx.Rollback(); errRollback != nil {
+			return errors.CommonError(err, errRollback)
+		}
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "can't tx.Commit()")
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package files_sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/selectors"
+	"github.com/pavlo67/common/common/sqllib"
+)
+
+var _ files.Cleaner = &filesSQLite{}
+
+// PendingMaxAge is the age of pending files (being written, with NULL path) CleanItems() removes as left by failed writers
+var PendingMaxAge = 24 * time.Hour
+
+const onClean = "on filesSQLite.Clean()"
+
+func (filesOp *filesSQLite) Clean(term *selectors.Term) error {
+	if _, err := filesOp.CleanItems(term, false); err != nil {
+		return errors.CommonError(err, onClean)
+	}
+
+	return nil
+}
+
+const onCleanItems = "on filesSQLite.CleanItems()"
+
+func (filesOp *filesSQLite) CleanItems(term *selectors.Term, dryRun bool) (files.Items, error) {
+	selected, err := files.Selected(term)
+	if err != nil {
+		return nil, errors.CommonError(err, onCleanItems)
+	}
+
+	var items files.Items
+	if err = filesOp.transaction(func(tx *sql.Tx) error {
+		itemsAll, err := filesOp.items(tx, "is_dir = 0")
+		if err != nil {
+			return err
+		}
+		for _, item := range itemsAll {
+			if selected(item) {
+				items = append(items, item)
+			}
+		}
+
+		if dryRun {
+			return nil
+		}
+
+		if term == nil {
+			// pending files are removed too
+			for _, table := range []string{filesOp.tableChunks, filesOp.table} {
+				if _, err = tx.Exec("DELETE FROM " + table); err != nil {
+					return errors.Wrapf(err, sqllib.CantExec, "DELETE FROM "+table, nil)
+				}
+			}
+			return nil
+		}
+
+		for _, item := range items {
+			if err = filesOp.remove(tx, "path = ?", item.Path); err != nil {
+				return err
+			}
+		}
+
+		// pending files left by failed writers are removed too
+		return filesOp.remove(tx, "path IS NULL AND created_at < ?", time.Now().Add(-PendingMaxAge).UnixNano())
+	}); err != nil {
+		return nil, errors.CommonError(err, onCleanItems)
+	}
+
+	return items, nil
+}
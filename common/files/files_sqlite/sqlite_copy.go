@@ -0,0 +1,166 @@
+package files_sqlite
+
+import (
+	"database/sql"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/filelib"
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/sqllib"
+)
+
+const onRemoveAll = "on filesSQLite.RemoveAll()"
+
+func (filesOp *filesSQLite) RemoveAll(path string) error {
+	if err := filesOp.transaction(func(tx *sql.Tx) error {
+		filePath, _, err := filesOp.existing(tx, path)
+		if err != nil {
+			return err
+		}
+
+		lower, upper := below(filePath)
+		return filesOp.remove(tx, "path = ? OR (path >= ? AND path < ?)", filePath, lower, upper)
+	}); err != nil {
+		return errors.CommonError(err, onRemoveAll)
+	}
+
+	return nil
+}
+
+const onCopy = "on filesSQLite.Copy()"
+
+func (filesOp *filesSQLite) Copy(from, to string) error {
+	if err := filesOp.transaction(func(tx *sql.Tx) error {
+		fromPath, toPath, err := filesOp.fromTo(tx, from, to)
+		if err != nil {
+			return err
+		}
+
+		lower, upper := below(fromPath)
+		sqlSelect := "SELECT id, path FROM " + filesOp.table + " WHERE path = ? OR (path >= ? AND path < ?)"
+		rows, err := tx.Query(sqlSelect, fromPath, lower, upper)
+		if err != nil {
+			return errors.Wrapf(err, sqllib.CantQuery, sqlSelect, fromPath)
+		}
+
+		ids := map[int64]string{}
+		for rows.Next() {
+			var id int64
+			var path string
+			if err = rows.Scan(&id, &path); err != nil {
+				rows.Close()
+				return errors.Wrapf(err, sqllib.CantScanQueryRow, sqlSelect, fromPath)
+			}
+			ids[id] = toPath + path[len(fromPath):]
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return errors.Wrapf(err, sqllib.RowsError, sqlSelect, fromPath)
+		}
+
+		sqlInsert := "INSERT INTO " + filesOp.table + " (path, is_dir, size, created_at, modified_at, mime_type, hash, original_name, tags) " +
+			"SELECT ?, is_dir, size, created_at, modified_at, mime_type, hash, original_name, tags FROM " + filesOp.table + " WHERE id = ?"
+		sqlInsertChunks := "INSERT INTO " + filesOp.tableChunks + " (file_id, n, data) SELECT ?, n, data FROM " + filesOp.tableChunks + " WHERE file_id = ?"
+		for id, path := range ids {
+			res, err := tx.Exec(sqlInsert, path, id)
+			if err != nil {
+				return errors.Wrapf(err, sqllib.CantExec, sqlInsert, path)
+			}
+			idCopied, err := res.LastInsertId()
+			if err != nil {
+				return errors.Wrapf(err, sqllib.CantGetLastInsertId, sqlInsert, path)
+			}
+			if _, err = tx.Exec(sqlInsertChunks, idCopied, id); err != nil {
+				return errors.Wrapf(err, sqllib.CantExec, sqlInsertChunks, id)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return errors.CommonError(err, onCopy)
+	}
+
+	return nil
+}
+
+const onMove = "on filesSQLite.Move()"
+
+func (filesOp *filesSQLite) Move(from, to string) error {
+	if err := filesOp.transaction(func(tx *sql.Tx) error {
+		fromPath, toPath, err := filesOp.fromTo(tx, from, to)
+		if err != nil {
+			return err
+		}
+
+		// SQLite substr() counts characters, not bytes
+		lower, upper := below(fromPath)
+		values := []interface{}{toPath, utf8.RuneCountInString(fromPath) + 1, fromPath, lower, upper}
+		sqlUpdate := "UPDATE " + filesOp.table + " SET path = ? || substr(path, ?) WHERE path = ? OR (path >= ? AND path < ?)"
+		if _, err = tx.Exec(sqlUpdate, values...); err != nil {
+			return errors.Wrapf(err, sqllib.CantExec, sqlUpdate, values)
+		}
+
+		return nil
+	}); err != nil {
+		return errors.CommonError(err, onMove)
+	}
+
+	return nil
+}
+
+// existing returns the cleaned path of the existing file or directory (but not the root)
+func (filesOp *filesSQLite) existing(tx *sql.Tx, path string) (string, *files.Item, error) {
+	filePath, err := filelib.CleanPath(path)
+	if err != nil {
+		return "", nil, err
+	} else if filePath == "" {
+		return "", nil, errors.CommonError(common.WrongPathKey, common.Map{"path": path}, "the root can't be used")
+	}
+
+	_, item, err := filesOp.item(tx, filePath)
+	if err != nil {
+		return "", nil, err
+	} else if item == nil {
+		return "", nil, errors.CommonError(common.NotFoundKey, common.Map{"path": path}, "no such file or directory")
+	}
+
+	return filePath, item, nil
+}
+
+// fromTo checks the source and the target for Copy() and Move(), the target directory is created if it's necessary
+// and the target file is removed
+func (filesOp *filesSQLite) fromTo(tx *sql.Tx, from, to string) (string, string, error) {
+	fromPath, fromItem, err := filesOp.existing(tx, from)
+	if err != nil {
+		return "", "", err
+	}
+
+	toPath, err := filelib.CleanPath(to)
+	if err != nil {
+		return "", "", err
+	} else if toPath == "" {
+		return "", "", errors.CommonError(common.WrongPathKey, common.Map{"path": to}, "the root can't be used")
+	} else if toPath == fromPath || strings.HasPrefix(toPath, fromPath+"/") {
+		return "", "", errors.CommonError(common.WrongPathKey, common.Map{"from": from, "to": to}, "the target is inside the source")
+	}
+
+	toID, toItem, err := filesOp.item(tx, toPath)
+	if err != nil {
+		return "", "", err
+	} else if toItem != nil {
+		if fromItem.IsDir || toItem.IsDir {
+			return "", "", errors.CommonError(common.WrongPathKey, common.Map{"path": to}, "the target directory exists already")
+		} else if err = filesOp.remove(tx, "id = ?", toID); err != nil {
+			return "", "", err
+		}
+	}
+
+	if err = filesOp.makeDir(tx, files.Parent(toPath)); err != nil {
+		return "", "", errors.Wrapf(err, "can't create the target directory for %s", to)
+	}
+
+	return fromPath, toPath, nil
+}
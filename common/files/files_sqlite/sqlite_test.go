@@ -0,0 +1,96 @@
+package files_sqlite
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/config"
+	"github.com/pavlo67/common/common/db/db_sqlite"
+	"github.com/pavlo67/common/common/joiner/joiner_runtime"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/logger/logger_test"
+	"github.com/pavlo67/common/common/selectors"
+	"github.com/pavlo67/common/common/sqllib/sqllib_sqlite"
+
+	"github.com/pavlo67/common/common/files"
+)
+
+func TestFilesSQLite(t *testing.T) {
+	db, err := sqllib_sqlite.Connect(config.Access{Path: filepath.Join(t.TempDir(), "files.sqlite")})
+	require.NoError(t, err)
+	defer db.Close()
+
+	l := logger_test.New(t)
+	joinerOp := joiner_runtime.New(nil, l)
+	require.NoError(t, joinerOp.Join(l, logger.InterfaceKey))
+	require.NoError(t, joinerOp.Join(db, db_sqlite.InterfaceKey))
+
+	// the small chunk size is used to check the chunked storage
+	filesStarter := Starter()
+	require.NoError(t, filesStarter.Prepare(nil, common.Map{"chunk_size": 4}))
+	require.NoError(t, filesStarter.Run(joinerOp))
+
+	files.FilesTestScenario(t, joinerOp, files.InterfaceKey, files.InterfaceKeyCleaner)
+
+	filesOp, _ := joinerOp.Interface(files.InterfaceKey).(files.Operator)
+	require.NotNil(t, filesOp)
+
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	pathSaved, err := filesOp.Save("big/file", "", data, nil)
+	require.NoError(t, err)
+
+	var chunks int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM "+DefaultTable+"_chunks").Scan(&chunks))
+	require.Equal(t, len(data)/4, chunks)
+
+	reader, item, err := filesOp.Open(pathSaved)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), item.Size)
+	dataReaded, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, data, dataReaded)
+	require.NoError(t, reader.Close())
+
+	// the file removed while it's read isn't returned truncated
+	reader, _, err = filesOp.Open(pathSaved)
+	require.NoError(t, err)
+	_, err = reader.Read(make([]byte, 10))
+	require.NoError(t, err)
+	require.NoError(t, filesOp.Remove(pathSaved))
+	_, err = ioutil.ReadAll(reader)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.NoError(t, reader.Close())
+
+	// stale pending files are cleaned
+	cleanerOp, _ := joinerOp.Interface(files.InterfaceKeyCleaner).(files.Cleaner)
+	require.NotNil(t, cleanerOp)
+
+	writerStale, err := filesOp.Create("pending/stale", "", nil)
+	require.NoError(t, err)
+	_, err = writerStale.Write([]byte("stale data"))
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE "+DefaultTable+" SET created_at = ? WHERE path IS NULL", time.Now().Add(-2*PendingMaxAge).UnixNano())
+	require.NoError(t, err)
+
+	writerPending, err := filesOp.Create("pending/fresh", "", nil)
+	require.NoError(t, err)
+	_, err = writerPending.Write([]byte("pending data"))
+	require.NoError(t, err)
+
+	require.NoError(t, cleanerOp.Clean(&selectors.Term{Key: files.SelectPathPrefix, Values: "pending"}))
+
+	var pending int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM "+DefaultTable+" WHERE path IS NULL").Scan(&pending))
+	require.Equal(t, 1, pending)
+	require.NoError(t, writerPending.Close())
+
+	_, _, err = New(db, "wrong table", 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,219 @@
+package files_sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/sqllib"
+)
+
+var _ files.Writer = &fileWriter{}
+
+// fileWriter saves data chunks for the pending file (with NULL path) as soon as they are filled
+type fileWriter struct {
+	filesOp        *filesSQLite
+	fileID         int64
+	dirPath        string
+	filePath       string
+	newFilePattern string
+	meta           *files.Meta
+	digest         *files.Digest
+	buffer         []byte
+	chunks         int
+	size           int64
+	path           string
+	finished       bool
+}
+
+const onWrite = "on fileWriter.Write()"
+
+func (fw *fileWriter) Write(p []byte) (int, error) {
+	if fw.finished {
+		return 0, fmt.Errorf(onWrite + ": the writer is closed already")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := fw.filesOp.chunkSize - len(fw.buffer)
+		if n > len(p) {
+			n = len(p)
+		}
+		fw.buffer = append(fw.buffer, p[:n]...)
+		fw.digest.Write(p[:n])
+		p, written = p[n:], written+n
+
+		if len(fw.buffer) >= fw.filesOp.chunkSize {
+			if err := fw.flush(); err != nil {
+				return written, errors.CommonError(err, onWrite)
+			}
+		}
+	}
+
+	return written, nil
+}
+
+func (fw *fileWriter) flush() error {
+	if len(fw.buffer) < 1 {
+		return nil
+	}
+
+	sqlInsert := "INSERT INTO " + fw.filesOp.tableChunks + " (file_id, n, data) VALUES (?, ?, ?)"
+	if _, err := sqllib.Exec(fw.filesOp.db, sqlInsert, fw.fileID, fw.chunks, fw.buffer); err != nil {
+		return err
+	}
+
+	fw.chunks++
+	fw.size += int64(len(fw.buffer))
+	fw.buffer = fw.buffer[:0]
+
+	return nil
+}
+
+const onClose = "on fileWriter.Close()"
+
+func (fw *fileWriter) Close() error {
+	if fw.finished {
+		return fmt.Errorf(onClose + ": the writer is closed already")
+	}
+	fw.finished = true
+
+	if err := fw.flush(); err != nil {
+		fw.abort()
+		return errors.CommonError(err, onClose)
+	}
+
+	filesOp := fw.filesOp
+	var filePath string
+
+	if err := filesOp.transaction(func(tx *sql.Tx) error {
+		if err := filesOp.makeDir(tx, fw.dirPath); err != nil {
+			return err
+		}
+
+		if fw.newFilePattern != "" {
+			for {
+				filePath = path.Join(fw.dirPath, files.RandomName(fw.newFilePattern))
+				if _, item, err := filesOp.item(tx, filePath); err != nil {
+					return err
+				} else if item == nil {
+					break
+				}
+			}
+		} else {
+			filePath = fw.filePath
+			if id, item, err := filesOp.item(tx, filePath); err != nil {
+				return err
+			} else if item != nil && item.IsDir {
+				return fmt.Errorf("%s is a directory", filePath)
+			} else if item != nil {
+				if err = filesOp.remove(tx, "id = ?", id); err != nil {
+					return err
+				}
+			}
+		}
+
+		var originalName, tags string
+		if fw.meta != nil {
+			originalName = fw.meta.OriginalName
+			if len(fw.meta.Tags) > 0 {
+				tagsJSON, err := json.Marshal(fw.meta.Tags)
+				if err != nil {
+					return errors.Wrapf(err, "can't marshal tags (%#v)", fw.meta.Tags)
+				}
+				tags = string(tagsJSON)
+			}
+		}
+
+		now := time.Now().UnixNano()
+		values := []interface{}{filePath, fw.size, now, now, fw.digest.MIMEType(filePath, fw.meta), fw.digest.Hash(), originalName, tags, fw.fileID}
+		sqlUpdate := "UPDATE " + filesOp.table + " SET path = ?, size = ?, created_at = ?, modified_at = ?, mime_type = ?, hash = ?, original_name = ?, tags = ? WHERE id = ?"
+		if _, err := tx.Exec(sqlUpdate, values...); err != nil {
+			return errors.Wrapf(err, sqllib.CantExec, sqlUpdate, values)
+		}
+
+		return nil
+	}); err != nil {
+		fw.abort()
+		return errors.CommonError(err, onClose)
+	}
+
+	fw.path = filePath
+	return nil
+}
+
+const onAbort = "on fileWriter.Abort()"
+
+func (fw *fileWriter) Abort() error {
+	if fw.finished {
+		return nil
+	}
+	fw.finished = true
+
+	if err := fw.abort(); err != nil {
+		return errors.CommonError(err, onAbort)
+	}
+
+	return nil
+}
+
+// abort removes the pending file with its chunks
+func (fw *fileWriter) abort() error {
+	fw.buffer = nil
+	return fw.filesOp.remove(fw.filesOp.db, "id = ? AND path IS NULL", fw.fileID)
+}
+
+func (fw *fileWriter) Path() string {
+	return fw.path
+}
+
+// fileReader reads data chunks one by one, the error is returned if they are fewer than the file size (the file is
+// overwritten or removed while it's read)
+type fileReader struct {
+	filesOp *filesSQLite
+	fileID  int64
+	size    int64
+	read    int64
+	chunk   []byte
+	n       int
+	eof     bool
+}
+
+const onRead = "on fileReader.Read()"
+
+func (fr *fileReader) Read(p []byte) (int, error) {
+	for len(fr.chunk) < 1 {
+		if fr.eof {
+			return 0, io.EOF
+		}
+
+		sqlSelect := "SELECT data FROM " + fr.filesOp.tableChunks + " WHERE file_id = ? AND n = ?"
+		err := fr.filesOp.db.QueryRow(sqlSelect, fr.fileID, fr.n).Scan(&fr.chunk)
+		if err == sql.ErrNoRows {
+			if fr.read != fr.size {
+				return 0, errors.Wrapf(io.ErrUnexpectedEOF, onRead+": %d bytes of %d are read, the file is changed or removed", fr.read, fr.size)
+			}
+			fr.eof = true
+			continue
+		} else if err != nil {
+			return 0, errors.Wrapf(err, onRead+": "+sqllib.CantScanQueryRow, sqlSelect, fr.fileID)
+		}
+		fr.n++
+	}
+
+	n := copy(p, fr.chunk)
+	fr.chunk = fr.chunk[n:]
+	fr.read += int64(n)
+
+	return n, nil
+}
+
+func (fr *fileReader) Close() error {
+	fr.chunk, fr.eof = nil, true
+	return nil
+}
@@ -0,0 +1,70 @@
+package files_sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/config"
+	"github.com/pavlo67/common/common/db/db_sqlite"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/files"
+	"github.com/pavlo67/common/common/joiner"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/starter"
+)
+
+func Starter() starter.Operator {
+	return &filesSQLiteStarter{}
+}
+
+var l logger.Operator
+var _ starter.Operator = &filesSQLiteStarter{}
+
+type filesSQLiteStarter struct {
+	dbKey        joiner.InterfaceKey
+	table        string
+	chunkSize    int
+	interfaceKey joiner.InterfaceKey
+	cleanerKey   joiner.InterfaceKey
+}
+
+func (fss *filesSQLiteStarter) Name() string {
+	return logger.GetCallInfo().PackageName
+}
+
+func (fss *filesSQLiteStarter) Prepare(cfg *config.Config, options common.Map) error {
+	fss.dbKey = joiner.InterfaceKey(options.StringDefault("db_key", string(db_sqlite.InterfaceKey)))
+	fss.table = options.StringDefault("table", DefaultTable)
+	fss.chunkSize = int(options.Int64Default("chunk_size", DefaultChunkSize))
+	fss.interfaceKey = joiner.InterfaceKey(options.StringDefault("interface_key", string(files.InterfaceKey)))
+	fss.cleanerKey = joiner.InterfaceKey(options.StringDefault("cleaner_key", string(files.InterfaceKeyCleaner)))
+
+	return nil
+}
+
+func (fss *filesSQLiteStarter) Run(joinerOp joiner.Operator) error {
+	if l, _ = joinerOp.Interface(logger.InterfaceKey).(logger.Operator); l == nil {
+		return fmt.Errorf("no logger.Operator with key %s", logger.InterfaceKey)
+	}
+
+	db, _ := joinerOp.Interface(fss.dbKey).(*sql.DB)
+	if db == nil {
+		return fmt.Errorf("no *sql.DB with key %s", fss.dbKey)
+	}
+
+	filesOp, filesCleanerOp, err := New(db, fss.table, fss.chunkSize)
+	if err != nil {
+		return errors.Wrap(err, "can't init *filesSQLite{} as files.Operator")
+	}
+
+	if err = joinerOp.Join(filesOp, fss.interfaceKey); err != nil {
+		return errors.Wrapf(err, "can't join *filesSQLite{} as files.Operator with key '%s'", fss.interfaceKey)
+	}
+
+	if err = joinerOp.Join(filesCleanerOp, fss.cleanerKey); err != nil {
+		return errors.Wrapf(err, "can't join *filesSQLite{} as db.Cleaner with key '%s'", fss.cleanerKey)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package files
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pavlo67/common/common/errors"
+)
+
+// RandomName replaces the last "*" in pattern with the random string (or appends it) like ioutil.TempFile() does
+func RandomName(pattern string) string {
+	random := strconv.Itoa(int(rand.Uint32()))
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		return pattern[:i] + random + pattern[i+1:]
+	}
+	return pattern + random
+}
+
+// NotExist returns the error for the missing path like os functions do (so errors.Is(err, os.ErrNotExist) is true)
+func NotExist(op, path string) error {
+	return &os.PathError{Op: op, Path: path, Err: os.ErrNotExist}
+}
+
+// Parent returns the parent directory of the path relative to the root ("" for the root itself)
+func Parent(filePath string) string {
+	if dirPath := path.Dir(filePath); dirPath != "." {
+		return dirPath
+	}
+	return ""
+}
+
+const onSaveData = "on files.SaveData()"
+
+// SaveData implements Operator.Save() with Operator.Create()
+func SaveData(filesOp Operator, path, newFilePattern string, data []byte, meta *Meta) (string, error) {
+	writer, err := filesOp.Create(path, newFilePattern, meta)
+	if err != nil {
+		return "", errors.CommonError(err, onSaveData)
+	}
+
+	if _, err = io.Copy(writer, bytes.NewReader(data)); err != nil {
+		if errAbort := writer.Abort(); errAbort != nil {
+			err = errors.CommonError(err, errAbort)
+		}
+		return "", errors.CommonError(err, onSaveData)
+	}
+
+	if err = writer.Close(); err != nil {
+		return "", errors.CommonError(err, onSaveData)
+	}
+
+	return writer.Path(), nil
+}
+
+const onReadData = "on files.ReadData()"
+
+// ReadData implements Operator.Read() with Operator.Open()
+func ReadData(filesOp Operator, path string) ([]byte, error) {
+	reader, _, err := filesOp.Open(path)
+	if err != nil {
+		return nil, errors.CommonError(err, onReadData)
+	}
+	defer reader.Close()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errors.CommonError(err, onReadData)
+	}
+
+	return data, nil
+}
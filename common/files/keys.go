@@ -4,3 +4,6 @@ import "github.com/pavlo67/common/common/joiner"
 
 const InterfaceKey = joiner.InterfaceKey("files")
 const InterfaceKeyCleaner = joiner.InterfaceKey("files_cleaner")
+
+// InterfaceKeyStorage is the default key of files.Operator used as the storage by decorators (files_dedup, etc.)
+const InterfaceKeyStorage = joiner.InterfaceKey("files_storage")
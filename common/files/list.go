@@ -0,0 +1,241 @@
+package files
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+type SortKey string
+
+const SortByName SortKey = "name" // the default one, items are sorted by their paths
+const SortBySize SortKey = "size"
+const SortByTime SortKey = "time" // Item.CreatedAt is used
+
+// ListOptions select items for Operator.List(), empty fields don't restrict anything
+type ListOptions struct {
+	MIMEType string // MIME type prefix ("image/" matches all images)
+	Hash     string
+	Tags     Tags // all of them should be set for the item with the same values
+
+	// path.Match() patterns, they are checked against the item name or against the path relative to the root
+	// (if the pattern contains "/"), the item should match any of Include and none of Exclude (excluded directories
+	// aren't listed with their contents)
+	Include []string
+	Exclude []string
+
+	SortBy     SortKey
+	Descending bool
+	Limit      int    // the page size (no limit if it's 0)
+	Cursor     string // the cursor returned with the previous page
+}
+
+// Matches checks the item against options, directories match the options without metadata conditions only
+func (item Item) Matches(options *ListOptions) bool {
+	if options == nil {
+		return true
+	}
+
+	if options.MIMEType != "" && !strings.HasPrefix(item.MIMEType, options.MIMEType) {
+		return false
+	}
+	if options.Hash != "" && !strings.EqualFold(item.Hash, options.Hash) {
+		return false
+	}
+	for key, value := range options.Tags {
+		if itemValue, ok := item.Tags[key]; !ok || itemValue != value {
+			return false
+		}
+	}
+
+	if len(options.Include) > 0 && !item.matchesAny(options.Include) {
+		return false
+	}
+
+	return !item.matchesAny(options.Exclude)
+}
+
+// Filter returns items matching options, items inside excluded directories are skipped too (so these directories should
+// be in items)
+func (items Items) Filter(options *ListOptions) Items {
+	if options == nil {
+		return items
+	}
+
+	var excluded []string
+	for _, item := range items {
+		if item.IsDir && item.matchesAny(options.Exclude) {
+			excluded = append(excluded, item.Path)
+		}
+	}
+
+	var filtered Items
+ITEMS:
+	for _, item := range items {
+		for _, excludedPath := range excluded {
+			if strings.HasPrefix(item.Path, excludedPath) {
+				continue ITEMS
+			}
+		}
+		if item.Matches(options) {
+			filtered = append(filtered, item)
+		}
+	}
+
+	return filtered
+}
+
+func (item Item) matchesAny(patterns []string) bool {
+	itemPath := strings.TrimSuffix(item.Path, "/")
+	name := path.Base(itemPath)
+
+	for _, pattern := range patterns {
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = itemPath
+		}
+		if matched, _ := path.Match(pattern, target); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BeforeNameCursor returns the function checking if the item path is on the previous pages of items sorted by name, so
+// Operator implementations can skip such items before reading their metadata (nil is returned for other sort keys and
+// without the cursor)
+func BeforeNameCursor(options *ListOptions) func(itemPath string) bool {
+	if options == nil || (options.SortBy != "" && options.SortBy != SortByName) {
+		return nil
+	}
+
+	cursor, err := decodeCursor(options.Cursor)
+	if err != nil || cursor == nil || cursor.SortBy != SortByName {
+		return nil
+	}
+
+	if options.Descending {
+		return func(itemPath string) bool { return itemPath >= cursor.Path }
+	}
+
+	return func(itemPath string) bool { return itemPath <= cursor.Path }
+}
+
+// CheckListOptions is called by Operator implementations before the listing
+func CheckListOptions(options *ListOptions) error {
+	if options == nil {
+		return nil
+	}
+
+	for _, pattern := range append(options.Include[:len(options.Include):len(options.Include)], options.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("wrong pattern (%s): %s", pattern, err)
+		}
+	}
+
+	switch options.SortBy {
+	case "", SortByName, SortBySize, SortByTime:
+	default:
+		return fmt.Errorf("wrong sort key: '%s'", options.SortBy)
+	}
+
+	if options.Limit < 0 {
+		return fmt.Errorf("wrong limit: %d", options.Limit)
+	}
+
+	_, err := decodeCursor(options.Cursor)
+	return err
+}
+
+// listCursor is the position after the last item of the page (encoded to the opaque string)
+type listCursor struct {
+	SortBy SortKey `json:"s,omitempty"`
+	Value  int64   `json:"v,omitempty"`
+	Path   string  `json:"p"`
+}
+
+func decodeCursor(cursorStr string) (*listCursor, error) {
+	if cursorStr == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursorStr)
+	if err != nil {
+		return nil, fmt.Errorf("wrong cursor (%s): %s", cursorStr, err)
+	}
+
+	var cursor listCursor
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("wrong cursor (%s): %s", cursorStr, err)
+	}
+
+	return &cursor, nil
+}
+
+func (cursor listCursor) String() string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func sortValue(item Item, sortBy SortKey) int64 {
+	switch sortBy {
+	case SortBySize:
+		return item.Size
+	case SortByTime:
+		return item.CreatedAt.UnixNano()
+	}
+
+	return 0
+}
+
+// Page sorts items and returns the page defined by options.Limit and options.Cursor with the cursor for the next page
+// (it's empty for the last page), it's used by Operator implementations after the listing
+func (items Items) Page(options *ListOptions) (Items, string, error) {
+	var opts ListOptions
+	if options != nil {
+		opts = *options
+	}
+	if opts.SortBy == "" {
+		opts.SortBy = SortByName
+	}
+
+	less := func(value0 int64, path0 string, value1 int64, path1 string) bool {
+		if opts.Descending {
+			value0, path0, value1, path1 = value1, path1, value0, path0
+		}
+		if value0 != value1 {
+			return value0 < value1
+		}
+		return path0 < path1
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return less(sortValue(items[i], opts.SortBy), items[i].Path, sortValue(items[j], opts.SortBy), items[j].Path)
+	})
+
+	cursor, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, "", err
+	} else if cursor != nil {
+		if cursor.SortBy != opts.SortBy {
+			return nil, "", fmt.Errorf("the cursor is for sorting by '%s', not by '%s'", cursor.SortBy, opts.SortBy)
+		}
+		start := sort.Search(len(items), func(i int) bool {
+			return less(cursor.Value, cursor.Path, sortValue(items[i], opts.SortBy), items[i].Path)
+		})
+		items = items[start:]
+	}
+
+	if opts.Limit <= 0 || len(items) <= opts.Limit {
+		return items, "", nil
+	}
+
+	items = items[:opts.Limit]
+	last := items[len(items)-1]
+
+	return items, listCursor{SortBy: opts.SortBy, Value: sortValue(last, opts.SortBy), Path: last.Path}.String(), nil
+}
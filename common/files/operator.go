@@ -1,24 +1,80 @@
 package files
 
 import (
+	"io"
 	"os"
 	"time"
 )
 
 type Operator interface {
-	Save(path, newFilePattern string, data []byte) (string, error)
+	// Save and Read keep all the file data in memory, they are helpers over Create and Open (see SaveData and ReadData)
+	Save(path, newFilePattern string, data []byte, meta *Meta) (string, error)
 	Read(path string) ([]byte, error)
+
+	// Open returns the reader of the file data, it must be closed
+	Open(path string) (io.ReadCloser, *Item, error)
+
+	// Create returns the writer of the new file data (the file is created with the random name by newFilePattern if it's set),
+	// meta is optional
+	Create(path, newFilePattern string, meta *Meta) (Writer, error)
+
 	Remove(path string) error
-	List(path string, depth int) (Items, error)
+
+	// RemoveAll removes the file or the directory with all its contents (the root can't be removed so, see Cleaner)
+	RemoveAll(path string) error
+
+	// Copy and Move process files and directories (recursively) with their metadata, the target file is replaced
+	// but the target directory shouldn't exist
+	Copy(from, to string) error
+	Move(from, to string) error
+
+	// List returns items (with paths relative to the root) below path: only direct children with depth == 0, depth more levels
+	// with depth > 0 and all levels with depth < 0. Items are filtered, sorted and paged with options (see ListOptions),
+	// the cursor for the next page is returned (it's empty for the last one).
+	List(path string, depth int, options *ListOptions) (Items, string, error)
+
+	// Stat returns the item of path, with depth != 0 the Size of a directory is the total size of files List(path, depth)
+	// would return (it's 0 with depth == 0)
 	Stat(path string, depth int) (*Item, error)
 }
 
+// Writer commits the written data on Close() only (so the partially written file is never available for readers),
+// Abort() discards it
+type Writer interface {
+	io.WriteCloser
+	Abort() error
+
+	// Path returns the path of the saved file (it's available after Close() only)
+	Path() string
+}
+
+// Tagger is implemented by operators able to replace tags of the file without rewriting its data
+type Tagger interface {
+	SetTags(path string, tags Tags) error
+}
+
+type Tags map[string]string
+
+// Meta is given on the file creation
+type Meta struct {
+	OriginalName string
+	MIMEType     string // it's detected with filelib.MIME() if it's empty
+	Tags         Tags
+}
+
 type Item struct {
-	Path string
+	Path string // relative to the root, directory paths end with "/"
 	// Name      string
-	IsDir     bool
-	Size      int64
-	CreatedAt time.Time
+	IsDir      bool
+	Size       int64
+	CreatedAt  time.Time // it's the modification time if the file has no metadata
+	ModifiedAt time.Time
+
+	// the metadata saved with the file (they are empty for directories)
+	MIMEType     string
+	Hash         string // hex encoded SHA-256 of the file data
+	OriginalName string
+	Tags         Tags
 }
 
 type Items []Item
@@ -37,15 +93,17 @@ func (fis Items) Append(basePath string, info os.FileInfo) (Items, error) {
 		fis = append(fis, Item{
 			Path: path,
 			// Path:      path[len(basePath):],
-			IsDir:     true,
-			CreatedAt: info.ModTime(),
+			IsDir:      true,
+			CreatedAt:  info.ModTime(),
+			ModifiedAt: info.ModTime(),
 		})
 	} else {
 		fis = append(fis, Item{
 			Path: path,
 			// Path:      path[len(basePath):],
-			Size:      info.Size(),
-			CreatedAt: info.ModTime(),
+			Size:       info.Size(),
+			CreatedAt:  info.ModTime(),
+			ModifiedAt: info.ModTime(),
 		})
 	}
 
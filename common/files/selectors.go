@@ -0,0 +1,119 @@
+package files
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pavlo67/common/common/db"
+	"github.com/pavlo67/common/common/selectors"
+)
+
+// selector keys understood by Cleaner implementations
+const (
+	SelectPathPrefix selectors.Key = "path_prefix" // Values: string, the prefix of the path relative to the root
+	SelectGlob       selectors.Key = "glob"        // Values: string, path.Match() pattern for the path relative to the root
+	SelectOlderThan  selectors.Key = "older_than"  // Values: time.Time or time.Duration (the age), Item.CreatedAt is checked
+	SelectLargerThan selectors.Key = "larger_than" // Values: int or int64, the size in bytes
+	SelectTags       selectors.Key = "tags"        // Values: Tags, all of them should be set for the item with the same values
+	SelectAnd        selectors.Key = "and"         // Values: []selectors.Term, all of them should match
+)
+
+// Cleaner removes the files selected with the term (see Select* keys), all files (and directories) are removed if the term
+// is nil. CleanItems() with dryRun returns the items to be removed but removes nothing.
+type Cleaner interface {
+	db.Cleaner
+	CleanItems(term *selectors.Term, dryRun bool) (Items, error)
+}
+
+// Selected returns the check for items (files only, with paths relative to the root) selected with the term. Everything
+// is selected with nil term only, the empty or unknown key is an error (so the wrong term can't remove everything).
+func Selected(term *selectors.Term) (func(Item) bool, error) {
+	if term == nil {
+		return func(Item) bool { return true }, nil
+	}
+
+	wrongValues := func() error {
+		return fmt.Errorf("wrong values for selector '%s': %#v", term.Key, term.Values)
+	}
+
+	switch term.Key {
+	case SelectPathPrefix:
+		prefix, ok := term.Values.(string)
+		if !ok || prefix == "" {
+			return nil, wrongValues()
+		}
+		prefix = strings.TrimPrefix(path.Clean("/"+prefix), "/")
+		return func(item Item) bool {
+			return item.Path == prefix || strings.HasPrefix(item.Path, strings.TrimSuffix(prefix, "/")+"/")
+		}, nil
+
+	case SelectGlob:
+		pattern, ok := term.Values.(string)
+		if !ok || pattern == "" {
+			return nil, wrongValues()
+		} else if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("wrong pattern for selector '%s' (%s): %s", term.Key, pattern, err)
+		}
+		return func(item Item) bool {
+			matched, _ := path.Match(pattern, item.Path)
+			return matched
+		}, nil
+
+	case SelectOlderThan:
+		var before time.Time
+		switch v := term.Values.(type) {
+		case time.Time:
+			before = v
+		case time.Duration:
+			before = time.Now().Add(-v)
+		default:
+			return nil, wrongValues()
+		}
+		return func(item Item) bool { return item.CreatedAt.Before(before) }, nil
+
+	case SelectLargerThan:
+		var size int64
+		switch v := term.Values.(type) {
+		case int:
+			size = int64(v)
+		case int64:
+			size = v
+		default:
+			return nil, wrongValues()
+		}
+		return func(item Item) bool { return item.Size > size }, nil
+
+	case SelectTags:
+		tags, ok := term.Values.(Tags)
+		if !ok || len(tags) < 1 {
+			return nil, wrongValues()
+		}
+		return func(item Item) bool { return item.Matches(&ListOptions{Tags: tags}) }, nil
+
+	case SelectAnd:
+		terms, ok := term.Values.([]selectors.Term)
+		if !ok || len(terms) < 1 {
+			return nil, wrongValues()
+		}
+		var checks []func(Item) bool
+		for i := range terms {
+			check, err := Selected(&terms[i])
+			if err != nil {
+				return nil, err
+			}
+			checks = append(checks, check)
+		}
+		return func(item Item) bool {
+			for _, check := range checks {
+				if !check(item) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown selector key: '%s'", term.Key)
+}
@@ -1,15 +1,25 @@
 package files
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
+	"github.com/pavlo67/common/common"
 	"github.com/pavlo67/common/common/db"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/filelib"
 	"github.com/pavlo67/common/common/joiner"
+	"github.com/pavlo67/common/common/selectors"
 )
 
 const path1 = "bbb/ccc"
@@ -34,6 +44,29 @@ func FilesTestScenario(t *testing.T, joinerOp joiner.Operator, interfaceKey, int
 
 	path2Saved := saveTest(t, filesOp, path2, fileData2)
 	require.NotEmpty(t, path2Saved)
+
+	streamTest(t, filesOp, path1, "", fileData1)
+	streamTest(t, filesOp, filepath.Dir(path1), "ddd_*", fileData2)
+
+	if cleanerOp, _ := filesCleanerOp.(Cleaner); cleanerOp != nil {
+		cleanTest(t, filesOp, cleanerOp)
+	}
+
+	listTest(t, filesOp)
+	CopyMoveTestScenario(t, filesOp)
+
+	if taggerOp, _ := filesOp.(Tagger); taggerOp != nil {
+		tagsTest(t, filesOp, taggerOp)
+	}
+
+	for _, pathWrong := range []string{"../aaa", "bbb/../../aaa", "/aaa", "aaa\x00"} {
+		_, err = filesOp.Save(pathWrong, "", fileData1, nil)
+		require.Errorf(t, err, "%q", pathWrong)
+		require.Equalf(t, filelib.PathOutsideRootKey, errors.Keyed(err), "%q: %s", pathWrong, err)
+
+		_, err = filesOp.Read(pathWrong)
+		require.Equalf(t, filelib.PathOutsideRootKey, errors.Keyed(err), "%q: %s", pathWrong, err)
+	}
 }
 
 const noSuchFileStr = "no such file or directory"
@@ -57,7 +90,7 @@ func saveTest(t *testing.T, filesOp Operator, path string, data []byte) (pathCor
 
 	if err != nil {
 		require.Nil(t, fi)
-		require.True(t, os.IsNotExist(errors.Cause(err)))
+		require.True(t, errors.Is(err, os.ErrNotExist))
 	} else {
 		require.NotNil(t, fi)
 		size0 = fi.Size
@@ -65,7 +98,7 @@ func saveTest(t *testing.T, filesOp Operator, path string, data []byte) (pathCor
 
 	// save file ------------------------------------------------------------
 
-	pathSaved, err := filesOp.Save(path, "", data)
+	pathSaved, err := filesOp.Save(path, "", data, nil)
 	require.NoError(t, err)
 	require.NotEmpty(t, pathSaved)
 
@@ -75,7 +108,7 @@ func saveTest(t *testing.T, filesOp Operator, path string, data []byte) (pathCor
 	require.NoError(t, err)
 	require.Equal(t, data, dataReaded)
 
-	fis, err := filesOp.List(filepath.Dir(pathSaved), 0)
+	fis, _, err := filesOp.List(filepath.Dir(pathSaved), 0, nil)
 	require.NoError(t, err)
 
 	// require.FailNowf(t, "%s --> %#v", filepath.Dir(pathSaved), fis)
@@ -106,7 +139,7 @@ func saveTest(t *testing.T, filesOp Operator, path string, data []byte) (pathCor
 	require.Error(t, err)
 	require.Nil(t, dataReaded)
 
-	fis, err = filesOp.List(filepath.Dir(pathSaved), 0)
+	fis, _, err = filesOp.List(filepath.Dir(pathSaved), 0, nil)
 	require.NoError(t, err)
 
 	found = false
@@ -125,3 +158,356 @@ func saveTest(t *testing.T, filesOp Operator, path string, data []byte) (pathCor
 
 	return pathSaved
 }
+
+func streamTest(t *testing.T, filesOp Operator, path, newFilePattern string, data []byte) {
+
+	// aborted file isn't saved ---------------------------------------------
+
+	writer, err := filesOp.Create(path, newFilePattern, nil)
+	require.NoError(t, err)
+	require.NotNil(t, writer)
+
+	_, err = writer.Write(data)
+	require.NoError(t, err)
+	err = writer.Abort()
+	require.NoError(t, err)
+	require.Empty(t, writer.Path())
+
+	fis, _, err := filesOp.List(path, 0, nil)
+	if newFilePattern == "" {
+		require.Error(t, err)
+	} else {
+		require.NoError(t, err)
+		require.Empty(t, fis)
+	}
+
+	// partially written file isn't available before .Close() -------------
+
+	meta := Meta{OriginalName: "original.txt", Tags: Tags{"kind": "test", "pattern": newFilePattern}}
+	writer, err = filesOp.Create(path, newFilePattern, &meta)
+	require.NoError(t, err)
+	require.NotNil(t, writer)
+
+	for _, chunk := range bytes.SplitAfter(data, []byte("a")) {
+		_, err = writer.Write(chunk)
+		require.NoError(t, err)
+	}
+
+	if newFilePattern == "" {
+		_, _, err = filesOp.Open(path)
+		require.Error(t, err)
+	} else {
+		fis, _, err = filesOp.List(path, 0, nil)
+		require.NoError(t, err)
+		require.Empty(t, fis)
+	}
+
+	err = writer.Close()
+	require.NoError(t, err)
+	pathSaved := writer.Path()
+	require.NotEmpty(t, pathSaved)
+	require.Error(t, writer.Close())
+
+	// check .Open() ---------------------------------------------------------
+
+	reader, fi, err := filesOp.Open(pathSaved)
+	require.NoError(t, err)
+	require.NotNil(t, reader)
+	require.NotNil(t, fi)
+	require.False(t, fi.IsDir)
+	require.Equal(t, int64(len(data)), fi.Size)
+
+	dataReaded, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, data, dataReaded)
+	require.NoError(t, reader.Close())
+
+	// check metadata --------------------------------------------------------
+
+	hash := sha256.Sum256(data)
+	require.Equal(t, hex.EncodeToString(hash[:]), fi.Hash)
+	require.True(t, strings.HasPrefix(fi.MIMEType, "text/plain"), fi.MIMEType)
+	require.Equal(t, meta.OriginalName, fi.OriginalName)
+	require.Equal(t, meta.Tags, fi.Tags)
+	require.False(t, fi.CreatedAt.IsZero())
+
+	fiStat, err := filesOp.Stat(pathSaved, 0)
+	require.NoError(t, err)
+	require.Equal(t, fi, fiStat)
+
+	fis, _, err = filesOp.List(filepath.Dir(pathSaved), 0, nil)
+	require.NoError(t, err)
+	require.Equalf(t, 1, len(fis), "%#v", fis)
+	require.Equal(t, *fi, fis[0])
+
+	for _, options := range []ListOptions{{Tags: Tags{"kind": "test"}}, {MIMEType: "text/"}, {Hash: fi.Hash}} {
+		fis, _, err = filesOp.List(filepath.Dir(pathSaved), 0, &options)
+		require.NoError(t, err)
+		require.Equalf(t, 1, len(fis), "%#v: %#v", options, fis)
+	}
+	for _, options := range []ListOptions{{Tags: Tags{"kind": "another"}}, {Tags: Tags{"another": ""}}, {MIMEType: "image/"}, {Hash: "0"}} {
+		fis, _, err = filesOp.List(filepath.Dir(pathSaved), 0, &options)
+		require.NoError(t, err)
+		require.Emptyf(t, fis, "%#v", options)
+	}
+
+	err = filesOp.Remove(pathSaved)
+	require.NoError(t, err)
+
+	_, _, err = filesOp.Open(pathSaved)
+	require.Error(t, err)
+}
+
+func cleanTest(t *testing.T, filesOp Operator, cleanerOp Cleaner) {
+	err := cleanerOp.Clean(nil)
+	require.NoError(t, err)
+
+	for _, path := range []string{"ccc/1.txt", "ccc/ddd/2.txt", "ccc/3.log", "eee/4.txt"} {
+		_, err = filesOp.Save(path, "", append(fileData1, path...), &Meta{Tags: Tags{"dir": filepath.Dir(path)}})
+		require.NoError(t, err)
+	}
+
+	// wrong terms remove nothing ----------------------------------------------
+
+	for _, term := range []selectors.Term{{}, {Key: "unknown", Values: "ccc"}, {Key: SelectPathPrefix}, {Key: SelectGlob, Values: "["}, {Key: SelectLargerThan, Values: "1"}} {
+		err = cleanerOp.Clean(&term)
+		require.Errorf(t, err, "%#v", term)
+	}
+
+	items, err := cleanerOp.CleanItems(nil, true)
+	require.NoError(t, err)
+	require.Equalf(t, 4, len(items), "%#v", items)
+
+	// dry run ---------------------------------------------------------------------
+
+	for _, tc := range []struct {
+		term  selectors.Term
+		paths []string
+	}{
+		{selectors.Term{Key: SelectPathPrefix, Values: "ccc"}, []string{"ccc/1.txt", "ccc/3.log", "ccc/ddd/2.txt"}},
+		{selectors.Term{Key: SelectPathPrefix, Values: "cc"}, nil},
+		{selectors.Term{Key: SelectGlob, Values: "*/*.txt"}, []string{"ccc/1.txt", "eee/4.txt"}},
+		{selectors.Term{Key: SelectOlderThan, Values: -time.Hour}, []string{"ccc/1.txt", "ccc/3.log", "ccc/ddd/2.txt", "eee/4.txt"}},
+		{selectors.Term{Key: SelectOlderThan, Values: time.Hour}, nil},
+		{selectors.Term{Key: SelectLargerThan, Values: len(fileData1) + len("ccc/1.txt")}, []string{"ccc/ddd/2.txt"}},
+		{selectors.Term{Key: SelectTags, Values: Tags{"dir": "ccc"}}, []string{"ccc/1.txt", "ccc/3.log"}},
+		{selectors.Term{Key: SelectAnd, Values: []selectors.Term{{Key: SelectPathPrefix, Values: "ccc/"}, {Key: SelectGlob, Values: "*/*.txt"}}}, []string{"ccc/1.txt"}},
+	} {
+		items, err = cleanerOp.CleanItems(&tc.term, true)
+		require.NoErrorf(t, err, "%#v", tc.term)
+
+		var paths []string
+		for _, item := range items {
+			paths = append(paths, item.Path)
+		}
+		sort.Strings(paths)
+		require.Equalf(t, tc.paths, paths, "%#v", tc.term)
+	}
+
+	items, err = cleanerOp.CleanItems(nil, true)
+	require.NoError(t, err)
+	require.Equalf(t, 4, len(items), "%#v", items)
+
+	// cleaning ----------------------------------------------------------------------
+
+	err = cleanerOp.Clean(&selectors.Term{Key: SelectGlob, Values: "*/*.txt"})
+	require.NoError(t, err)
+
+	items, err = cleanerOp.CleanItems(nil, true)
+	require.NoError(t, err)
+	require.Equalf(t, 2, len(items), "%#v", items)
+
+	_, err = filesOp.Read("ccc/ddd/2.txt")
+	require.NoError(t, err)
+	_, err = filesOp.Read("ccc/1.txt")
+	require.Error(t, err)
+
+	err = cleanerOp.Clean(nil)
+	require.NoError(t, err)
+
+	items, err = cleanerOp.CleanItems(nil, true)
+	require.NoError(t, err)
+	require.Empty(t, items)
+}
+
+func listTest(t *testing.T, filesOp Operator) {
+	filesData := map[string]string{"lll/a.txt": "a", "lll/b.log": "bbbb", "lll/sub/c.txt": "cc", "lll/sub/deep/d.txt": "ddd"}
+	for path, data := range filesData {
+		_, err := filesOp.Save(path, "", []byte(data), nil)
+		require.NoError(t, err)
+	}
+
+	list := func(depth int, options *ListOptions) []string {
+		items, cursor, err := filesOp.List("lll", depth, options)
+		require.NoErrorf(t, err, "%d / %#v", depth, options)
+		require.Empty(t, cursor)
+
+		var paths []string
+		for _, item := range items {
+			paths = append(paths, item.Path)
+		}
+		return paths
+	}
+
+	require.Equal(t, []string{"lll/a.txt", "lll/b.log", "lll/sub/"}, list(0, nil))
+	require.Equal(t, []string{"lll/a.txt", "lll/b.log", "lll/sub/", "lll/sub/c.txt", "lll/sub/deep/"}, list(1, nil))
+	require.Equal(t, []string{"lll/a.txt", "lll/b.log", "lll/sub/", "lll/sub/c.txt", "lll/sub/deep/", "lll/sub/deep/d.txt"}, list(-1, nil))
+	require.Equal(t, []string{"lll/a.txt", "lll/sub/c.txt", "lll/sub/deep/d.txt"}, list(-1, &ListOptions{Include: []string{"*.txt"}}))
+	require.Equal(t, []string{"lll/a.txt", "lll/b.log"}, list(-1, &ListOptions{Exclude: []string{"sub"}}))
+	require.Equal(t, []string{"lll/a.txt", "lll/b.log", "lll/sub/", "lll/sub/c.txt"}, list(-1, &ListOptions{Exclude: []string{"lll/sub/deep"}}))
+	require.Equal(t, []string{"lll/sub/c.txt"}, list(-1, &ListOptions{Include: []string{"lll/sub/*.txt"}}))
+	require.Equal(t, []string{"lll/sub/deep/d.txt", "lll/sub/c.txt", "lll/a.txt"}, list(-1, &ListOptions{Include: []string{"*.txt"}, SortBy: SortBySize, Descending: true}))
+
+	// stat --------------------------------------------------------------------------
+
+	stat := func(path string, depth int) int64 {
+		item, err := filesOp.Stat(path, depth)
+		require.NoErrorf(t, err, "%s / %d", path, depth)
+		require.NotNil(t, item)
+		return item.Size
+	}
+
+	require.Equal(t, int64(0), stat("lll", 0))
+	require.Equal(t, int64(len("a")+len("bbbb")+len("cc")), stat("lll", 1))
+	require.Equal(t, int64(len("a")+len("bbbb")+len("cc")+len("ddd")), stat("lll", -1))
+	require.Equal(t, int64(len("cc")+len("ddd")), stat("lll/sub", 1))
+	require.Equal(t, int64(len("bbbb")), stat("lll/b.log", -1))
+
+	// paging ----------------------------------------------------------------------
+
+	for _, options := range []ListOptions{{Limit: 2}, {Limit: 1, SortBy: SortBySize}, {Limit: 4, SortBy: SortByTime, Descending: true}} {
+		expected := list(-1, &ListOptions{SortBy: options.SortBy, Descending: options.Descending})
+
+		var paths []string
+		for pages := 0; ; pages++ {
+			require.True(t, pages <= len(expected))
+
+			items, cursor, err := filesOp.List("lll", -1, &options)
+			require.NoError(t, err)
+			require.True(t, len(items) <= options.Limit)
+			for _, item := range items {
+				paths = append(paths, item.Path)
+			}
+			if cursor == "" {
+				break
+			}
+			options.Cursor = cursor
+		}
+		require.Equalf(t, expected, paths, "%#v", options)
+	}
+
+	for _, options := range []ListOptions{{Cursor: "wrong"}, {SortBy: "wrong"}, {Include: []string{"["}}, {Limit: -1}} {
+		_, _, err := filesOp.List("lll", -1, &options)
+		require.Errorf(t, err, "%#v", options)
+	}
+
+	for path := range filesData {
+		require.NoError(t, filesOp.Remove(path))
+	}
+}
+
+func tagsTest(t *testing.T, filesOp Operator, taggerOp Tagger) {
+	data := []byte("tagged")
+	pathSaved, err := filesOp.Save("ttt/a.txt", "", data, &Meta{OriginalName: "original.txt", Tags: Tags{"a": "1"}})
+	require.NoError(t, err)
+
+	item, err := filesOp.Stat(pathSaved, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, taggerOp.SetTags(pathSaved, Tags{"b": "2"}))
+
+	itemTagged, err := filesOp.Stat(pathSaved, 0)
+	require.NoError(t, err)
+	require.Equal(t, Tags{"b": "2"}, itemTagged.Tags)
+	itemTagged.Tags = item.Tags
+	require.Equal(t, item, itemTagged)
+
+	dataReaded, err := filesOp.Read(pathSaved)
+	require.NoError(t, err)
+	require.Equal(t, data, dataReaded)
+
+	require.Error(t, taggerOp.SetTags("ttt", Tags{"b": "2"}))
+	require.Error(t, taggerOp.SetTags("ttt/b.txt", Tags{"b": "2"}))
+
+	require.NoError(t, filesOp.RemoveAll("ttt"))
+}
+
+// CopyMoveTestScenario is exported to be repeated by implementations with special cases (like moving across devices)
+func CopyMoveTestScenario(t *testing.T, filesOp Operator) {
+	meta := Meta{OriginalName: "original.txt", Tags: Tags{"kind": "copy"}}
+	_, err := filesOp.Save("mmm/a.txt", "", fileData1, &meta)
+	require.NoError(t, err)
+	fi, err := filesOp.Stat("mmm/a.txt", 0)
+	require.NoError(t, err)
+
+	checkFile := func(path string) {
+		data, err := filesOp.Read(path)
+		require.NoErrorf(t, err, path)
+		require.Equal(t, fileData1, data)
+
+		fiCopied, err := filesOp.Stat(path, 0)
+		require.NoErrorf(t, err, path)
+		require.Equal(t, fi.Hash, fiCopied.Hash)
+		require.Equal(t, fi.MIMEType, fiCopied.MIMEType)
+		require.Equal(t, fi.OriginalName, fiCopied.OriginalName)
+		require.Equal(t, fi.Tags, fiCopied.Tags)
+		require.True(t, fi.CreatedAt.Equal(fiCopied.CreatedAt))
+	}
+	checkNotFound := func(path string) {
+		_, err := filesOp.Stat(path, 0)
+		require.Errorf(t, err, path)
+		err = filesOp.RemoveAll(path)
+		require.Equalf(t, common.NotFoundKey, errors.Keyed(err), "%s: %s", path, err)
+	}
+
+	// files -----------------------------------------------------------------------
+
+	require.NoError(t, filesOp.Copy("mmm/a.txt", "nnn/b.txt"))
+	checkFile("mmm/a.txt")
+	checkFile("nnn/b.txt")
+
+	require.NoError(t, filesOp.Move("nnn/b.txt", "ooo/c.txt"))
+	checkFile("ooo/c.txt")
+	checkNotFound("nnn/b.txt")
+
+	_, err = filesOp.Save("ooo/d.txt", "", fileData2, nil)
+	require.NoError(t, err)
+	require.NoError(t, filesOp.Move("ooo/c.txt", "ooo/d.txt"))
+	checkFile("ooo/d.txt")
+	checkNotFound("ooo/c.txt")
+
+	// directories -----------------------------------------------------------------
+
+	require.NoError(t, filesOp.Copy("mmm", "ppp/mmm"))
+	checkFile("mmm/a.txt")
+	checkFile("ppp/mmm/a.txt")
+
+	require.NoError(t, filesOp.Move("ppp", "qqq"))
+	checkFile("qqq/mmm/a.txt")
+	checkNotFound("ppp")
+
+	// errors ----------------------------------------------------------------------
+
+	err = filesOp.Copy("nnn/nothing.txt", "nnn/b.txt")
+	require.Equal(t, common.NotFoundKey, errors.Keyed(err), err)
+	err = filesOp.Move("nnn/nothing.txt", "nnn/b.txt")
+	require.Equal(t, common.NotFoundKey, errors.Keyed(err), err)
+
+	for _, fromTo := range [][2]string{{"mmm", "qqq"}, {"mmm", "mmm/sub"}, {"mmm/a.txt", "qqq"}, {"mmm", ""}, {"", "sss"}, {"mmm", "../sss"}} {
+		require.Errorf(t, filesOp.Copy(fromTo[0], fromTo[1]), "%#v", fromTo)
+		require.Errorf(t, filesOp.Move(fromTo[0], fromTo[1]), "%#v", fromTo)
+	}
+	checkFile("mmm/a.txt")
+	checkFile("qqq/mmm/a.txt")
+
+	require.Error(t, filesOp.RemoveAll(""))
+	require.Error(t, filesOp.RemoveAll("."))
+	require.Error(t, filesOp.RemoveAll("../mmm"))
+
+	// cleaning --------------------------------------------------------------------
+
+	for _, path := range []string{"mmm", "ooo", "qqq", "nnn"} {
+		require.NoError(t, filesOp.RemoveAll(path))
+		checkNotFound(path)
+	}
+}
@@ -0,0 +1,109 @@
+package health_server_http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/health"
+	"github.com/pavlo67/common/common/joiner"
+	"github.com/pavlo67/common/common/server"
+	"github.com/pavlo67/common/common/server/server_http"
+)
+
+func Endpoints(joinerOp joiner.Operator, timeout time.Duration, started time.Time) server_http.Endpoints {
+	return server_http.Endpoints{
+		{
+			EndpointDescription: server_http.EndpointDescription{InternalKey: health.InterfaceKeyLiveness, Method: "GET"},
+			WorkerHTTP: func(_ context.Context, _ server_http.Operator, req *http.Request, _ server_http.PathParams, _ *auth.Identity) (server.Response, error) {
+				return server_http.ResponseRESTOk(http.StatusOK, health.Status{Status: health.StatusOk, Uptime: time.Since(started).Round(time.Second).String()}, req)
+			},
+		},
+		{
+			EndpointDescription: server_http.EndpointDescription{InternalKey: health.InterfaceKeyReadiness, Method: "GET"},
+			WorkerHTTP: func(ctx context.Context, _ server_http.Operator, req *http.Request, _ server_http.PathParams, _ *auth.Identity) (server.Response, error) {
+				status := Check(ctx, joinerOp, timeout)
+				if status.Status != health.StatusOk {
+					return server_http.ResponseRESTOk(http.StatusServiceUnavailable, status, req)
+				}
+				return server_http.ResponseRESTOk(http.StatusOK, status, req)
+			},
+		},
+	}
+}
+
+// Check runs concurrently all health.HealthChecker components joined, each of them is limited with timeout
+func Check(ctx context.Context, joinerOp joiner.Operator, timeout time.Duration) health.Status {
+	components := joinerOp.InterfacesAll((*health.HealthChecker)(nil))
+	sort.Slice(components, func(i, j int) bool { return components[i].InterfaceKey < components[j].InterfaceKey })
+
+	status := health.Status{Status: health.StatusOk, Components: map[joiner.InterfaceKey]health.ComponentStatus{}}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, component := range components {
+		healthChecker, _ := component.Interface.(health.HealthChecker)
+		if healthChecker == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(key joiner.InterfaceKey, healthChecker health.HealthChecker) {
+			defer wg.Done()
+
+			componentStatus := checkComponent(ctx, healthChecker, timeout)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			status.Components[key] = componentStatus
+			if componentStatus.Status != health.StatusOk {
+				status.Status = health.StatusFail
+			}
+		}(component.InterfaceKey, healthChecker)
+	}
+
+	wg.Wait()
+
+	return status
+}
+
+func checkComponent(ctx context.Context, healthChecker health.HealthChecker, timeout time.Duration) (componentStatus health.ComponentStatus) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	started := time.Now()
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				errCh <- fmt.Errorf("panic: %v", rec)
+			}
+		}()
+		errCh <- healthChecker.HealthCheck(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	componentStatus.Duration = time.Since(started).String()
+	if err != nil {
+		componentStatus.Status, componentStatus.Error = health.StatusFail, err.Error()
+	} else {
+		componentStatus.Status = health.StatusOk
+	}
+
+	return componentStatus
+}
@@ -0,0 +1,41 @@
+package health_server_http
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common/health"
+	"github.com/pavlo67/common/common/joiner/joiner_runtime"
+)
+
+type checkerStub func(ctx context.Context) error
+
+func (cs checkerStub) HealthCheck(ctx context.Context) error {
+	return cs(ctx)
+}
+
+func TestCheck(t *testing.T) {
+	joinerOp := joiner_runtime.New(nil, nil)
+
+	require.NoError(t, joinerOp.Join(checkerStub(func(context.Context) error { return nil }), "ok"))
+
+	status := Check(context.Background(), joinerOp, time.Second)
+	require.Equal(t, health.StatusOk, status.Status)
+	require.Equal(t, health.StatusOk, status.Components["ok"].Status)
+
+	require.NoError(t, joinerOp.Join(checkerStub(func(context.Context) error { return errors.New("failed") }), "fail"))
+	require.NoError(t, joinerOp.Join(checkerStub(func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() }), "slow"))
+	require.NoError(t, joinerOp.Join("not a checker", "other"))
+
+	status = Check(context.Background(), joinerOp, 10*time.Millisecond)
+	require.Equal(t, health.StatusFail, status.Status)
+	require.Equal(t, 3, len(status.Components))
+	require.Equal(t, health.StatusOk, status.Components["ok"].Status)
+	require.Equal(t, health.ComponentStatus{Status: health.StatusFail, Error: "failed", Duration: status.Components["fail"].Duration}, status.Components["fail"])
+	require.Equal(t, health.StatusFail, status.Components["slow"].Status)
+	require.Equal(t, context.DeadlineExceeded.Error(), status.Components["slow"].Error)
+}
@@ -0,0 +1,53 @@
+package health_server_http
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/config"
+	"github.com/pavlo67/common/common/joiner"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/starter"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// Starter joins liveness and readiness endpoints with health.InterfaceKeyLiveness and health.InterfaceKeyReadiness keys
+// (their server paths, like /healthz and /readyz, are set in the server config as for any other endpoint), all
+// health.HealthChecker components are found at request time, so the starter order isn't important
+func Starter() starter.Operator {
+	return &healthServerHTTPStarter{}
+}
+
+var l logger.Operator
+var _ starter.Operator = &healthServerHTTPStarter{}
+
+type healthServerHTTPStarter struct {
+	timeout time.Duration
+}
+
+func (hshs *healthServerHTTPStarter) Name() string {
+	return logger.GetCallInfo().PackageName
+}
+
+func (hshs *healthServerHTTPStarter) Prepare(_ *config.Config, options common.Map) error {
+	hshs.timeout = defaultTimeout
+	if timeoutStr, ok := options.String("timeout"); ok {
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return fmt.Errorf("wrong 'timeout' in options (%#v): %s", options, err)
+		}
+		hshs.timeout = timeout
+	}
+
+	return nil
+}
+
+func (hshs *healthServerHTTPStarter) Run(joinerOp joiner.Operator) error {
+	if l, _ = joinerOp.Interface(logger.InterfaceKey).(logger.Operator); l == nil {
+		return fmt.Errorf("no logger.Operator with key %s", logger.InterfaceKey)
+	}
+
+	return Endpoints(joinerOp, hshs.timeout, time.Now()).Join(joinerOp)
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/joiner"
+)
+
+const InterfaceKeyLiveness joiner.InterfaceKey = "health_liveness"
+const InterfaceKeyReadiness joiner.InterfaceKey = "health_readiness"
+
+// InterfaceKeySuffix is added to the key of component that can't implement HealthChecker itself to join its checker
+const InterfaceKeySuffix joiner.InterfaceKey = "_health"
+
+// HealthChecker can be implemented by any joined component to be found by health starter with joiner.Operator.InterfacesAll()
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+const StatusOk = "ok"
+const StatusFail = "fail"
+
+type ComponentStatus struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+type Status struct {
+	Status     string                                  `json:"status"`
+	Uptime     string                                  `json:"uptime,omitempty"`
+	Components map[joiner.InterfaceKey]ComponentStatus `json:"components,omitempty"`
+}
+
+// *sql.DB checker ---------------------------------------------------------------------------------------------------
+
+var _ HealthChecker = &dbChecker{}
+
+type dbChecker struct {
+	db *sql.DB
+}
+
+// DB wraps database connection (as *sql.DB can't implement HealthChecker itself)
+func DB(db *sql.DB) HealthChecker {
+	return &dbChecker{db: db}
+}
+
+func (dc *dbChecker) HealthCheck(ctx context.Context) error {
+	if dc == nil || dc.db == nil {
+		return errors.New("no *sql.DB to check")
+	}
+
+	return dc.db.PingContext(ctx)
+}
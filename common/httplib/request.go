@@ -2,11 +2,15 @@ package httplib
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"strings"
 
 	"github.com/pavlo67/common/common"
 	"github.com/pavlo67/common/common/errors"
@@ -24,6 +28,11 @@ type ResponseBinary struct {
 }
 
 func Request(client *http.Client, serverURL, method string, header http.Header, requestData, responseData interface{}, l logger.Operator) error {
+	return RequestContext(context.Background(), client, serverURL, method, header, requestData, responseData, l)
+}
+
+// RequestContext stops the downstream call when ctx is canceled or its deadline is exceeded
+func RequestContext(ctx context.Context, client *http.Client, serverURL, method string, header http.Header, requestData, responseData interface{}, l logger.Operator) error {
 	if client == nil {
 		client = &http.Client{}
 	}
@@ -58,14 +67,20 @@ func Request(client *http.Client, serverURL, method string, header http.Header,
 		requestBodyReader = bytes.NewBuffer(requestBody)
 	}
 
-	req, err := http.NewRequest(method, serverURL, requestBodyReader)
+	req, err := http.NewRequestWithContext(ctx, method, serverURL, requestBodyReader)
 	if err != nil || req == nil {
 		logger.LogRequest(l, method, serverURL, nil, requestBody, nil, nil, err, 0)
 		return fmt.Errorf(onRequest+": can't create request %s %s, got %#v, %s", method, serverURL, req, err)
 	} else if req.Body != nil {
 		defer Close(req.Body, client, nil)
 	}
-	req.Header = header
+	if header != nil {
+		req.Header = header.Clone()
+	}
+	if req.Header.Get("Accept-Encoding") == "" {
+		// it's set explicitly, so http.Transport doesn't decompress the response itself
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
 	var responseBody []byte
 
 	resp, err := client.Do(req)
@@ -79,14 +94,14 @@ func Request(client *http.Client, serverURL, method string, header http.Header,
 		if resp != nil {
 			statusCode = resp.StatusCode
 			responseHeaders = resp.Header
-			responseBody, _ = ioutil.ReadAll(resp.Body)
+			responseBody, _ = readBody(resp)
 		}
 
 		logger.LogRequest(l, method, serverURL, req.Header, requestBody, responseHeaders, responseBody, err, statusCode)
-		return fmt.Errorf(onRequest+": can't %s %s, got %s", method, serverURL, err)
+		return fmt.Errorf(onRequest+": can't %s %s, got %w", method, serverURL, err)
 	}
 
-	responseBody, err = ioutil.ReadAll(resp.Body)
+	responseBody, err = readBody(resp)
 	logger.LogRequest(l, method, serverURL, req.Header, requestBody, resp.Header, responseBody, err, resp.StatusCode)
 	if err != nil {
 		return fmt.Errorf(onRequest+": can't read body from %s %s, got %s", method, serverURL, err)
@@ -120,6 +135,9 @@ func Request(client *http.Client, serverURL, method string, header http.Header,
 			data["error"] = errCommon
 		}
 		errorKey := errors.Key(data.StringDefault(server.ErrorKey, ""))
+		if errorKey == "" && IsProblem(resp) {
+			errorKey = ProblemKey(data.StringDefault("type", ""))
+		}
 		return errors.CommonError(errorKey, data)
 	}
 
@@ -152,6 +170,36 @@ func Request(client *http.Client, serverURL, method string, header http.Header,
 	return nil
 }
 
+// IsProblem checks if the response is RFC 7807 problem details document
+func IsProblem(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == server.MIMETypeProblemJSON
+}
+
+// ProblemKey gets the error key from the problem type URI (it's the last segment of the URI)
+func ProblemKey(problemType string) errors.Key {
+	if problemType == "" || problemType == "about:blank" {
+		return ""
+	}
+
+	return errors.Key(problemType[strings.LastIndexAny(problemType, ":/#")+1:])
+}
+
+// readBody decompresses gzipped response body
+func readBody(resp *http.Response) ([]byte, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return ioutil.ReadAll(resp.Body)
+	}
+
+	gr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	return ioutil.ReadAll(gr)
+}
+
 //func RequestJSON(method, url string, data []byte, headers map[string]string) (common.Map, error) {
 //	client := &http.Client{}
 //
@@ -1,8 +1,17 @@
 package httplib
 
 import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/pavlo67/common/common"
 	"github.com/pavlo67/common/common/logger/logger_test"
 	"github.com/stretchr/testify/require"
 )
@@ -21,3 +30,64 @@ func TestRequest(t *testing.T) {
 
 	// t.Logf("%s")
 }
+
+func TestRequestContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var responseData []byte
+	err := RequestContext(ctx, nil, srv.URL, "GET", nil, nil, &responseData, logger_test.New(nil))
+	require.Error(t, err)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestRequestGzip(t *testing.T) {
+	expected := map[string]string{"data": strings.Repeat("x", 4096)}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		require.NoError(t, json.NewEncoder(gw).Encode(expected))
+		require.NoError(t, gw.Close())
+	}))
+	defer srv.Close()
+
+	var responseData map[string]string
+	err := Request(nil, srv.URL, "GET", nil, nil, &responseData, logger_test.New(nil))
+	require.NoError(t, err)
+	require.Equal(t, expected, responseData)
+}
+
+func TestRequestProblem(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"type":"https://example.com/problems/not_found","title":"Not Found","status":404,"instance":"abc","id":"1"}`))
+	}))
+	defer srv.Close()
+
+	err := Request(nil, srv.URL, "GET", nil, nil, nil, logger_test.New(nil))
+	require.Error(t, err)
+
+	keyed, _ := err.(interface {
+		Key() common.ErrorKey
+		Data() common.Map
+	})
+	require.NotNil(t, keyed)
+	require.Equal(t, common.NotFoundKey, keyed.Key())
+	require.Equal(t, "1", keyed.Data()["id"])
+	require.Equal(t, "abc", keyed.Data()["instance"])
+
+	require.Equal(t, common.ErrorKey("not_found"), ProblemKey("urn:problem-type:not_found"))
+	require.Empty(t, ProblemKey("about:blank"))
+}
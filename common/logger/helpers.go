@@ -10,6 +10,20 @@ func LogTimestamp(l Operator) {
 	l.Info("\n\ncreated at: " + time.Now().Format(time.RFC3339))
 }
 
+// Logf logs with the level (levels lower than DebugLevel are logged as debug ones, FatalLevel is logged as error one)
+func Logf(l Operator, level Level, template string, args ...interface{}) {
+	switch {
+	case level <= DebugLevel:
+		l.Debugf(template, args...)
+	case level == InfoLevel:
+		l.Infof(template, args...)
+	case level == WarnLevel:
+		l.Warnf(template, args...)
+	default:
+		l.Errorf(template, args...)
+	}
+}
+
 const MaxLoggedDataLength = 2048
 
 func LogRequest(l Operator, method, path string, reqHeaders http.Header, reqBody []byte, respHeaders http.Header, respBody []byte, bodyErr error, status int) {
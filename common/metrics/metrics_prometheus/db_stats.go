@@ -0,0 +1,78 @@
+package metrics_prometheus
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/joiner"
+	"github.com/pavlo67/common/common/metrics"
+)
+
+const onDBStatsCollector = "on metrics_prometheus.DBStatsCollector()"
+
+// DBStatsCollector reports the pool stats of all database connections joined (*sql.DB from db_sqlite, db_pg, etc.),
+// cumulative waits are counters (increased with the difference from the previous collection), others are gauges
+func DBStatsCollector(metricsOp metrics.Operator, joinerOp joiner.Operator) (metrics.Collector, error) {
+	if metricsOp == nil || joinerOp == nil {
+		return nil, errors.New(onDBStatsCollector + ": no metrics.Operator or joiner.Operator")
+	}
+
+	gauges := map[string]metrics.Gauge{}
+	for name, help := range map[string]string{
+		"db_max_open_connections": "Maximum number of open connections to the database.",
+		"db_open_connections":     "Number of established connections both in use and idle.",
+		"db_in_use_connections":   "Number of connections currently in use.",
+		"db_idle_connections":     "Number of idle connections.",
+	} {
+		gauge, err := metricsOp.Gauge(name, help, "db")
+		if err != nil {
+			return nil, errors.CommonError(err, onDBStatsCollector)
+		}
+		gauges[name] = gauge
+	}
+
+	counters := map[string]metrics.Counter{}
+	for name, help := range map[string]string{
+		"db_wait_count_total":            "Total number of connections waited for.",
+		"db_wait_duration_seconds_total": "Total time blocked waiting for a new connection.",
+	} {
+		counter, err := metricsOp.Counter(name, help, "db")
+		if err != nil {
+			return nil, errors.CommonError(err, onDBStatsCollector)
+		}
+		counters[name] = counter
+	}
+
+	// collectors can be called concurrently (on concurrent metrics outputs)
+	statsPrevious := map[string]sql.DBStats{}
+	var mutex sync.Mutex
+
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		for _, component := range joinerOp.InterfacesAll((*metrics.DBStater)(nil)) {
+			dbStater, _ := component.Interface.(metrics.DBStater)
+			if dbStater == nil {
+				continue
+			}
+
+			stats, db := dbStater.Stats(), string(component.InterfaceKey)
+
+			gauges["db_max_open_connections"].Set(float64(stats.MaxOpenConnections), db)
+			gauges["db_open_connections"].Set(float64(stats.OpenConnections), db)
+			gauges["db_in_use_connections"].Set(float64(stats.InUse), db)
+			gauges["db_idle_connections"].Set(float64(stats.Idle), db)
+
+			// totals of the replaced connection (with the same key) are started again
+			previous := statsPrevious[db]
+			if stats.WaitCount < previous.WaitCount || stats.WaitDuration < previous.WaitDuration {
+				previous = sql.DBStats{}
+			}
+			counters["db_wait_count_total"].Add(float64(stats.WaitCount-previous.WaitCount), db)
+			counters["db_wait_duration_seconds_total"].Add((stats.WaitDuration - previous.WaitDuration).Seconds(), db)
+			statsPrevious[db] = stats
+		}
+	}, nil
+}
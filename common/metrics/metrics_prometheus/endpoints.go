@@ -0,0 +1,30 @@
+package metrics_prometheus
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/metrics"
+	"github.com/pavlo67/common/common/server"
+	"github.com/pavlo67/common/common/server/server_http"
+)
+
+func MetricsEndpoint(metricsOp metrics.Operator) server_http.Endpoint {
+	return server_http.Endpoint{
+		EndpointDescription: server_http.EndpointDescription{
+			InternalKey: metrics.InterfaceKeyEndpoint,
+			Method:      "GET",
+		},
+
+		WorkerHTTP: func(_ context.Context, _ server_http.Operator, req *http.Request, _ server_http.PathParams, _ *auth.Identity) (server.Response, error) {
+			var buf bytes.Buffer
+			if err := metricsOp.Write(&buf); err != nil {
+				return server_http.ResponseRESTError(http.StatusInternalServerError, err, req)
+			}
+
+			return server.Response{Status: http.StatusOK, Data: buf.Bytes(), MIMEType: metrics.MIMEType}, nil
+		},
+	}
+}
@@ -0,0 +1,323 @@
+package metrics_prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/metrics"
+)
+
+var _ metrics.Operator = &metricsPrometheus{}
+
+type metricsPrometheus struct {
+	mutex      sync.RWMutex
+	families   map[string]*family
+	collectors []metrics.Collector
+}
+
+func New() (metrics.Operator, error) {
+	return &metricsPrometheus{families: map[string]*family{}}, nil
+}
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+var reName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+var reLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+const onRegister = "on metricsPrometheus.register()"
+
+func (mp *metricsPrometheus) register(name, help, typ string, buckets []float64, labelNames []string) (*family, error) {
+	if !reName.MatchString(name) {
+		return nil, fmt.Errorf(onRegister+": wrong metric name (%s)", name)
+	}
+	for _, labelName := range labelNames {
+		if !reLabelName.MatchString(labelName) || labelName == "le" {
+			return nil, fmt.Errorf(onRegister+": wrong label name (%s) for metric %s", labelName, name)
+		}
+	}
+
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	if f, ok := mp.families[name]; ok {
+		if f.typ != typ || strings.Join(f.labelNames, ",") != strings.Join(labelNames, ",") {
+			return nil, fmt.Errorf(onRegister+": metric %s is already registered as %s%v", name, f.typ, f.labelNames)
+		}
+		return f, nil
+	}
+
+	f := &family{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     map[string]*series{},
+	}
+	mp.families[name] = f
+
+	return f, nil
+}
+
+func (mp *metricsPrometheus) Counter(name, help string, labelNames ...string) (metrics.Counter, error) {
+	f, err := mp.register(name, help, typeCounter, nil, labelNames)
+	if err != nil {
+		return nil, err
+	}
+	return &counter{f}, nil
+}
+
+func (mp *metricsPrometheus) Gauge(name, help string, labelNames ...string) (metrics.Gauge, error) {
+	f, err := mp.register(name, help, typeGauge, nil, labelNames)
+	if err != nil {
+		return nil, err
+	}
+	return &gauge{f}, nil
+}
+
+func (mp *metricsPrometheus) Histogram(name, help string, buckets []float64, labelNames ...string) (metrics.Histogram, error) {
+	if len(buckets) < 1 {
+		buckets = metrics.DefaultBuckets
+	}
+	bucketsSorted := append([]float64{}, buckets...)
+	sort.Float64s(bucketsSorted)
+
+	f, err := mp.register(name, help, typeHistogram, bucketsSorted, labelNames)
+	if err != nil {
+		return nil, err
+	}
+	return &histogram{f}, nil
+}
+
+func (mp *metricsPrometheus) AddCollector(collector metrics.Collector) {
+	if collector == nil {
+		return
+	}
+
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	mp.collectors = append(mp.collectors, collector)
+}
+
+const onWrite = "on metricsPrometheus.Write()"
+
+func (mp *metricsPrometheus) Write(w io.Writer) error {
+	mp.mutex.RLock()
+	collectors := append([]metrics.Collector{}, mp.collectors...)
+	mp.mutex.RUnlock()
+
+	for _, collector := range collectors {
+		collector()
+	}
+
+	mp.mutex.RLock()
+	var names []string
+	for name := range mp.families {
+		names = append(names, name)
+	}
+	mp.mutex.RUnlock()
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		mp.mutex.RLock()
+		f := mp.families[name]
+		mp.mutex.RUnlock()
+
+		f.write(bw)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return errors.CommonError(err, onWrite)
+	}
+
+	return nil
+}
+
+// family ------------------------------------------------------------------------------------------------------------
+
+type family struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+	buckets    []float64
+
+	mutex  sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues  []string
+	value        float64
+	bucketCounts []uint64
+	count        uint64
+}
+
+func (f *family) withSeries(labelValues []string, do func(s *series)) {
+	if len(labelValues) != len(f.labelNames) {
+		return
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string{}, labelValues...)}
+		if f.typ == typeHistogram {
+			s.bucketCounts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+
+	do(s)
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+
+	var keys []string
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+		labels := f.labels(s.labelValues)
+
+		if f.typ != typeHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, labels.String(), formatFloat(s.value))
+			continue
+		}
+
+		var cumulative uint64
+		for i, bucket := range f.buckets {
+			cumulative += s.bucketCounts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, labels.with("le", formatFloat(bucket)).String(), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, labels.with("le", "+Inf").String(), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, labels.String(), formatFloat(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, labels.String(), s.count)
+	}
+}
+
+type labelPairs [][2]string
+
+func (f *family) labels(labelValues []string) labelPairs {
+	var lp labelPairs
+	for i, labelName := range f.labelNames {
+		lp = append(lp, [2]string{labelName, labelValues[i]})
+	}
+	return lp
+}
+
+func (lp labelPairs) with(name, value string) labelPairs {
+	return append(append(labelPairs{}, lp...), [2]string{name, value})
+}
+
+func (lp labelPairs) String() string {
+	if len(lp) < 1 {
+		return ""
+	}
+
+	var pairs []string
+	for _, p := range lp {
+		pairs = append(pairs, p[0]+`="`+escapeLabelValue(p[1])+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// metric types ------------------------------------------------------------------------------------------------------
+
+var _ metrics.Counter = &counter{}
+
+type counter struct {
+	*family
+}
+
+func (c *counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.withSeries(labelValues, func(s *series) { s.value += delta })
+}
+
+var _ metrics.Gauge = &gauge{}
+
+type gauge struct {
+	*family
+}
+
+func (g *gauge) Set(value float64, labelValues ...string) {
+	g.withSeries(labelValues, func(s *series) { s.value = value })
+}
+
+func (g *gauge) Add(delta float64, labelValues ...string) {
+	g.withSeries(labelValues, func(s *series) { s.value += delta })
+}
+
+var _ metrics.Histogram = &histogram{}
+
+type histogram struct {
+	*family
+}
+
+func (h *histogram) Observe(value float64, labelValues ...string) {
+	h.withSeries(labelValues, func(s *series) {
+		for i, bucket := range h.buckets {
+			if value <= bucket {
+				s.bucketCounts[i]++
+				break
+			}
+		}
+		s.value += value
+		s.count++
+	})
+}
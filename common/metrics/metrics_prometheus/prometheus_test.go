@@ -0,0 +1,115 @@
+package metrics_prometheus
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common/joiner/joiner_runtime"
+	"github.com/pavlo67/common/common/logger/logger_test"
+	"github.com/pavlo67/common/common/server/server_http"
+)
+
+func TestWrite(t *testing.T) {
+	metricsOp, err := New()
+	require.NoError(t, err)
+	require.NotNil(t, metricsOp)
+
+	counter, err := metricsOp.Counter("test_total", "Test counter.", "a")
+	require.NoError(t, err)
+	counter.Add(1, "x")
+	counter.Add(2, "x")
+	counter.Add(1, `y"\`)
+	counter.Add(1) // wrong label values number, ignored
+
+	_, err = metricsOp.Gauge("test_total", "Test counter.", "a")
+	require.Error(t, err)
+
+	counterAgain, err := metricsOp.Counter("test_total", "Test counter.", "a")
+	require.NoError(t, err)
+	counterAgain.Add(1, "x")
+
+	gauge, err := metricsOp.Gauge("test_gauge", "")
+	require.NoError(t, err)
+	metricsOp.AddCollector(func() { gauge.Set(7) })
+
+	histogram, err := metricsOp.Histogram("test_seconds", "Test histogram.", []float64{1, 0.1})
+	require.NoError(t, err)
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+
+	var buf bytes.Buffer
+	err = metricsOp.Write(&buf)
+	require.NoError(t, err)
+
+	expected := `# TYPE test_gauge gauge
+test_gauge 7
+# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 1
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 5.55
+test_seconds_count 3
+# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{a="x"} 4
+test_total{a="y\"\\"} 1
+`
+	require.Equal(t, expected, buf.String())
+}
+
+func TestOnAccessMiddleware(t *testing.T) {
+	metricsOp, err := New()
+	require.NoError(t, err)
+
+	onAccess, err := OnAccessMiddleware(metricsOp)
+	require.NoError(t, err)
+
+	onAccess.OnAccess(server_http.AccessRecord{Method: "GET", Key: "test", Status: 404, Bytes: 10, Latency: 20 * time.Millisecond})
+
+	var buf bytes.Buffer
+	err = metricsOp.Write(&buf)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), `http_requests_total{key="test",method="GET",status_class="4xx"} 1`)
+	require.Contains(t, buf.String(), `http_request_duration_seconds_bucket{key="test",le="0.025"} 1`)
+	require.Contains(t, buf.String(), `http_response_bytes_total{key="test"} 10`)
+}
+
+type dbStaterTest struct {
+	stats sql.DBStats
+}
+
+func (dst *dbStaterTest) Stats() sql.DBStats {
+	return dst.stats
+}
+
+func TestDBStatsCollector(t *testing.T) {
+	metricsOp, err := New()
+	require.NoError(t, err)
+
+	joinerOp := joiner_runtime.New(nil, logger_test.New(t))
+	dbStater := &dbStaterTest{stats: sql.DBStats{OpenConnections: 2, WaitCount: 3, WaitDuration: time.Second}}
+	require.NoError(t, joinerOp.Join(dbStater, "test_db"))
+
+	collector, err := DBStatsCollector(metricsOp, joinerOp)
+	require.NoError(t, err)
+	metricsOp.AddCollector(collector)
+
+	var buf bytes.Buffer
+	require.NoError(t, metricsOp.Write(&buf))
+	require.Contains(t, buf.String(), "# TYPE db_open_connections gauge\ndb_open_connections{db=\"test_db\"} 2\n")
+	require.Contains(t, buf.String(), "# TYPE db_wait_count_total counter\ndb_wait_count_total{db=\"test_db\"} 3\n")
+	require.Contains(t, buf.String(), "# TYPE db_wait_duration_seconds_total counter\ndb_wait_duration_seconds_total{db=\"test_db\"} 1\n")
+
+	// counters are increased with the difference only
+	dbStater.stats.WaitCount, dbStater.stats.WaitDuration = 5, 2*time.Second
+	buf.Reset()
+	require.NoError(t, metricsOp.Write(&buf))
+	require.Contains(t, buf.String(), "db_wait_count_total{db=\"test_db\"} 5\n")
+	require.Contains(t, buf.String(), "db_wait_duration_seconds_total{db=\"test_db\"} 2\n")
+}
@@ -0,0 +1,51 @@
+package metrics_prometheus
+
+import (
+	"strconv"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/metrics"
+	"github.com/pavlo67/common/common/server/server_http"
+)
+
+var _ server_http.OnAccessMiddleware = &onAccessMetrics{}
+
+type onAccessMetrics struct {
+	requests  metrics.Counter
+	latencies metrics.Histogram
+	bytes     metrics.Counter
+}
+
+const onOnAccessMiddleware = "on metrics_prometheus.OnAccessMiddleware()"
+
+// OnAccessMiddleware records per-endpoint request count (by status class), latency and response size
+func OnAccessMiddleware(metricsOp metrics.Operator) (server_http.OnAccessMiddleware, error) {
+	if metricsOp == nil {
+		return nil, errors.New(onOnAccessMiddleware + ": no metrics.Operator")
+	}
+
+	requests, err := metricsOp.Counter("http_requests_total", "Number of HTTP requests by endpoint key, method and status class.", "key", "method", "status_class")
+	if err != nil {
+		return nil, errors.CommonError(err, onOnAccessMiddleware)
+	}
+
+	latencies, err := metricsOp.Histogram("http_request_duration_seconds", "HTTP request latencies by endpoint key.", metrics.DefaultBuckets, "key")
+	if err != nil {
+		return nil, errors.CommonError(err, onOnAccessMiddleware)
+	}
+
+	bytes, err := metricsOp.Counter("http_response_bytes_total", "Number of HTTP response body bytes by endpoint key.", "key")
+	if err != nil {
+		return nil, errors.CommonError(err, onOnAccessMiddleware)
+	}
+
+	return &onAccessMetrics{requests: requests, latencies: latencies, bytes: bytes}, nil
+}
+
+func (oam *onAccessMetrics) OnAccess(accessRecord server_http.AccessRecord) {
+	key := string(accessRecord.Key)
+
+	oam.requests.Add(1, key, accessRecord.Method, strconv.Itoa(accessRecord.Status/100)+"xx")
+	oam.latencies.Observe(accessRecord.Latency.Seconds(), key)
+	oam.bytes.Add(float64(accessRecord.Bytes), key)
+}
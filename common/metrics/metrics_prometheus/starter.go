@@ -0,0 +1,86 @@
+package metrics_prometheus
+
+import (
+	"fmt"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/config"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/joiner"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/metrics"
+	"github.com/pavlo67/common/common/server/server_http"
+	"github.com/pavlo67/common/common/starter"
+)
+
+// Starter should be run before the server_http.Operator starter to record the requests metrics,
+// the endpoint is joined with metrics.InterfaceKeyEndpoint key and should be settled in server_http.Config (e.g. with Path: "/metrics")
+func Starter() starter.Operator {
+	return &metricsPrometheusStarter{}
+}
+
+var l logger.Operator
+var _ starter.Operator = &metricsPrometheusStarter{}
+
+type metricsPrometheusStarter struct {
+	interfaceKey        joiner.InterfaceKey
+	endpointKey         joiner.InterfaceKey
+	onAccessKey         joiner.InterfaceKey
+	noServerHTTPMetrics bool
+	noDBStatsMetrics    bool
+}
+
+func (mps *metricsPrometheusStarter) Name() string {
+	return logger.GetCallInfo().PackageName
+}
+
+func (mps *metricsPrometheusStarter) Prepare(_ *config.Config, options common.Map) error {
+	mps.interfaceKey = joiner.InterfaceKey(options.StringDefault("interface_key", string(metrics.InterfaceKey)))
+	mps.endpointKey = joiner.InterfaceKey(options.StringDefault("endpoint_key", string(metrics.InterfaceKeyEndpoint)))
+	mps.onAccessKey = joiner.InterfaceKey(options.StringDefault("on_access_key", string(server_http.OnAccessMiddlewareInterfaceKey)))
+	mps.noServerHTTPMetrics = options.IsTrue("no_server_http_metrics")
+	mps.noDBStatsMetrics = options.IsTrue("no_db_stats_metrics")
+
+	return nil
+}
+
+func (mps *metricsPrometheusStarter) Run(joinerOp joiner.Operator) error {
+	if l, _ = joinerOp.Interface(logger.InterfaceKey).(logger.Operator); l == nil {
+		return fmt.Errorf("no logger.Operator with key %s", logger.InterfaceKey)
+	}
+
+	metricsOp, err := New()
+	if err != nil {
+		return errors.CommonError(err, "can't init *metricsPrometheus{} as metrics.Operator")
+	}
+
+	if err = joinerOp.Join(metricsOp, mps.interfaceKey); err != nil {
+		return errors.CommonError(err, fmt.Sprintf("can't join *metricsPrometheus{} as metrics.Operator with key '%s'", mps.interfaceKey))
+	}
+
+	if !mps.noServerHTTPMetrics {
+		onAccess, err := OnAccessMiddleware(metricsOp)
+		if err != nil {
+			return err
+		}
+		if err = joinerOp.Join(onAccess, mps.onAccessKey); err != nil {
+			return errors.CommonError(err, fmt.Sprintf("can't join *onAccessMetrics{} as server_http.OnAccessMiddleware with key '%s'", mps.onAccessKey))
+		}
+	}
+
+	if !mps.noDBStatsMetrics {
+		collector, err := DBStatsCollector(metricsOp, joinerOp)
+		if err != nil {
+			return err
+		}
+		metricsOp.AddCollector(collector)
+	}
+
+	endpoint := MetricsEndpoint(metricsOp)
+	endpoint.InternalKey = mps.endpointKey
+	if err = joinerOp.Join(&endpoint, mps.endpointKey); err != nil {
+		return errors.CommonError(err, fmt.Sprintf("can't join metrics endpoint as server_http.Endpoint with key '%s'", mps.endpointKey))
+	}
+
+	return nil
+}
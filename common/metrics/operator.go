@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"database/sql"
+	"io"
+
+	"github.com/pavlo67/common/common/joiner"
+)
+
+const InterfaceKey joiner.InterfaceKey = "metrics"
+const InterfaceKeyEndpoint joiner.InterfaceKey = "metrics_endpoint"
+
+const MIMEType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the histogram buckets (in seconds) suitable for request latencies
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counter, Gauge and Histogram ignore observations with the number of label values different from the registered label names
+
+type Counter interface {
+	Add(delta float64, labelValues ...string)
+}
+
+type Gauge interface {
+	Set(value float64, labelValues ...string)
+	Add(delta float64, labelValues ...string)
+}
+
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+// Collector is called before each metrics output to refresh the gauges with current values
+type Collector func()
+
+type Operator interface {
+	// Counter, Gauge and Histogram return the metric registered before if it has the same name, type and label names
+	Counter(name, help string, labelNames ...string) (Counter, error)
+	Gauge(name, help string, labelNames ...string) (Gauge, error)
+	Histogram(name, help string, buckets []float64, labelNames ...string) (Histogram, error)
+
+	AddCollector(collector Collector)
+
+	// Write outputs all metrics in Prometheus text exposition format
+	Write(w io.Writer) error
+}
+
+// DBStater is implemented by *sql.DB, so all joined database connections can be found with joiner.Operator.InterfacesAll()
+type DBStater interface {
+	Stats() sql.DBStats
+}
+
+var _ DBStater = &sql.DB{}
@@ -1,10 +1,107 @@
 package server
 
+import (
+	"path/filepath"
+	"time"
+)
+
 // Config ...
 type Config struct {
-	Port        int    `yaml:"port"          json:"port"`
-	NoHTTPS     bool   `yaml:"no_https"      json:"no_https"`
+	Port    int  `yaml:"port"          json:"port"`
+	NoHTTPS bool `yaml:"no_https"      json:"no_https"` // all TLS settings are ignored if it's true
+
+	// KeyPath is the directory for TLS files set with relative paths
 	KeyPath     string `yaml:"key_path"      json:"key_path"`
 	TLSCertFile string `yaml:"tls_cert_file" json:"tls_cert_file"`
 	TLSKeyFile  string `yaml:"tls_key_file"  json:"tls_key_file"`
+
+	// TLSMinVersion is "1.2" (default) or "1.3"
+	TLSMinVersion string `yaml:"tls_min_version"   json:"tls_min_version"`
+
+	// TLSCipherSuites are names from crypto/tls (like "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"), they are used for TLS 1.2 only
+	TLSCipherSuites []string `yaml:"tls_cipher_suites" json:"tls_cipher_suites"`
+
+	// TLSClientCAFile is the CA bundle to verify client certificates with
+	TLSClientCAFile string `yaml:"tls_client_ca_file" json:"tls_client_ca_file"`
+
+	// TLSClientAuth is "require" (default if TLSClientCAFile is set) or "verify_if_given"
+	TLSClientAuth string `yaml:"tls_client_auth"    json:"tls_client_auth"`
+
+	// HTTPRedirectPort is the port of additional plain HTTP listener redirecting all requests to HTTPS
+	HTTPRedirectPort int `yaml:"http_redirect_port" json:"http_redirect_port"`
+
+	// CompressionMinSize is the min response size to be gzipped (1024 by default, negative value disables compression)
+	CompressionMinSize int `yaml:"compression_min_size" json:"compression_min_size"`
+
+	// CompressionMIMETypes are response types allowed to be gzipped ("type/*" masks are allowed too)
+	CompressionMIMETypes []string `yaml:"compression_mime_types" json:"compression_mime_types"`
+
+	// RateLimit is optional, no requests are limited without it
+	RateLimit *RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
+
+	// IdempotencyTTL is the time the responses of idempotent endpoints are stored for (24h by default)
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl" json:"idempotency_ttl"`
+
+	// IdempotencyMaxBodySize limits the request body of idempotent endpoints, it's read to the memory to be hashed
+	// (1MiB by default, negative value disables the limit)
+	IdempotencyMaxBodySize int64 `yaml:"idempotency_max_body_size" json:"idempotency_max_body_size"`
+
+	// IdempotencyMaxItems limits the number of records kept by the in-memory idempotency store (10000 by default),
+	// the earliest started ones are evicted
+	IdempotencyMaxItems int `yaml:"idempotency_max_items" json:"idempotency_max_items"`
+
+	// ResponseCacheMaxItems limits the number of responses cached for endpoints with CacheTTL (10000 by default,
+	// negative value disables the cache)
+	ResponseCacheMaxItems int `yaml:"response_cache_max_items" json:"response_cache_max_items"`
+
+	// ProblemJSON turns error responses into RFC 7807 problem details (application/problem+json)
+	ProblemJSON bool `yaml:"problem_json" json:"problem_json"`
+
+	// ProblemTypeBase is the prefix of problem type URIs ("urn:problem-type:" by default), the error key is appended to it
+	ProblemTypeBase string `yaml:"problem_type_base" json:"problem_type_base"`
+
+	// WebSocketOrigins are origins (besides the server's own one) allowed to open WebSocket connections, like
+	// "https://example.com" ("*" allows any origin)
+	WebSocketOrigins []string `yaml:"websocket_origins" json:"websocket_origins"`
+
+	// SecretENVs are values of ENV environment variable where error details aren't responded ("production" by default),
+	// details are hidden if ENV isn't set too
+	SecretENVs []string `yaml:"secret_envs" json:"secret_envs"`
+}
+
+type RateLimitConfig struct {
+	// Global limits all requests of each client (independently of endpoints)
+	Global *RateLimitRule `yaml:"global" json:"global"`
+
+	// Endpoints limit requests of each client to the endpoint with the key
+	Endpoints map[string]RateLimitRule `yaml:"endpoints" json:"endpoints"`
+
+	// MaxClients limits the number of remembered clients (for each rule), least recently seen ones are evicted
+	MaxClients int `yaml:"max_clients" json:"max_clients"`
+
+	// TrustedProxies are IPs or CIDRs allowed to set X-Forwarded-For header
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies"`
+}
+
+// RateLimitRule allows Requests per Period on average and up to Burst requests at once (Burst = Requests by default)
+type RateLimitRule struct {
+	Requests int           `yaml:"requests" json:"requests"`
+	Period   time.Duration `yaml:"period"   json:"period"`
+	Burst    int           `yaml:"burst"    json:"burst"`
+}
+
+const TLSClientAuthRequire = "require"
+const TLSClientAuthVerifyIfGiven = "verify_if_given"
+
+func (c Config) HTTPS() bool {
+	return !c.NoHTTPS && c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// FilePath resolves the path relative to KeyPath
+func (c Config) FilePath(path string) string {
+	if path == "" || c.KeyPath == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(c.KeyPath, path)
 }
@@ -2,6 +2,8 @@ package server
 
 const ErrorKey = "error_key"
 
+const MIMETypeProblemJSON = "application/problem+json"
+
 type ResponseFinished struct {
 	Response Response
 	Error    error
@@ -0,0 +1,27 @@
+package server_http
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pavlo67/common/common/auth"
+)
+
+// AccessRecord is the single line of access log written for each request
+type AccessRecord struct {
+	RequestID  string
+	Method     string
+	Key        EndpointKey
+	Status     int
+	Bytes      int
+	Latency    time.Duration
+	IdentityID auth.ID
+	RemoteIP   string
+}
+
+func (ar AccessRecord) String() string {
+	return fmt.Sprintf(
+		"access: request_id=%s method=%s key=%s status=%d bytes=%d latency=%s identity_id=%q remote_ip=%s",
+		ar.RequestID, ar.Method, ar.Key, ar.Status, ar.Bytes, ar.Latency, ar.IdentityID, ar.RemoteIP,
+	)
+}
@@ -0,0 +1,215 @@
+package server_http
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/joiner"
+	"github.com/pavlo67/common/common/server"
+)
+
+const DefaultResponseCacheMaxItems = 10000
+
+const CacheStatusHeader = "X-Cache"
+
+// ResponseCacheInterfaceKey is used by server_http.Operator starters to get the custom ResponseCache (the in-memory one
+// is used without it), join it to invalidate the cached responses outside of endpoint workers
+const ResponseCacheInterfaceKey joiner.InterfaceKey = "server_http_response_cache"
+
+// ResponseCache keeps successful responses of GET endpoints with EndpointDescription.CacheTTL
+type ResponseCache interface {
+	Get(key EndpointKey, requestKey string) *server.Response
+	Set(key EndpointKey, requestKey string, response server.Response, ttl time.Duration)
+
+	// Invalidate removes all cached responses of endpoints with keys
+	Invalidate(keys ...EndpointKey)
+}
+
+// ResponseCacheRequestKey identifies the request to the endpoint with path params, query, identity and accepted MIME types
+// (because the response content is negotiated)
+func ResponseCacheRequestKey(r *http.Request, params PathParams, identity *auth.Identity) string {
+	var paramsKeys []string
+	for k := range params {
+		paramsKeys = append(paramsKeys, k)
+	}
+	sort.Strings(paramsKeys)
+
+	parts := make([]string, 0, 2*len(paramsKeys)+3)
+	for _, k := range paramsKeys {
+		parts = append(parts, k, params[k])
+	}
+
+	var identityID auth.ID
+	if identity != nil {
+		identityID = identity.ID
+	}
+	parts = append(parts, r.URL.Query().Encode(), string(identityID), r.Header.Get("Accept"))
+
+	hash := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(hash[:])
+}
+
+// ETag returns the strong entity tag for the response data
+func ETag(data []byte) string {
+	hash := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// ETagEncoded appends the content coding suffix to the entity tag of the not encoded data, so the encoded representation
+// of the response has its own tag (as RFC 7232 requires)
+func ETagEncoded(etag, encoding string) string {
+	if encoding == "" || !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+
+	return etag[:len(etag)-1] + "-" + encoding + `"`
+}
+
+// ETagMatches checks the value of If-None-Match header (weak comparison is used as RFC 7232 requires)
+func ETagMatches(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notModified sets ETag (and Cache-Control if it's defined) headers for successful response and checks
+// if the client has the same response already
+func notModified(w http.ResponseWriter, r *http.Request, responseData server.Response, encoding, cacheControl string) bool {
+	if responseData.Status != 0 && responseData.Status != http.StatusOK {
+		return false
+	}
+
+	etag := ETagEncoded(ETag(responseData.Data), encoding)
+	w.Header().Set("ETag", etag)
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	return ifNoneMatch != "" && ETagMatches(ifNoneMatch, etag)
+}
+
+func writeNotModified(w http.ResponseWriter) (status, bytes int) {
+	w.Header().Del("Content-Encoding")
+	w.WriteHeader(http.StatusNotModified)
+
+	return http.StatusNotModified, 0
+}
+
+// workCached returns the cached response (if any) or calls workerHTTP and caches its successful response
+func workCached(ctx context.Context, serverOp Operator, w http.ResponseWriter, key EndpointKey, ttl time.Duration, workerHTTP WorkerHTTP, r *http.Request, params PathParams, identity *auth.Identity, cache ResponseCache) (server.Response, error) {
+	requestKey := ResponseCacheRequestKey(r, params, identity)
+	if response := cache.Get(key, requestKey); response != nil {
+		w.Header().Set(CacheStatusHeader, "HIT")
+		return *response, nil
+	}
+	w.Header().Set(CacheStatusHeader, "MISS")
+
+	responseData, err := work(ctx, serverOp, workerHTTP, r, params, identity)
+	if err == nil && (responseData.Status == 0 || responseData.Status == http.StatusOK) {
+		cache.Set(key, requestKey, responseData, ttl)
+	}
+
+	return responseData, err
+}
+
+// in-memory response cache -------------------------------------------------------------------------------------------
+
+var _ ResponseCache = &responseCache{}
+
+type responseCache struct {
+	maxItems int
+	items    map[EndpointKey]map[string]*list.Element
+	lru      *list.List
+	now      func() time.Time
+
+	mutex sync.Mutex
+}
+
+type cachedResponse struct {
+	key        EndpointKey
+	requestKey string
+	response   server.Response
+	expires    time.Time
+}
+
+// NewResponseCache creates the in-memory cache evicting the least recently used responses if there are more than maxItems
+func NewResponseCache(maxItems int) ResponseCache {
+	if maxItems <= 0 {
+		maxItems = DefaultResponseCacheMaxItems
+	}
+
+	return &responseCache{maxItems: maxItems, items: map[EndpointKey]map[string]*list.Element{}, lru: list.New(), now: time.Now}
+}
+
+func (rc *responseCache) Get(key EndpointKey, requestKey string) *server.Response {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	element, ok := rc.items[key][requestKey]
+	if !ok {
+		return nil
+	}
+
+	cached := element.Value.(*cachedResponse)
+	if !cached.expires.After(rc.now()) {
+		rc.remove(element)
+		return nil
+	}
+	rc.lru.MoveToFront(element)
+
+	response := cached.response
+	return &response
+}
+
+func (rc *responseCache) Set(key EndpointKey, requestKey string, response server.Response, ttl time.Duration) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if element, ok := rc.items[key][requestKey]; ok {
+		rc.remove(element)
+	}
+
+	for rc.lru.Len() >= rc.maxItems {
+		rc.remove(rc.lru.Back())
+	}
+
+	if rc.items[key] == nil {
+		rc.items[key] = map[string]*list.Element{}
+	}
+	rc.items[key][requestKey] = rc.lru.PushFront(&cachedResponse{key: key, requestKey: requestKey, response: response, expires: rc.now().Add(ttl)})
+}
+
+func (rc *responseCache) Invalidate(keys ...EndpointKey) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	for _, key := range keys {
+		for _, element := range rc.items[key] {
+			rc.lru.Remove(element)
+		}
+		delete(rc.items, key)
+	}
+}
+
+func (rc *responseCache) remove(element *list.Element) {
+	cached := rc.lru.Remove(element).(*cachedResponse)
+	delete(rc.items[cached.key], cached.requestKey)
+	if len(rc.items[cached.key]) < 1 {
+		delete(rc.items, cached.key)
+	}
+}
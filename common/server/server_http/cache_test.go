@@ -0,0 +1,149 @@
+package server_http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/logger/logger_zap"
+	"github.com/pavlo67/common/common/server"
+)
+
+func TestETagMatches(t *testing.T) {
+	etag := ETag([]byte("data"))
+	require.NotEqual(t, etag, ETag([]byte("another data")))
+
+	require.True(t, ETagMatches(etag, etag))
+	require.True(t, ETagMatches(`"a", W/`+etag, etag))
+	require.True(t, ETagMatches("*", etag))
+	require.False(t, ETagMatches(`"a", "b"`, etag))
+
+	gzipETag := ETagEncoded(etag, "gzip")
+	require.Equal(t, etag[:len(etag)-1]+`-gzip"`, gzipETag)
+	require.Equal(t, etag, ETagEncoded(etag, ""))
+	require.False(t, ETagMatches(etag, gzipETag))
+}
+
+func TestHandleNotModifiedCompressed(t *testing.T) {
+	l, err := logger_zap.New(logger.Config{})
+	require.NoError(t, err)
+
+	settings, err := HandleSettingsFromConfig(server.Config{}, onRequestMiddlewareTest(), nil, l)
+	require.NoError(t, err)
+
+	data := []byte(strings.Repeat("data ", DefaultCompressionMinSize))
+	handle := Handle(nil, "test", Endpoint{
+		EndpointDescription: EndpointDescription{Method: "GET"},
+		WorkerHTTP: func(_ context.Context, _ Operator, _ *http.Request, _ PathParams, _ *auth.Identity) (server.Response, error) {
+			return server.Response{Status: http.StatusOK, Data: data, MIMEType: "text/plain"}, nil
+		},
+	}, settings)
+
+	request := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		handle(w, req, nil)
+		return w
+	}
+
+	w := request("")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	require.Equal(t, ETagEncoded(ETag(data), "gzip"), w.Header().Get("ETag"))
+
+	w = request(w.Header().Get("ETag"))
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.Empty(t, w.Body.Bytes())
+
+	// the tag of the identity representation doesn't match the gzipped one
+	w = request(ETag(data))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+}
+
+func TestResponseCache(t *testing.T) {
+	now := time.Now()
+	rc := NewResponseCache(2).(*responseCache)
+	rc.now = func() time.Time { return now }
+
+	rc.Set("a", "1", server.Response{Data: []byte("a1")}, time.Minute)
+	rc.Set("a", "2", server.Response{Data: []byte("a2")}, time.Minute)
+	require.Equal(t, []byte("a1"), rc.Get("a", "1").Data)
+
+	// "a"/"2" is the least recently used one
+	rc.Set("b", "1", server.Response{Data: []byte("b1")}, time.Second)
+	require.Nil(t, rc.Get("a", "2"))
+	require.NotNil(t, rc.Get("a", "1"))
+	require.NotNil(t, rc.Get("b", "1"))
+
+	rc.Invalidate("a")
+	require.Nil(t, rc.Get("a", "1"))
+	require.NotNil(t, rc.Get("b", "1"))
+
+	now = now.Add(time.Second)
+	require.Nil(t, rc.Get("b", "1"))
+	require.Equal(t, 0, rc.lru.Len())
+}
+
+func TestHandleCached(t *testing.T) {
+	l, err := logger_zap.New(logger.Config{})
+	require.NoError(t, err)
+
+	settings, err := HandleSettingsFromConfig(server.Config{}, onRequestMiddlewareTest(), nil, l)
+	require.NoError(t, err)
+
+	var calls int
+	handleGet := Handle(nil, "test_get", Endpoint{
+		EndpointDescription: EndpointDescription{Method: "GET", CacheTTL: time.Minute, CacheControl: "max-age=60"},
+		WorkerHTTP: func(_ context.Context, _ Operator, req *http.Request, _ PathParams, _ *auth.Identity) (server.Response, error) {
+			calls++
+			return ResponseRESTOk(0, calls, req)
+		},
+	}, settings)
+	handlePost := Handle(nil, "test_post", Endpoint{
+		EndpointDescription: EndpointDescription{Method: "POST", Invalidates: []EndpointKey{"test_get"}},
+		WorkerHTTP: func(_ context.Context, _ Operator, req *http.Request, _ PathParams, _ *auth.Identity) (server.Response, error) {
+			return ResponseRESTOk(0, nil, req)
+		},
+	}, settings)
+
+	get := func(query string, params PathParams) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handleGet(w, httptest.NewRequest("GET", "/?"+query, nil), params)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "max-age=60", w.Header().Get("Cache-Control"))
+		require.Equal(t, []string{"Accept, Accept-Language"}, w.Header().Values("Vary"))
+		return w
+	}
+
+	w := get("a=1&b=2", PathParams{"p": "1"})
+	require.Equal(t, "MISS", w.Header().Get(CacheStatusHeader))
+	require.Equal(t, "1", w.Body.String())
+
+	w = get("b=2&a=1", PathParams{"p": "1"})
+	require.Equal(t, "HIT", w.Header().Get(CacheStatusHeader))
+	require.Equal(t, "1", w.Body.String())
+
+	w = get("a=1&b=2", PathParams{"p": "2"})
+	require.Equal(t, "MISS", w.Header().Get(CacheStatusHeader))
+	require.Equal(t, "2", w.Body.String())
+
+	handlePost(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil), nil)
+
+	w = get("a=1&b=2", PathParams{"p": "1"})
+	require.Equal(t, "MISS", w.Header().Get(CacheStatusHeader))
+	require.Equal(t, strconv.Itoa(calls), w.Body.String())
+}
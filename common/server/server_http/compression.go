@@ -0,0 +1,124 @@
+package server_http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/pavlo67/common/common/server"
+)
+
+const DefaultCompressionMinSize = 1024
+
+var DefaultCompressionMIMETypes = []string{
+	MIMETypeJSON, MIMETypeYAML, MIMETypeCSV, "application/javascript", "application/xml", "image/svg+xml", "text/*",
+}
+
+// Compression configures gzip encoding of responses, nil *Compression means no compression
+type Compression struct {
+	MinSize   int      // responses are compressed if they are not shorter
+	MIMETypes []string // allowed types, "type/*" masks are allowed too
+}
+
+// CompressionFromConfig returns nil if compression is disabled in cfg (with negative CompressionMinSize)
+func CompressionFromConfig(cfg server.Config) *Compression {
+	if cfg.CompressionMinSize < 0 {
+		return nil
+	}
+
+	compression := Compression{MinSize: cfg.CompressionMinSize, MIMETypes: cfg.CompressionMIMETypes}
+	if compression.MinSize == 0 {
+		compression.MinSize = DefaultCompressionMinSize
+	}
+	if len(compression.MIMETypes) < 1 {
+		compression.MIMETypes = DefaultCompressionMIMETypes
+	}
+
+	return &compression
+}
+
+func (c *Compression) allowed(mimeType string) bool {
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = mediaType
+	}
+
+	for _, allowed := range c.MIMETypes {
+		if allowed == mimeType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mimeType, allowed[:len(allowed)-1])) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Compress gzips responseData if it's accepted by client (with Accept-Encoding header of req) and allowed by settings,
+// it sets the response headers (so MIME type is detected before compression if it's empty)
+func (c *Compression) Compress(w http.ResponseWriter, req *http.Request, responseData server.Response) server.Response {
+	responseData, encoding := c.Encoding(w, req, responseData)
+	return Encode(w, responseData, encoding)
+}
+
+// Encoding returns the content coding responseData should be sent with ("gzip" or "" if it isn't compressed), it detects
+// MIME type of responseData (if it's empty) and sets Vary header but doesn't compress the data (see Encode)
+func (c *Compression) Encoding(w http.ResponseWriter, req *http.Request, responseData server.Response) (server.Response, string) {
+	if c == nil || req == nil || len(responseData.Data) < c.MinSize || w.Header().Get("Content-Encoding") != "" {
+		return responseData, ""
+	}
+
+	if responseData.MIMEType == "" {
+		responseData.MIMEType = http.DetectContentType(responseData.Data)
+	}
+	if !c.allowed(responseData.MIMEType) {
+		return responseData, ""
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(req.Header.Get("Accept-Encoding")) {
+		return responseData, ""
+	}
+
+	return responseData, "gzip"
+}
+
+// Encode compresses responseData with the encoding returned by Compression.Encoding and sets Content-Encoding header,
+// responseData is returned as is if the encoding is empty (or can't be applied)
+func Encode(w http.ResponseWriter, responseData server.Response, encoding string) server.Response {
+	if encoding != "gzip" {
+		return responseData
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(responseData.Data); err != nil {
+		return responseData
+	}
+	if err := gw.Close(); err != nil {
+		return responseData
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	responseData.Data = buf.Bytes()
+
+	return responseData
+}
+
+// acceptsGzip checks q values: explicit "gzip;q=0" refuses gzip even if "*" is accepted
+func acceptsGzip(acceptEncoding string) bool {
+	gzipQ, anyQ := -1.0, -1.0
+	for _, encoding := range parseAccept(acceptEncoding) {
+		switch encoding.mimeType {
+		case "gzip", "x-gzip":
+			gzipQ = encoding.q
+		case "*":
+			anyQ = encoding.q
+		}
+	}
+
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+
+	return anyQ > 0
+}
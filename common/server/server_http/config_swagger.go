@@ -4,10 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
 	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/server"
 )
 
 type Config struct {
@@ -42,9 +46,14 @@ func (c Config) SwaggerV2(isHTTPS bool) ([]byte, error) {
 
 		if len(ep.Produces) >= 1 {
 			epDescr["produces"] = ep.Produces
+		} else if ep.Endpoint.WorkerSSE != nil {
+			epDescr["produces"] = []string{SSEMIMEType}
 		} else {
 			epDescr["produces"] = []string{"application/json"}
 		}
+		if ep.Endpoint.WorkerWebSocket != nil {
+			epDescr["x-websocket"] = true
+		}
 
 		var parameters []interface{} // []map[string]interface{}
 
@@ -77,6 +86,19 @@ func (c Config) SwaggerV2(isHTTPS bool) ([]byte, error) {
 			)
 		}
 
+		if ep.Endpoint.Idempotent {
+			parameters = append(
+				parameters,
+				common.Map{
+					"in":          "header",
+					"required":    false,
+					"name":        IdempotencyHeader,
+					"type":        "string",
+					"description": "the stored response is replayed for the repeated request with the same key",
+				},
+			)
+		}
+
 		if method == "post" {
 			if len(ep.Endpoint.BodyParams) > 0 {
 				parameters = append(parameters, ep.Endpoint.BodyParams)
@@ -94,6 +116,8 @@ func (c Config) SwaggerV2(isHTTPS bool) ([]byte, error) {
 			epDescr["parameters"] = parameters
 		}
 
+		epDescr["responses"] = swaggerResponses(ep.Endpoint.ErrorKeys)
+
 		if epDescrPrev, ok := paths[path][method]; ok {
 			return nil, fmt.Errorf("duplicate endpoint description (%s %s): \n%#v\nvs.\n%#v", method, path, epDescrPrev, epDescr)
 		}
@@ -121,11 +145,53 @@ func (c Config) SwaggerV2(isHTTPS bool) ([]byte, error) {
 		"schemes": schemes,
 		"port":    c.Port,
 		"paths":   paths,
+		"definitions": common.Map{
+			"Error": swaggerErrorSchema(),
+		},
 	}
 
 	return json.MarshalIndent(swagger, "", " ")
 }
 
+const swaggerErrorRef = "#/definitions/Error"
+
+// swaggerResponses groups errorKeys by their registered statuses
+func swaggerResponses(errorKeys []common.ErrorKey) common.Map {
+	errorsByStatus := map[int][]string{}
+	for _, key := range errorKeys {
+		status, message := http.StatusInternalServerError, ""
+		if description, ok := errors.Describe(key); ok {
+			if description.Status > 0 {
+				status = description.Status
+			}
+			message = description.Message()
+		}
+		errorsByStatus[status] = append(errorsByStatus[status], strings.TrimSuffix(string(key)+": "+message, ": "))
+	}
+
+	responses := common.Map{
+		"200":     common.Map{"description": "OK"},
+		"default": common.Map{"description": "error", "schema": common.Map{"$ref": swaggerErrorRef}},
+	}
+	for status, errorDescriptions := range errorsByStatus {
+		responses[strconv.Itoa(status)] = common.Map{"description": strings.Join(errorDescriptions, "; "), "schema": common.Map{"$ref": swaggerErrorRef}}
+	}
+
+	return responses
+}
+
+// swaggerErrorSchema describes the error response with all registered error keys
+func swaggerErrorSchema() common.Map {
+	return common.Map{
+		"type": "object",
+		"properties": common.Map{
+			server.ErrorKey: common.Map{"type": "string", "enum": errors.RegisteredKeys()},
+			"message":       common.Map{"type": "string"},
+			"details":       common.Map{"type": "string"},
+		},
+	}
+}
+
 func (c Config) InitSwagger(isHTTPS bool, swaggerStaticFilePath string, l logger.Operator) error {
 	//if c == nil {
 	//	return nil
@@ -2,7 +2,12 @@ package server_http
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
 	"github.com/pavlo67/common/common/joiner"
 )
 
@@ -12,6 +17,17 @@ type EndpointDescription struct {
 	PathParams  []string            `json:",omitempty"`
 	QueryParams []string            `json:",omitempty"`
 	BodyParams  json.RawMessage     `json:",omitempty"`
+	Timeout     time.Duration       `json:",omitempty"`
+	KeepAlive   time.Duration       `json:",omitempty"` // for SSE endpoints only
+	Idempotent  bool                `json:",omitempty"` // the response is replayed for the repeated request with the same Idempotency-Key header
+
+	CacheControl string        `json:",omitempty"` // Cache-Control header value for successful GET responses
+	CacheTTL     time.Duration `json:",omitempty"` // successful GET responses are cached on the server side if it's set
+	Invalidates  []EndpointKey `json:",omitempty"` // cached responses of these endpoints are removed after the successful request
+
+	AllowedOrigins []string `json:",omitempty"` // added to server.Config.WebSocketOrigins for WebSocket endpoint (see CheckOrigin())
+
+	ErrorKeys []common.ErrorKey `json:",omitempty"` // errors the endpoint can respond with (for Swagger only)
 }
 
 type EndpointKey = joiner.InterfaceKey
@@ -24,7 +40,44 @@ type EndpointSettled struct {
 	Endpoint
 }
 
+// Endpoint must have exactly one worker: WorkerHTTP for ordinary requests, WorkerSSE for Server-Sent Events stream
+// or WorkerWebSocket for WebSocket connection (two last ones are available for GET method only)
 type Endpoint struct {
 	EndpointDescription
 	WorkerHTTP
+	WorkerSSE
+	WorkerWebSocket
+}
+
+// Check verifies the endpoint has exactly one worker suitable for its method
+func (ep Endpoint) Check() error {
+	var workers int
+	if ep.WorkerHTTP != nil {
+		workers++
+	}
+	if ep.WorkerSSE != nil || ep.WorkerWebSocket != nil {
+		if ep.WorkerSSE != nil && ep.WorkerWebSocket != nil {
+			workers++
+		}
+		if strings.ToUpper(ep.Method) != "GET" {
+			return fmt.Errorf("streaming endpoint requires GET method, not %s", ep.Method)
+		}
+		if ep.Idempotent || ep.CacheTTL > 0 {
+			return errors.New("streaming endpoint can't be idempotent or cached")
+		}
+		workers++
+	}
+
+	if ep.CacheTTL > 0 && strings.ToUpper(ep.Method) != "GET" {
+		return fmt.Errorf("only GET endpoint can be cached, not %s", ep.Method)
+	}
+
+	switch workers {
+	case 0:
+		return errors.New("no worker for endpoint")
+	case 1:
+		return nil
+	}
+
+	return errors.New("too many workers for endpoint")
 }
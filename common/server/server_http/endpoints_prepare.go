@@ -44,48 +44,48 @@ func (c *Config) EP(endpointKey EndpointKey, params []string, createFullURL bool
 
 var rePathParam = regexp.MustCompile(":[^/]+")
 
-func (ed EndpointDescription) PathTemplate(serverPath string) string {
+// pathTemplate joins serverPath with path params formatted for the concrete router (params started with '*' are catch-all ones)
+func (ed EndpointDescription) pathTemplate(serverPath string, format func(param string, catchAll bool) string) string {
 	if len(serverPath) == 0 || serverPath[0] != '/' {
 		serverPath = "/" + serverPath
 	}
 
-	if len(ed.PathParams) < 1 {
-		return serverPath
-	}
-
-	var pathParams []string
 	for _, pp := range ed.PathParams {
 		if len(pp) > 0 && pp[0] == '*' {
-			pathParams = append(pathParams, pp)
+			serverPath += "/" + format(pp[1:], true)
 		} else {
-			pathParams = append(pathParams, ":"+pp)
+			serverPath += "/" + format(pp, false)
 		}
-
 	}
 
-	return serverPath + "/" + strings.Join(pathParams, "/")
+	return serverPath
 }
 
-func (ed EndpointDescription) PathTemplateBraced(serverPath string) string {
-	if len(serverPath) == 0 || serverPath[0] != '/' {
-		serverPath = "/" + serverPath
-	}
-
-	if len(ed.PathParams) < 1 {
-		return serverPath
-	}
-
-	var pathParams []string
-	for _, pp := range ed.PathParams {
-		if len(pp) > 0 && pp[0] == '*' {
-			pathParams = append(pathParams, pp[1:])
-		} else {
-			pathParams = append(pathParams, pp)
+// PathTemplate returns the path in julienschmidt/httprouter syntax: /path/:param/*catchall
+func (ed EndpointDescription) PathTemplate(serverPath string) string {
+	return ed.pathTemplate(serverPath, func(param string, catchAll bool) string {
+		if catchAll {
+			return "*" + param
 		}
+		return ":" + param
+	})
+}
 
-	}
+// PathTemplateBraced returns the path in Swagger syntax: /path/{param}/{catchall}
+func (ed EndpointDescription) PathTemplateBraced(serverPath string) string {
+	return ed.pathTemplate(serverPath, func(param string, _ bool) string {
+		return "{" + param + "}"
+	})
+}
 
-	return serverPath + "/{" + strings.Join(ed.PathParams, "}/{") + "}"
+// PathPattern returns the path in net/http.ServeMux syntax: /path/{param}/{catchall...}
+func (ed EndpointDescription) PathPattern(serverPath string) string {
+	return ed.pathTemplate(serverPath, func(param string, catchAll bool) string {
+		if catchAll {
+			return "{" + param + "...}"
+		}
+		return "{" + param + "}"
+	})
 }
 
 //func (ep Endpoint) PathWithParams(params ...string) string {
@@ -3,8 +3,33 @@ package server_http
 import (
 	"fmt"
 	"net/http"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/logger"
 )
 
+const TooManyRequestsKey common.ErrorKey = "too_many_requests"
+
+const WrongIdempotencyKey common.ErrorKey = "wrong_idempotency_key"
+const IdempotencyKeyReusedKey common.ErrorKey = "idempotency_key_reused"
+const IdempotencyKeyInProgressKey common.ErrorKey = "idempotency_key_in_progress"
+
+func init() {
+	for key, description := range map[common.ErrorKey]errors.KeyDescription{
+		TooManyRequestsKey: {Status: http.StatusTooManyRequests, LogLevel: logger.InfoLevel, Messages: map[string]string{
+			"en": "Too many requests", "uk": "Забагато запитів"}},
+		WrongIdempotencyKey: {Status: http.StatusBadRequest, LogLevel: logger.WarnLevel, Messages: map[string]string{
+			"en": "Wrong Idempotency-Key header", "uk": "Неправильний заголовок Idempotency-Key"}},
+		IdempotencyKeyReusedKey: {Status: http.StatusUnprocessableEntity, LogLevel: logger.WarnLevel, Messages: map[string]string{
+			"en": "The Idempotency-Key is used already for another request", "uk": "Цей Idempotency-Key уже використано для іншого запиту"}},
+		IdempotencyKeyInProgressKey: {Status: http.StatusConflict, LogLevel: logger.InfoLevel, Messages: map[string]string{
+			"en": "The request with the same Idempotency-Key is in progress", "uk": "Запит із тим самим Idempotency-Key ще виконується"}},
+	} {
+		errors.Register(key, description)
+	}
+}
+
 func On(req *http.Request) string {
 	if req == nil {
 		return ""
@@ -0,0 +1,238 @@
+package server_http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/server"
+)
+
+// Handler is the router-independent part of endpoint handling, server_http.Operator implementations
+// only extract path params and call it
+type Handler func(w http.ResponseWriter, r *http.Request, params PathParams)
+
+// HandleSettings are common for all endpoints of server_http.Operator implementation
+type HandleSettings struct {
+	OnRequest      OnRequestMiddleware
+	OnAccess       OnAccessMiddleware // optional
+	Compression    *Compression       // optional
+	RateLimiter    RateLimiter        // optional
+	TrustedProxies []*net.IPNet
+	Logger         logger.Operator
+
+	IdempotencyStore       IdempotencyStore // optional, it's required for endpoints with Idempotent flag only
+	IdempotencyTTL         time.Duration
+	IdempotencyMaxBodySize int64 // DefaultIdempotencyMaxBodySize if it's 0, negative value disables the limit
+
+	ResponseCache ResponseCache // optional, it's required for endpoints with CacheTTL or Invalidates only
+
+	WebSocketOrigins []string // origins allowed for all WebSocket endpoints (besides the server's own one)
+
+	Errors ErrorSettings
+}
+
+const onHandleSettingsFromConfig = "on server_http.HandleSettingsFromConfig()"
+
+// HandleSettingsFromConfig prepares compression, rate limiting, (in-memory) idempotency store and response cache from cfg,
+// onAccess and l are optional
+func HandleSettingsFromConfig(cfg server.Config, onRequest OnRequestMiddleware, onAccess OnAccessMiddleware, l logger.Operator) (HandleSettings, error) {
+	settings := HandleSettings{
+		OnRequest:              onRequest,
+		OnAccess:               onAccess,
+		Compression:            CompressionFromConfig(cfg),
+		Logger:                 l,
+		IdempotencyStore:       NewIdempotencyStoreMem(cfg.IdempotencyMaxItems),
+		IdempotencyTTL:         cfg.IdempotencyTTL,
+		IdempotencyMaxBodySize: cfg.IdempotencyMaxBodySize,
+		WebSocketOrigins:       cfg.WebSocketOrigins,
+		Errors:                 ErrorSettings{ProblemJSON: cfg.ProblemJSON, ProblemTypeBase: cfg.ProblemTypeBase, ExposeDetails: ExposeErrorDetails(cfg.SecretENVs)},
+	}
+
+	if cfg.ResponseCacheMaxItems >= 0 {
+		settings.ResponseCache = NewResponseCache(cfg.ResponseCacheMaxItems)
+	}
+
+	if cfg.RateLimit != nil {
+		var err error
+		if settings.TrustedProxies, err = ParseTrustedProxies(cfg.RateLimit.TrustedProxies); err != nil {
+			return settings, errors.CommonError(err, onHandleSettingsFromConfig)
+		}
+		if settings.RateLimiter, err = NewRateLimiter(*cfg.RateLimit); err != nil {
+			return settings, errors.CommonError(err, onHandleSettingsFromConfig)
+		}
+	}
+
+	return settings, nil
+}
+
+// Handle creates the endpoint handler: it sets request ID and CORS headers, gets the identity, applies endpoint timeout,
+// calls the endpoint worker (recovering its panic, replaying the response of idempotent endpoint or getting the cached one
+// if it's required), writes (and compresses) the response (or 304 status if the client has it already) and the access log record
+func Handle(serverOp Operator, key EndpointKey, endpoint Endpoint, settings HandleSettings) Handler {
+	method := strings.ToUpper(endpoint.Method)
+	onRequest, l := settings.OnRequest, settings.Logger
+	allowedOrigins := append(append([]string(nil), settings.WebSocketOrigins...), endpoint.AllowedOrigins...)
+
+	return func(w http.ResponseWriter, r *http.Request, params PathParams) {
+		started := time.Now()
+
+		var requestID string
+		r, requestID = WithRequestID(r)
+		w.Header().Set(RequestIDHeader, requestID)
+		r = WithErrorSettings(r, settings.Errors)
+
+		identity, err := onRequest.Identity(r)
+		if err != nil {
+			logger.Logf(l, errors.LogLevel(err), "request_id=%s key=%s: %s", requestID, key, err)
+		}
+
+		SetCORSHeaders(w)
+
+		if settings.RateLimiter != nil {
+			rateLimit := settings.RateLimiter.Allow(key, RateLimitClientKey(r, identity, settings.TrustedProxies))
+			SetRateLimitHeaders(w, rateLimit)
+			if !rateLimit.Allowed {
+				responseData, _ := ResponseRESTError(http.StatusTooManyRequests, errors.CommonError(TooManyRequestsKey), r)
+				SetVaryNegotiated(w)
+				status, bytes := writeResponse(w, responseData, l)
+				logAccess(AccessRecord{RequestID: requestID, Method: method, Key: key, Status: status, Bytes: bytes, Latency: time.Since(started)}, r, identity, settings)
+				return
+			}
+		}
+
+		ctx := r.Context()
+		if endpoint.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, endpoint.Timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+
+		var status, bytes int
+		switch {
+		case endpoint.WorkerSSE != nil:
+			status, bytes, err = ServeSSE(ctx, serverOp, w, r, params, identity, recoveredSSE(endpoint.WorkerSSE), endpoint.KeepAlive)
+		case endpoint.WorkerWebSocket != nil:
+			status, bytes, err = ServeWebSocket(ctx, serverOp, w, r, params, identity, recoveredWebSocket(endpoint.WorkerWebSocket), allowedOrigins)
+		default:
+			var responseData server.Response
+			switch {
+			case endpoint.Idempotent && settings.IdempotencyStore != nil:
+				responseData, err = workIdempotent(ctx, serverOp, w, key, endpoint.WorkerHTTP, r, params, identity, settings)
+			case endpoint.CacheTTL > 0 && settings.ResponseCache != nil:
+				responseData, err = workCached(ctx, serverOp, w, key, endpoint.CacheTTL, endpoint.WorkerHTTP, r, params, identity, settings.ResponseCache)
+			default:
+				responseData, err = work(ctx, serverOp, endpoint.WorkerHTTP, r, params, identity)
+			}
+			if len(endpoint.Invalidates) > 0 && settings.ResponseCache != nil && responseData.Status < http.StatusBadRequest {
+				settings.ResponseCache.Invalidate(endpoint.Invalidates...)
+			}
+
+			SetVaryNegotiated(w)
+
+			// the entity tag is checked before compression, so the data isn't compressed for 304 responses
+			responseData, encoding := settings.Compression.Encoding(w, r, responseData)
+			if method == "GET" && notModified(w, r, responseData, encoding, endpoint.CacheControl) {
+				status, bytes = writeNotModified(w)
+			} else {
+				status, bytes = writeResponse(w, Encode(w, responseData, encoding), l)
+			}
+		}
+		if err != nil {
+			logger.Logf(l, errors.LogLevel(err), "request_id=%s key=%s: %s", requestID, key, err)
+		}
+
+		logAccess(AccessRecord{RequestID: requestID, Method: method, Key: key, Status: status, Bytes: bytes, Latency: time.Since(started)}, r, identity, settings)
+	}
+}
+
+func logAccess(accessRecord AccessRecord, r *http.Request, identity *auth.Identity, settings HandleSettings) {
+	accessRecord.RemoteIP = ClientIP(r, settings.TrustedProxies)
+	if identity != nil {
+		accessRecord.IdentityID = identity.ID
+	}
+	settings.Logger.Info(accessRecord.String())
+	if settings.OnAccess != nil {
+		settings.OnAccess.OnAccess(accessRecord)
+	}
+}
+
+func SetCORSHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", CORSAllowOrigin)
+	w.Header().Set("Access-Control-Allow-Headers", CORSAllowHeaders)
+	w.Header().Set("Access-Control-Allow-Methods", CORSAllowMethods)
+	w.Header().Set("Access-Control-Allow-Credentials", CORSAllowCredentials)
+}
+
+func errPanic(rec interface{}) error {
+	return errors.CommonError(common.CantPerformKey, fmt.Errorf("panic: %v\n%s", rec, debug.Stack()))
+}
+
+// work calls workerHTTP recovering its panic (if any) into the REST error response
+func work(ctx context.Context, serverOp Operator, workerHTTP WorkerHTTP, r *http.Request, params PathParams, identity *auth.Identity) (responseData server.Response, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			responseData, err = ResponseRESTError(http.StatusInternalServerError, errPanic(rec), r)
+		}
+	}()
+
+	return workerHTTP(ctx, serverOp, r, params, identity)
+}
+
+// recoveredSSE converts workerSSE panic (if any) into its error (the stream headers are already written)
+func recoveredSSE(workerSSE WorkerSSE) WorkerSSE {
+	return func(ctx context.Context, serverOp Operator, r *http.Request, params PathParams, identity *auth.Identity, sink EventSink) (err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = errPanic(rec)
+			}
+		}()
+
+		return workerSSE(ctx, serverOp, r, params, identity, sink)
+	}
+}
+
+// recoveredWebSocket converts workerWebSocket panic (if any) into its error (the connection is already upgraded)
+func recoveredWebSocket(workerWebSocket WorkerWebSocket) WorkerWebSocket {
+	return func(ctx context.Context, serverOp Operator, r *http.Request, params PathParams, identity *auth.Identity, conn WebSocketConn) (err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = errPanic(rec)
+			}
+		}()
+
+		return workerWebSocket(ctx, serverOp, r, params, identity, conn)
+	}
+}
+
+func writeResponse(w http.ResponseWriter, responseData server.Response, l logger.Operator) (status, bytes int) {
+	if responseData.MIMEType != "" {
+		w.Header().Set("Content-Type", responseData.MIMEType)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(responseData.Data)))
+	if responseData.FileName != "" {
+		w.Header().Set("Content-Disposition", "attachment; filename="+responseData.FileName)
+	}
+
+	if status = responseData.Status; status <= 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+
+	bytes, err := w.Write(responseData.Data)
+	if err != nil {
+		l.Error("can't write response", err)
+	}
+
+	return status, bytes
+}
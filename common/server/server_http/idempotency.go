@@ -0,0 +1,213 @@
+package server_http
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/joiner"
+	"github.com/pavlo67/common/common/server"
+)
+
+const IdempotencyHeader = "Idempotency-Key"
+const IdempotencyReplayedHeader = "Idempotent-Replayed"
+const IdempotencyKeyMaxLength = 255
+
+const DefaultIdempotencyTTL = 24 * time.Hour
+const DefaultIdempotencyMaxBodySize = 1 << 20
+const DefaultIdempotencyMaxItems = 10000
+
+// IdempotencyStoreInterfaceKey is used by server_http.Operator starters to get the custom IdempotencyStore
+// (the in-memory one is used without it)
+const IdempotencyStoreInterfaceKey joiner.InterfaceKey = "server_http_idempotency_store"
+
+// IdempotencyRecord keeps the result of the first request with the same Idempotency-Key header
+type IdempotencyRecord struct {
+	BodyHash string
+	Response *server.Response // nil while the first request is in progress
+	Expires  time.Time
+}
+
+type IdempotencyStore interface {
+	// Start saves the new record (in progress) and returns nil or returns the existing not expired record with the same key
+	Start(key, bodyHash string, expires time.Time) (*IdempotencyRecord, error)
+
+	// Finish saves the response for the record started before
+	Finish(key string, response server.Response) error
+
+	// Remove deletes the record (so the request can be performed again)
+	Remove(key string) error
+}
+
+// IdempotencyStoreKey identifies the request with the client (see RateLimitClientKey), Idempotency-Key header value and the route
+func IdempotencyStoreKey(clientKey, idempotencyKey string, key EndpointKey, path string) string {
+	hash := sha256.Sum256([]byte(strings.Join([]string{clientKey, idempotencyKey, string(key), path}, "\x00")))
+	return hex.EncodeToString(hash[:])
+}
+
+// workIdempotent replays the stored response if the request with the same Idempotency-Key header was performed before,
+// otherwise it calls workerHTTP and stores its response (server errors aren't stored, so the request can be retried)
+func workIdempotent(ctx context.Context, serverOp Operator, w http.ResponseWriter, key EndpointKey, workerHTTP WorkerHTTP, r *http.Request, params PathParams, identity *auth.Identity, settings HandleSettings) (server.Response, error) {
+	idempotencyKey := r.Header.Get(IdempotencyHeader)
+	if idempotencyKey == "" {
+		return work(ctx, serverOp, workerHTTP, r, params, identity)
+	} else if len(idempotencyKey) > IdempotencyKeyMaxLength {
+		return ResponseRESTError(http.StatusBadRequest, errors.CommonError(WrongIdempotencyKey, fmt.Sprintf("too long %s header (%d bytes)", IdempotencyHeader, len(idempotencyKey))), r)
+	}
+
+	var body []byte
+	if r.Body != nil {
+		maxBodySize := settings.IdempotencyMaxBodySize
+		if maxBodySize == 0 {
+			maxBodySize = DefaultIdempotencyMaxBodySize
+		}
+		if maxBodySize > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		}
+
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return ResponseRESTError(http.StatusRequestEntityTooLarge, errors.CommonError(common.WrongBodyKey, fmt.Sprintf("the request body is larger than %d bytes", maxBytesErr.Limit)), r)
+			}
+			return ResponseRESTError(http.StatusBadRequest, errors.CommonError(common.WrongBodyKey, err), r)
+		}
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	bodyHash := sha256.Sum256(body)
+	bodyHashStr := hex.EncodeToString(bodyHash[:])
+
+	ttl := settings.IdempotencyTTL
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+
+	storeKey := IdempotencyStoreKey(RateLimitClientKey(r, identity, settings.TrustedProxies), idempotencyKey, key, r.URL.Path)
+	record, err := settings.IdempotencyStore.Start(storeKey, bodyHashStr, time.Now().Add(ttl))
+	if err != nil {
+		return ResponseRESTError(http.StatusInternalServerError, errors.CommonError(common.CantPerformKey, err), r)
+	}
+
+	if record != nil {
+		if record.BodyHash != bodyHashStr {
+			return ResponseRESTError(http.StatusUnprocessableEntity, errors.CommonError(IdempotencyKeyReusedKey, "the request body differs from the previous one with the same "+IdempotencyHeader), r)
+		} else if record.Response == nil {
+			return ResponseRESTError(http.StatusConflict, errors.CommonError(IdempotencyKeyInProgressKey, "the previous request with the same "+IdempotencyHeader+" is in progress"), r)
+		}
+		w.Header().Set(IdempotencyReplayedHeader, "true")
+		return *record.Response, nil
+	}
+
+	responseData, err := work(ctx, serverOp, workerHTTP, r, params, identity)
+	if responseData.Status >= http.StatusInternalServerError {
+		if errRemove := settings.IdempotencyStore.Remove(storeKey); errRemove != nil {
+			settings.Logger.Errorf("can't remove idempotency record: %s", errRemove)
+		}
+	} else if errFinish := settings.IdempotencyStore.Finish(storeKey, responseData); errFinish != nil {
+		settings.Logger.Errorf("can't finish idempotency record: %s", errFinish)
+	}
+
+	return responseData, err
+}
+
+// in-memory idempotency store ----------------------------------------------------------------------------------------
+
+var IdempotencyCleanInterval = time.Minute
+
+var _ IdempotencyStore = &idempotencyStoreMem{}
+
+type idempotencyStoreMem struct {
+	maxItems int
+	records  map[string]*list.Element
+	started  *list.List // the most recently started records are in front
+	cleaned  time.Time
+	now      func() time.Time
+
+	mutex sync.Mutex
+}
+
+type idempotencyRecordMem struct {
+	key    string
+	record IdempotencyRecord
+}
+
+// NewIdempotencyStoreMem creates the in-memory store removing expired records and evicting the earliest started ones
+// if there are more than maxItems (DefaultIdempotencyMaxItems if it isn't positive)
+func NewIdempotencyStoreMem(maxItems int) IdempotencyStore {
+	if maxItems <= 0 {
+		maxItems = DefaultIdempotencyMaxItems
+	}
+
+	return &idempotencyStoreMem{maxItems: maxItems, records: map[string]*list.Element{}, started: list.New(), now: time.Now}
+}
+
+func (ism *idempotencyStoreMem) Start(key, bodyHash string, expires time.Time) (*IdempotencyRecord, error) {
+	ism.mutex.Lock()
+	defer ism.mutex.Unlock()
+
+	now := ism.now()
+	if now.Sub(ism.cleaned) >= IdempotencyCleanInterval {
+		for _, element := range ism.records {
+			if !element.Value.(*idempotencyRecordMem).record.Expires.After(now) {
+				ism.remove(element)
+			}
+		}
+		ism.cleaned = now
+	}
+
+	if element, ok := ism.records[key]; ok {
+		if record := element.Value.(*idempotencyRecordMem).record; record.Expires.After(now) {
+			return &record, nil
+		}
+		ism.remove(element)
+	}
+
+	for ism.started.Len() >= ism.maxItems {
+		ism.remove(ism.started.Back())
+	}
+
+	ism.records[key] = ism.started.PushFront(&idempotencyRecordMem{key: key, record: IdempotencyRecord{BodyHash: bodyHash, Expires: expires}})
+
+	return nil, nil
+}
+
+func (ism *idempotencyStoreMem) Finish(key string, response server.Response) error {
+	ism.mutex.Lock()
+	defer ism.mutex.Unlock()
+
+	element, ok := ism.records[key]
+	if !ok {
+		return errors.CommonError(common.NotFoundKey, "no idempotency record to finish")
+	}
+	element.Value.(*idempotencyRecordMem).record.Response = &response
+
+	return nil
+}
+
+func (ism *idempotencyStoreMem) Remove(key string) error {
+	ism.mutex.Lock()
+	defer ism.mutex.Unlock()
+
+	if element, ok := ism.records[key]; ok {
+		ism.remove(element)
+	}
+
+	return nil
+}
+
+func (ism *idempotencyStoreMem) remove(element *list.Element) {
+	delete(ism.records, ism.started.Remove(element).(*idempotencyRecordMem).key)
+}
@@ -0,0 +1,127 @@
+package idempotency_sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/server"
+	"github.com/pavlo67/common/common/server/server_http"
+	"github.com/pavlo67/common/common/sqllib"
+)
+
+const DefaultTable = "idempotency"
+
+var _ server_http.IdempotencyStore = &idempotencySQLite{}
+
+type idempotencySQLite struct {
+	db    *sql.DB
+	table string
+}
+
+var reTable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+const onNew = "on idempotency_sqlite.New()"
+
+// New creates the table (if it doesn't exist) for records of the idempotency store
+func New(db *sql.DB, table string) (server_http.IdempotencyStore, error) {
+	if db == nil {
+		return nil, errors.New(onNew + ": no *sql.DB")
+	}
+	if table == "" {
+		table = DefaultTable
+	} else if !reTable.MatchString(table) {
+		return nil, fmt.Errorf(onNew+": wrong table name (%s)", table)
+	}
+
+	sqlCreate := "CREATE TABLE IF NOT EXISTS " + table + ` (
+		key        TEXT    PRIMARY KEY,
+		body_hash  TEXT    NOT NULL,
+		response   TEXT,
+		expires_at INTEGER NOT NULL
+	)`
+	if _, err := sqllib.Exec(db, sqlCreate); err != nil {
+		return nil, errors.CommonError(err, onNew)
+	}
+
+	sqlIndex := "CREATE INDEX IF NOT EXISTS idx_" + table + "_expires_at ON " + table + " (expires_at)"
+	if _, err := sqllib.Exec(db, sqlIndex); err != nil {
+		return nil, errors.CommonError(err, onNew)
+	}
+
+	return &idempotencySQLite{db: db, table: table}, nil
+}
+
+const onStart = "on idempotencySQLite.Start()"
+
+func (is *idempotencySQLite) Start(key, bodyHash string, expires time.Time) (*server_http.IdempotencyRecord, error) {
+	if _, err := sqllib.Exec(is.db, "DELETE FROM "+is.table+" WHERE expires_at <= ?", time.Now().UnixNano()); err != nil {
+		return nil, errors.CommonError(err, onStart)
+	}
+
+	sqlInsert := "INSERT OR IGNORE INTO " + is.table + " (key, body_hash, expires_at) VALUES (?, ?, ?)"
+	res, err := sqllib.Exec(is.db, sqlInsert, key, bodyHash, expires.UnixNano())
+	if err != nil {
+		return nil, errors.CommonError(err, onStart)
+	}
+	inserted, err := (*res).RowsAffected()
+	if err != nil {
+		return nil, errors.Wrapf(err, onStart+": "+sqllib.CantGetRowsAffected, sqlInsert, key)
+	} else if inserted > 0 {
+		return nil, nil
+	}
+
+	sqlSelect := "SELECT body_hash, response, expires_at FROM " + is.table + " WHERE key = ?"
+	var record server_http.IdempotencyRecord
+	var response sql.NullString
+	var expiresAt int64
+	if err = is.db.QueryRow(sqlSelect, key).Scan(&record.BodyHash, &response, &expiresAt); err != nil {
+		return nil, errors.Wrapf(err, onStart+": "+sqllib.CantScanQueryRow, sqlSelect, key)
+	}
+	record.Expires = time.Unix(0, expiresAt)
+
+	if response.Valid {
+		record.Response = &server.Response{}
+		if err = json.Unmarshal([]byte(response.String), record.Response); err != nil {
+			return nil, errors.Wrapf(err, onStart+": can't unmarshal response (%s)", response.String)
+		}
+	}
+
+	return &record, nil
+}
+
+const onFinish = "on idempotencySQLite.Finish()"
+
+func (is *idempotencySQLite) Finish(key string, response server.Response) error {
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return errors.Wrapf(err, onFinish+": can't marshal response (%#v)", response)
+	}
+
+	sqlUpdate := "UPDATE " + is.table + " SET response = ? WHERE key = ?"
+	res, err := sqllib.Exec(is.db, sqlUpdate, string(responseJSON), key)
+	if err != nil {
+		return errors.CommonError(err, onFinish)
+	}
+	if updated, err := (*res).RowsAffected(); err != nil {
+		return errors.Wrapf(err, onFinish+": "+sqllib.CantGetRowsAffected, sqlUpdate, key)
+	} else if updated < 1 {
+		return errors.CommonError(common.NotFoundKey, onFinish+": no idempotency record to finish")
+	}
+
+	return nil
+}
+
+const onRemove = "on idempotencySQLite.Remove()"
+
+func (is *idempotencySQLite) Remove(key string) error {
+	if _, err := sqllib.Exec(is.db, "DELETE FROM "+is.table+" WHERE key = ?", key); err != nil {
+		return errors.CommonError(err, onRemove)
+	}
+
+	return nil
+}
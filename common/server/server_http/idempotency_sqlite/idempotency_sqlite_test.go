@@ -0,0 +1,26 @@
+package idempotency_sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common/config"
+	"github.com/pavlo67/common/common/server/server_http"
+	"github.com/pavlo67/common/common/sqllib/sqllib_sqlite"
+)
+
+func TestIdempotencySQLite(t *testing.T) {
+	db, err := sqllib_sqlite.Connect(config.Access{Path: filepath.Join(t.TempDir(), "idempotency.sqlite")})
+	require.NoError(t, err)
+	defer db.Close()
+
+	store, err := New(db, "")
+	require.NoError(t, err)
+
+	server_http.IdempotencyStoreTestScenario(t, store)
+
+	_, err = New(db, "wrong table")
+	require.Error(t, err)
+}
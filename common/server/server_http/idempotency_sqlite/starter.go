@@ -0,0 +1,62 @@
+package idempotency_sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/config"
+	"github.com/pavlo67/common/common/db/db_sqlite"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/joiner"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/server/server_http"
+	"github.com/pavlo67/common/common/starter"
+)
+
+func Starter() starter.Operator {
+	return &idempotencySQLiteStarter{}
+}
+
+var l logger.Operator
+var _ starter.Operator = &idempotencySQLiteStarter{}
+
+type idempotencySQLiteStarter struct {
+	dbKey        joiner.InterfaceKey
+	table        string
+	interfaceKey joiner.InterfaceKey
+}
+
+func (iss *idempotencySQLiteStarter) Name() string {
+	return logger.GetCallInfo().PackageName
+}
+
+func (iss *idempotencySQLiteStarter) Prepare(cfg *config.Config, options common.Map) error {
+	iss.dbKey = joiner.InterfaceKey(options.StringDefault("db_key", string(db_sqlite.InterfaceKey)))
+	iss.table = options.StringDefault("table", DefaultTable)
+	iss.interfaceKey = joiner.InterfaceKey(options.StringDefault("interface_key", string(server_http.IdempotencyStoreInterfaceKey)))
+
+	return nil
+}
+
+func (iss *idempotencySQLiteStarter) Run(joinerOp joiner.Operator) error {
+	if l, _ = joinerOp.Interface(logger.InterfaceKey).(logger.Operator); l == nil {
+		return fmt.Errorf("no logger.Operator with key %s", logger.InterfaceKey)
+	}
+
+	db, _ := joinerOp.Interface(iss.dbKey).(*sql.DB)
+	if db == nil {
+		return fmt.Errorf("no *sql.DB with key %s", iss.dbKey)
+	}
+
+	idempotencyStore, err := New(db, iss.table)
+	if err != nil {
+		return errors.Wrap(err, "can't init *idempotencySQLite{} as server_http.IdempotencyStore")
+	}
+
+	if err = joinerOp.Join(idempotencyStore, iss.interfaceKey); err != nil {
+		return errors.Wrapf(err, "can't join *idempotencySQLite{} as server_http.IdempotencyStore with key '%s'", iss.interfaceKey)
+	}
+
+	return nil
+}
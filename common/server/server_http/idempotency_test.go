@@ -0,0 +1,103 @@
+package server_http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/logger/logger_zap"
+	"github.com/pavlo67/common/common/server"
+)
+
+func TestIdempotencyStoreMem(t *testing.T) {
+	IdempotencyStoreTestScenario(t, NewIdempotencyStoreMem(0))
+
+	store := NewIdempotencyStoreMem(2)
+	for _, key := range []string{"k1", "k2", "k3"} {
+		record, err := store.Start(key, "body_hash", time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		require.Nil(t, record)
+	}
+
+	// k1 is evicted
+	record, err := store.Start("k1", "body_hash", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	record, err = store.Start("k3", "body_hash", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.NotNil(t, record)
+}
+
+func TestHandleIdempotent(t *testing.T) {
+	l, err := logger_zap.New(logger.Config{})
+	require.NoError(t, err)
+
+	settings, err := HandleSettingsFromConfig(server.Config{}, onRequestMiddlewareTest(), nil, l)
+	require.NoError(t, err)
+
+	var calls int
+	handle := Handle(nil, "test", Endpoint{
+		EndpointDescription: EndpointDescription{Method: "POST", Idempotent: true},
+		WorkerHTTP: func(_ context.Context, _ Operator, req *http.Request, _ PathParams, _ *auth.Identity) (server.Response, error) {
+			calls++
+			if calls == 1 {
+				return ResponseRESTError(http.StatusServiceUnavailable, errors.CommonError(common.CantPerformKey), req)
+			}
+			return ResponseRESTOk(http.StatusCreated, calls, req)
+		},
+	}, settings)
+
+	request := func(idempotencyKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set(IdempotencyHeader, idempotencyKey)
+		w := httptest.NewRecorder()
+		handle(w, req, nil)
+		return w
+	}
+
+	// server errors aren't stored
+	require.Equal(t, http.StatusServiceUnavailable, request("k", "body").Code)
+
+	w := request("k", "body")
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "2", w.Body.String())
+
+	w = request("k", "body")
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "2", w.Body.String())
+	require.Equal(t, "true", w.Header().Get(IdempotencyReplayedHeader))
+	require.Equal(t, 2, calls)
+
+	require.Equal(t, http.StatusUnprocessableEntity, request("k", "another body").Code)
+	require.Equal(t, http.StatusBadRequest, request(strings.Repeat("k", IdempotencyKeyMaxLength+1), "body").Code)
+
+	// in progress
+	storeKey := IdempotencyStoreKey(RateLimitClientKey(httptest.NewRequest("POST", "/", nil), nil, nil), "k2", "test", "/")
+	emptyBodyHash := sha256.Sum256(nil)
+	_, err = settings.IdempotencyStore.Start(storeKey, hex.EncodeToString(emptyBodyHash[:]), time.Now().Add(DefaultIdempotencyTTL))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusConflict, request("k2", "").Code)
+
+	// too large body
+	settings.IdempotencyMaxBodySize = 4
+	handle = Handle(nil, "test", Endpoint{
+		EndpointDescription: EndpointDescription{Method: "POST", Idempotent: true},
+		WorkerHTTP: func(_ context.Context, _ Operator, req *http.Request, _ PathParams, _ *auth.Identity) (server.Response, error) {
+			return ResponseRESTOk(http.StatusCreated, nil, req)
+		},
+	}, settings)
+	require.Equal(t, http.StatusRequestEntityTooLarge, request("k3", "too large").Code)
+	require.Equal(t, http.StatusCreated, request("k4", "body").Code)
+}
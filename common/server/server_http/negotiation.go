@@ -0,0 +1,281 @@
+package server_http
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/pavlo67/common/common/errors"
+)
+
+const (
+	MIMETypeJSON = "application/json"
+	MIMETypeYAML = "application/yaml"
+	MIMETypeCSV  = "text/csv"
+)
+
+// mimeTypeAliases are accepted in Accept header as the same types
+var mimeTypeAliases = map[string]string{
+	"application/x-yaml": MIMETypeYAML,
+	"text/yaml":          MIMETypeYAML,
+	"text/x-yaml":        MIMETypeYAML,
+}
+
+type acceptedType struct {
+	mimeType string
+	q        float64
+}
+
+// ParseAccept parses Accept (or Accept-Encoding) header value into types ordered by preference, types with q=0 are skipped
+func ParseAccept(accept string) []string {
+	var accepted []acceptedType
+	for _, a := range parseAccept(accept) {
+		if a.q > 0 {
+			accepted = append(accepted, a)
+		}
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		if accepted[i].q != accepted[j].q {
+			return accepted[i].q > accepted[j].q
+		}
+		// more specific types are preferred with the same q
+		return strings.Count(accepted[i].mimeType, "*") < strings.Count(accepted[j].mimeType, "*")
+	})
+
+	var mimeTypes []string
+	for _, a := range accepted {
+		mimeTypes = append(mimeTypes, a.mimeType)
+	}
+
+	return mimeTypes
+}
+
+// parseAccept returns all types with their q values (q=0 ones too) in the header order
+func parseAccept(accept string) []acceptedType {
+	var accepted []acceptedType
+
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mimeType := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mimeType == "" {
+			continue
+		}
+		if alias, ok := mimeTypeAliases[mimeType]; ok {
+			mimeType = alias
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if len(param) > 2 && (param[:2] == "q=" || param[:2] == "Q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+
+		accepted = append(accepted, acceptedType{mimeType: mimeType, q: q})
+	}
+
+	return accepted
+}
+
+// NegotiateMIMEType selects the most preferred of offered types, it returns the first offered one if Accept is empty
+// or "" if nothing is acceptable
+func NegotiateMIMEType(accept string, offered ...string) string {
+	if len(offered) < 1 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offered[0]
+	}
+
+	for _, mimeType := range ParseAccept(accept) {
+		for _, o := range offered {
+			if mimeType == o || mimeType == "*/*" || (strings.HasSuffix(mimeType, "/*") && strings.HasPrefix(o, mimeType[:len(mimeType)-1])) {
+				return o
+			}
+		}
+	}
+
+	return ""
+}
+
+const onMarshalYAML = "on server_http.MarshalYAML()"
+
+// MarshalYAML uses JSON field names and order (data is marshaled to JSON at first)
+func MarshalYAML(data interface{}) ([]byte, error) {
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.CommonError(err, onMarshalYAML)
+	}
+
+	// JSON is YAML too, yaml.MapSlice keeps the fields order
+	var value interface{}
+	if err = yaml.Unmarshal(jsonBytes, &value); err != nil {
+		return nil, errors.CommonError(err, onMarshalYAML)
+	}
+	value = toMapSlices(jsonBytes, value)
+
+	return yaml.Marshal(value)
+}
+
+// toMapSlices replaces YAML maps with yaml.MapSlice to keep JSON fields order
+func toMapSlices(jsonBytes []byte, value interface{}) interface{} {
+	var ordered yaml.MapSlice
+	if _, ok := value.(map[interface{}]interface{}); ok {
+		if err := yaml.Unmarshal(jsonBytes, &ordered); err == nil {
+			return ordered
+		}
+	} else if list, ok := value.([]interface{}); ok {
+		var orderedList []yaml.MapSlice
+		if len(list) > 0 {
+			if _, ok := list[0].(map[interface{}]interface{}); ok {
+				if err := yaml.Unmarshal(jsonBytes, &orderedList); err == nil {
+					return orderedList
+				}
+			}
+		}
+	}
+
+	return value
+}
+
+// IsList checks if data can be marshaled to CSV (it's a slice or array)
+func IsList(data interface{}) bool {
+	if data == nil {
+		return false
+	}
+	switch data.(type) {
+	case []byte, *[]byte:
+		return false
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(data))
+	return v.Kind() == reflect.Slice || v.Kind() == reflect.Array
+}
+
+const onMarshalCSV = "on server_http.MarshalCSV()"
+
+// MarshalCSV marshals a list: [][]string is written as is, other items are marshaled to JSON objects at first,
+// their field names make the header row and non-scalar values are written as JSON
+func MarshalCSV(data interface{}) ([]byte, error) {
+	if !IsList(data) {
+		return nil, errors.New(onMarshalCSV + ": data isn't a list")
+	}
+
+	if rows, ok := data.([][]string); ok {
+		return writeCSV(rows)
+	}
+
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.CommonError(err, onMarshalCSV)
+	}
+
+	var records [][]string
+
+	var items []yaml.MapSlice
+	if err = yaml.Unmarshal(jsonBytes, &items); err != nil {
+		// list items aren't objects, so each of them is written as a single column row
+		var values []interface{}
+		if err = yaml.Unmarshal(jsonBytes, &values); err != nil {
+			return nil, errors.CommonError(err, onMarshalCSV)
+		}
+		for _, value := range values {
+			records = append(records, []string{csvValue(value)})
+		}
+
+		return writeCSV(records)
+	}
+
+	var header []string
+	columns := map[string]int{}
+	for _, item := range items {
+		for _, field := range item {
+			name, _ := field.Key.(string)
+			if _, ok := columns[name]; !ok {
+				columns[name] = len(header)
+				header = append(header, name)
+			}
+		}
+	}
+
+	records = append(records, header)
+	for _, item := range items {
+		record := make([]string, len(header))
+		for _, field := range item {
+			name, _ := field.Key.(string)
+			record[columns[name]] = csvValue(field.Value)
+		}
+		records = append(records, record)
+	}
+
+	return writeCSV(records)
+}
+
+func writeCSV(records [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(records); err != nil {
+		return nil, errors.CommonError(err, onMarshalCSV)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	jsonBytes, err := json.Marshal(yamlToJSONValue(value))
+	if err != nil {
+		return ""
+	}
+	return string(jsonBytes)
+}
+
+// yamlToJSONValue converts YAML-unmarshaled structures to JSON-marshalable ones
+func yamlToJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		m := map[string]interface{}{}
+		for _, item := range v {
+			key, _ := item.Key.(string)
+			m[key] = yamlToJSONValue(item.Value)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, item := range v {
+			keyStr, _ := key.(string)
+			m[keyStr] = yamlToJSONValue(item)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = yamlToJSONValue(item)
+		}
+		return list
+	}
+
+	return value
+}
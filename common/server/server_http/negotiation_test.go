@@ -0,0 +1,51 @@
+package server_http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateMIMEType(t *testing.T) {
+	offered := []string{MIMETypeJSON, MIMETypeYAML, MIMETypeCSV}
+
+	require.Equal(t, MIMETypeJSON, NegotiateMIMEType("", offered...))
+	require.Equal(t, MIMETypeJSON, NegotiateMIMEType("*/*", offered...))
+	require.Equal(t, MIMETypeYAML, NegotiateMIMEType("text/yaml", offered...))
+	require.Equal(t, MIMETypeCSV, NegotiateMIMEType("application/json;q=0.5, text/csv", offered...))
+	require.Equal(t, MIMETypeCSV, NegotiateMIMEType("text/*", offered...))
+	require.Equal(t, MIMETypeYAML, NegotiateMIMEType("*/*;q=0.1, application/yaml", offered...))
+	require.Equal(t, "", NegotiateMIMEType("text/html, application/json;q=0", offered...))
+}
+
+func TestMarshalCSV(t *testing.T) {
+	type item struct {
+		ID   int               `json:"id"`
+		Name string            `json:"name,omitempty"`
+		Tags map[string]string `json:"tags,omitempty"`
+	}
+
+	data, err := MarshalCSV([]item{{ID: 1, Name: "a"}, {ID: 2, Tags: map[string]string{"k": "v"}}})
+	require.NoError(t, err)
+	require.Equal(t, "id,name,tags\n1,a,\n2,,\"{\"\"k\"\":\"\"v\"\"}\"\n", string(data))
+
+	data, err = MarshalCSV([]string{"a", "b"})
+	require.NoError(t, err)
+	require.Equal(t, "a\nb\n", string(data))
+
+	data, err = MarshalCSV([][]string{{"a", "b"}})
+	require.NoError(t, err)
+	require.Equal(t, "a,b\n", string(data))
+
+	_, err = MarshalCSV(item{})
+	require.Error(t, err)
+}
+
+func TestMarshalYAML(t *testing.T) {
+	data, err := MarshalYAML(struct {
+		B string `json:"b"`
+		A int    `json:"a"`
+	}{"x", 1})
+	require.NoError(t, err)
+	require.Equal(t, "b: x\na: 1\n", string(data))
+}
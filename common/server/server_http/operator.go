@@ -1,6 +1,7 @@
 package server_http
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/pavlo67/common/common/server"
@@ -11,6 +12,7 @@ import (
 )
 
 const OnRequestMiddlewareInterfaceKey joiner.InterfaceKey = "server_http_on_request_middleware"
+const OnAccessMiddlewareInterfaceKey joiner.InterfaceKey = "server_http_on_access_middleware"
 const InterfaceKey joiner.InterfaceKey = "server_http"
 
 type PathParams map[string]string
@@ -19,12 +21,18 @@ type OnRequestMiddleware interface {
 	Identity(r *http.Request) (*auth.Identity, error)
 }
 
+// OnAccessMiddleware gets the access record of each request after the response is written
+type OnAccessMiddleware interface {
+	OnAccess(accessRecord AccessRecord)
+}
+
 type StaticPath struct {
 	LocalPath string
 	MIMEType  *string
 }
 
-type WorkerHTTP func(Operator, *http.Request, PathParams, *auth.Identity) (server.Response, error)
+// WorkerHTTP gets the request context (limited with EndpointDescription.Timeout if it's set), the context is canceled on client disconnect
+type WorkerHTTP func(context.Context, Operator, *http.Request, PathParams, *auth.Identity) (server.Response, error)
 
 type Operator interface {
 	HandleEndpoint(key EndpointKey, serverPath string, endpoint Endpoint) error
@@ -0,0 +1,98 @@
+package server_http
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/server"
+)
+
+const DefaultProblemTypeBase = "urn:problem-type:"
+
+// DefaultSecretENVs are used if no secret ENVs are configured
+var DefaultSecretENVs = []string{"production"}
+
+// ErrorSettings define the format of error responses, Handle puts them into the request context, so ResponseRESTError
+// called by the endpoint worker can use them
+type ErrorSettings struct {
+	ProblemJSON     bool   // RFC 7807 problem details are responded instead of {"error_key": ...}
+	ProblemTypeBase string // the error key is appended to it to get the problem type URI
+	ExposeDetails   bool   // the error text is added to the response
+}
+
+type errorSettingsContextKey struct{}
+
+func WithErrorSettings(req *http.Request, errorSettings ErrorSettings) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), errorSettingsContextKey{}, errorSettings))
+}
+
+// ErrorSettingsFromRequest returns the zero ErrorSettings if the request isn't handled with Handle
+func ErrorSettingsFromRequest(req *http.Request) ErrorSettings {
+	if req == nil {
+		return ErrorSettings{}
+	}
+
+	errorSettings, _ := req.Context().Value(errorSettingsContextKey{}).(ErrorSettings)
+	return errorSettings
+}
+
+// ExposeErrorDetails checks ENV environment variable: error details are hidden if it's one of secretENVs (DefaultSecretENVs
+// if they are empty) or if it isn't set at all
+func ExposeErrorDetails(secretENVs []string) bool {
+	env := strings.TrimSpace(os.Getenv("ENV"))
+	if env == "" {
+		return false
+	}
+
+	if len(secretENVs) < 1 {
+		secretENVs = DefaultSecretENVs
+	}
+	for _, secretENV := range secretENVs {
+		if strings.EqualFold(env, strings.TrimSpace(secretENV)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+var problemMembers = map[string]bool{"type": true, "title": true, "status": true, "detail": true, "instance": true}
+
+// Problem returns RFC 7807 problem details document for the error, members of errors.Data(err) are added to it
+// (if they don't replace the standard ones)
+func Problem(status int, err error, requestID string, errorSettings ErrorSettings) common.Map {
+	key := errors.Keyed(err)
+
+	problem := common.Map{}
+	for k, v := range errors.Data(err) {
+		if !problemMembers[k] {
+			problem[k] = v
+		}
+	}
+
+	typeBase := errorSettings.ProblemTypeBase
+	if typeBase == "" {
+		typeBase = DefaultProblemTypeBase
+	}
+
+	if key != "" {
+		problem["type"] = typeBase + string(key)
+		problem[server.ErrorKey] = key
+	} else {
+		problem["type"] = "about:blank"
+	}
+	problem["title"] = http.StatusText(status)
+	problem["status"] = status
+	if requestID != "" {
+		problem["instance"] = requestID
+	}
+	if errorSettings.ExposeDetails && err != nil {
+		problem["detail"] = err.Error()
+	}
+
+	return problem
+}
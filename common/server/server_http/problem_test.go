@@ -0,0 +1,59 @@
+package server_http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/server"
+)
+
+func TestExposeErrorDetails(t *testing.T) {
+	t.Setenv("ENV", "")
+	require.False(t, ExposeErrorDetails(nil))
+
+	t.Setenv("ENV", "Production")
+	require.False(t, ExposeErrorDetails(nil))
+	require.True(t, ExposeErrorDetails([]string{"prod"}))
+
+	t.Setenv("ENV", "test")
+	require.True(t, ExposeErrorDetails(nil))
+	require.False(t, ExposeErrorDetails([]string{"prod", "test"}))
+}
+
+func TestResponseRESTErrorProblem(t *testing.T) {
+	err := errors.CommonError(common.NotFoundKey, common.Map{"id": "1", "status": "wrong"})
+
+	req, _ := WithRequestID(httptest.NewRequest("GET", "/items/1", nil))
+	req.Header.Set("Accept-Language", "uk-UA, en;q=0.5")
+	req = WithErrorSettings(req, ErrorSettings{ProblemJSON: true, ProblemTypeBase: "https://example.com/problems/", ExposeDetails: true})
+
+	responseData, errResponse := ResponseRESTError(http.StatusNotFound, err, req)
+	require.Error(t, errResponse)
+	require.Equal(t, server.MIMETypeProblemJSON, responseData.MIMEType)
+
+	var problem map[string]interface{}
+	require.NoError(t, json.Unmarshal(responseData.Data, &problem))
+	require.Equal(t, map[string]interface{}{
+		"type":          "https://example.com/problems/not_found",
+		"title":         "Не знайдено",
+		"status":        float64(http.StatusNotFound),
+		"detail":        err.Error(),
+		"instance":      RequestID(req),
+		server.ErrorKey: "not_found",
+		"id":            "1",
+	}, problem)
+
+	req = WithErrorSettings(req, ErrorSettings{ExposeDetails: true})
+	responseData, _ = ResponseRESTError(http.StatusNotFound, err, req)
+	require.Empty(t, responseData.MIMEType)
+	require.JSONEq(t, `{"error_key":"not_found","message":"Не знайдено","details":"`+err.Error()+`"}`, string(responseData.Data))
+
+	responseData, _ = ResponseRESTError(http.StatusNotFound, err, httptest.NewRequest("GET", "/items/1", nil))
+	require.JSONEq(t, `{"error_key":"not_found","message":"Not found"}`, string(responseData.Data))
+}
@@ -0,0 +1,278 @@
+package server_http
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/server"
+)
+
+const DefaultRateLimitMaxClients = 10000
+
+// RateLimit is the result of the request check
+type RateLimit struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request is allowed (if it's not allowed now)
+}
+
+type RateLimiter interface {
+	// Allow checks (and counts if it's allowed) the request of client to the endpoint with key
+	Allow(key EndpointKey, clientKey string) RateLimit
+}
+
+// RateLimitClientKey identifies the client with identity ID (if any) or with IP
+func RateLimitClientKey(req *http.Request, identity *auth.Identity, trustedProxies []*net.IPNet) string {
+	if identity != nil && identity.ID != "" {
+		return "id:" + string(identity.ID)
+	}
+
+	return "ip:" + ClientIP(req, trustedProxies)
+}
+
+// ClientIP returns the remote IP or (if the request is received from trusted proxy) the rightmost untrusted IP from X-Forwarded-For header
+func ClientIP(req *http.Request, trustedProxies []*net.IPNet) string {
+	ip := RemoteIP(req)
+	if !isTrusted(ip, trustedProxies) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		forwardedIP := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(forwardedIP) == nil {
+			break
+		}
+		ip = forwardedIP
+		if !isTrusted(ip, trustedProxies) {
+			break
+		}
+	}
+
+	return ip
+}
+
+func isTrusted(ipStr string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseTrustedProxies accepts IPs and CIDRs
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var trustedProxies []*net.IPNet
+
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("wrong trusted proxy IP (%s)", proxy)
+			}
+			if ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, errors.CommonError(err, fmt.Sprintf("wrong trusted proxy CIDR (%s)", proxy))
+		}
+		trustedProxies = append(trustedProxies, ipNet)
+	}
+
+	return trustedProxies, nil
+}
+
+// in-memory token bucket rate limiter ----------------------------------------------------------------------------------
+
+var _ RateLimiter = &rateLimiter{}
+
+type rateLimiter struct {
+	global    *buckets
+	endpoints map[EndpointKey]*buckets
+	now       func() time.Time
+
+	mutex sync.Mutex
+}
+
+const onNewRateLimiter = "on server_http.NewRateLimiter()"
+
+func NewRateLimiter(cfg server.RateLimitConfig) (RateLimiter, error) {
+	maxClients := cfg.MaxClients
+	if maxClients <= 0 {
+		maxClients = DefaultRateLimitMaxClients
+	}
+
+	rl := rateLimiter{endpoints: map[EndpointKey]*buckets{}, now: time.Now}
+
+	if cfg.Global != nil {
+		var err error
+		if rl.global, err = newBuckets(*cfg.Global, maxClients); err != nil {
+			return nil, errors.CommonError(err, onNewRateLimiter+": global rule")
+		}
+	}
+
+	for key, rule := range cfg.Endpoints {
+		b, err := newBuckets(rule, maxClients)
+		if err != nil {
+			return nil, errors.CommonError(err, onNewRateLimiter+": rule for "+key)
+		}
+		rl.endpoints[EndpointKey(key)] = b
+	}
+
+	return &rl, nil
+}
+
+func (rl *rateLimiter) Allow(key EndpointKey, clientKey string) RateLimit {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	now := rl.now()
+
+	var toCheck []*bucket
+	if rl.global != nil {
+		toCheck = append(toCheck, rl.global.get(clientKey, now))
+	}
+	if b := rl.endpoints[key]; b != nil {
+		toCheck = append(toCheck, b.get(clientKey, now))
+	}
+
+	if len(toCheck) < 1 {
+		return RateLimit{Allowed: true}
+	}
+
+	allowed := true
+	for _, b := range toCheck {
+		if b.tokens < 1 {
+			allowed = false
+		}
+	}
+
+	// the most restrictive bucket is reported
+	var rateLimit RateLimit
+	for i, b := range toCheck {
+		if allowed {
+			b.tokens--
+		}
+		limit := b.rateLimit(allowed)
+		if i == 0 || (allowed && limit.Remaining < rateLimit.Remaining) || (!allowed && limit.RetryAfter > rateLimit.RetryAfter) {
+			rateLimit = limit
+		}
+	}
+
+	return rateLimit
+}
+
+// buckets keep the limited number of clients' buckets for the same rule evicting the least recently used ones
+type buckets struct {
+	rate       float64 // tokens per second
+	burst      int
+	maxClients int
+
+	items map[string]*list.Element
+	lru   *list.List
+}
+
+type bucket struct {
+	*buckets
+	clientKey string
+	tokens    float64
+	updated   time.Time
+}
+
+func newBuckets(rule server.RateLimitRule, maxClients int) (*buckets, error) {
+	if rule.Requests <= 0 || rule.Period <= 0 {
+		return nil, fmt.Errorf("wrong rate limit rule: %#v", rule)
+	}
+
+	burst := rule.Burst
+	if burst <= 0 {
+		burst = rule.Requests
+	}
+
+	return &buckets{
+		rate:       float64(rule.Requests) / rule.Period.Seconds(),
+		burst:      burst,
+		maxClients: maxClients,
+		items:      map[string]*list.Element{},
+		lru:        list.New(),
+	}, nil
+}
+
+func (bs *buckets) get(clientKey string, now time.Time) *bucket {
+	if element, ok := bs.items[clientKey]; ok {
+		bs.lru.MoveToFront(element)
+		b := element.Value.(*bucket)
+		b.tokens = math.Min(float64(bs.burst), b.tokens+now.Sub(b.updated).Seconds()*bs.rate)
+		b.updated = now
+		return b
+	}
+
+	for bs.lru.Len() >= bs.maxClients {
+		oldest := bs.lru.Back()
+		bs.lru.Remove(oldest)
+		delete(bs.items, oldest.Value.(*bucket).clientKey)
+	}
+
+	b := &bucket{buckets: bs, clientKey: clientKey, tokens: float64(bs.burst), updated: now}
+	bs.items[clientKey] = bs.lru.PushFront(b)
+
+	return b
+}
+
+func (b *bucket) rateLimit(allowed bool) RateLimit {
+	rateLimit := RateLimit{
+		Allowed:   allowed,
+		Limit:     b.burst,
+		Remaining: int(math.Floor(b.tokens)),
+		Reset:     secondsToDuration((float64(b.burst) - b.tokens) / b.rate),
+	}
+	if !allowed && b.tokens < 1 {
+		rateLimit.RetryAfter = secondsToDuration((1 - b.tokens) / b.rate)
+	}
+
+	return rateLimit
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// SetRateLimitHeaders sets RateLimit-* headers (and Retry-After if the request isn't allowed)
+func SetRateLimitHeaders(w http.ResponseWriter, rateLimit RateLimit) {
+	if rateLimit.Limit <= 0 {
+		return
+	}
+
+	w.Header().Set("RateLimit-Limit", fmt.Sprint(rateLimit.Limit))
+	w.Header().Set("RateLimit-Remaining", fmt.Sprint(rateLimit.Remaining))
+	w.Header().Set("RateLimit-Reset", fmt.Sprint(ceilSeconds(rateLimit.Reset)))
+	if !rateLimit.Allowed {
+		w.Header().Set("Retry-After", fmt.Sprint(ceilSeconds(rateLimit.RetryAfter)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,98 @@
+package server_http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/logger/logger_test"
+	"github.com/pavlo67/common/common/server"
+)
+
+func TestRateLimiter(t *testing.T) {
+	rlOp, err := NewRateLimiter(server.RateLimitConfig{
+		Global:     &server.RateLimitRule{Requests: 10, Period: time.Second},
+		Endpoints:  map[string]server.RateLimitRule{"limited": {Requests: 1, Period: time.Second, Burst: 2}},
+		MaxClients: 2,
+	})
+	require.NoError(t, err)
+
+	rl := rlOp.(*rateLimiter)
+	now := time.Now()
+	rl.now = func() time.Time { return now }
+
+	require.Equal(t, RateLimit{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}, rl.Allow("limited", "a"))
+	require.True(t, rl.Allow("limited", "a").Allowed)
+
+	rateLimit := rl.Allow("limited", "a")
+	require.False(t, rateLimit.Allowed)
+	require.Equal(t, time.Second, rateLimit.RetryAfter)
+
+	// other endpoints are limited with the global rule only
+	rateLimit = rl.Allow("other", "a")
+	require.True(t, rateLimit.Allowed)
+	require.Equal(t, 7, rateLimit.Remaining)
+
+	// other clients have their own buckets
+	require.True(t, rl.Allow("limited", "b").Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	require.False(t, rl.Allow("limited", "a").Allowed)
+	now = now.Add(500 * time.Millisecond)
+	require.True(t, rl.Allow("limited", "a").Allowed)
+
+	// the least recently used client "b" is evicted
+	require.True(t, rl.Allow("limited", "c").Allowed)
+	require.Equal(t, 2, rl.endpoints["limited"].lru.Len())
+	_, ok := rl.endpoints["limited"].items["b"]
+	require.False(t, ok)
+}
+
+func TestClientIP(t *testing.T) {
+	trustedProxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.1.1.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 2.2.2.2, 192.168.1.1")
+	require.Equal(t, "2.2.2.2", ClientIP(req, trustedProxies))
+	require.Equal(t, "10.1.1.1", ClientIP(req, nil))
+
+	req.RemoteAddr = "3.3.3.3:1234"
+	require.Equal(t, "3.3.3.3", ClientIP(req, trustedProxies))
+
+	require.Equal(t, "id:test_id", RateLimitClientKey(req, &auth.Identity{ID: "test_id"}, trustedProxies))
+	require.Equal(t, "ip:3.3.3.3", RateLimitClientKey(req, nil, trustedProxies))
+}
+
+func TestHandleRateLimited(t *testing.T) {
+	settings, err := HandleSettingsFromConfig(server.Config{
+		RateLimit: &server.RateLimitConfig{Endpoints: map[string]server.RateLimitRule{"test": {Requests: 1, Period: time.Minute}}},
+	}, onRequestMiddlewareTest(), nil, logger_test.New(t))
+	require.NoError(t, err)
+
+	handle := Handle(nil, "test", Endpoint{
+		EndpointDescription: EndpointDescription{Method: "GET"},
+		WorkerHTTP: func(_ context.Context, _ Operator, req *http.Request, _ PathParams, _ *auth.Identity) (server.Response, error) {
+			return ResponseRESTOk(0, "ok", req)
+		},
+	}, settings)
+
+	w := httptest.NewRecorder()
+	handle(w, httptest.NewRequest("GET", "/", nil), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = httptest.NewRecorder()
+	handle(w, httptest.NewRequest("GET", "/", nil), nil)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, []string{"Accept, Accept-Language"}, w.Header().Values("Vary"))
+	require.Equal(t, "60", w.Header().Get("Retry-After"))
+	require.JSONEq(t, `{"error_key":"too_many_requests","message":"Too many requests"}`, w.Body.String())
+}
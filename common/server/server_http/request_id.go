@@ -0,0 +1,51 @@
+package server_http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"regexp"
+
+	"github.com/pavlo67/common/common/strlib"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDLength = 16
+
+type requestIDContextKey struct{}
+
+var reRequestID = regexp.MustCompile(`^[\w\-.:]{1,128}$`)
+
+// RequestID returns the request ID set by server_http.Operator implementation (it's available for workers)
+func RequestID(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+
+	requestID, _ := req.Context().Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// WithRequestID gets the request ID from X-Request-ID header (or generates the new one) and puts it into request's context
+func WithRequestID(req *http.Request) (*http.Request, string) {
+	requestID := req.Header.Get(RequestIDHeader)
+	if !reRequestID.MatchString(requestID) {
+		requestID = strlib.RandomString(requestIDLength)
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), requestIDContextKey{}, requestID)), requestID
+}
+
+func RemoteIP(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
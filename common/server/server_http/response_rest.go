@@ -11,7 +11,7 @@ import (
 )
 
 const (
-	CORSAllowHeaders     = "authorization,content-type"
+	CORSAllowHeaders     = "authorization,content-type,idempotency-key,if-none-match"
 	CORSAllowMethods     = "HEAD,GET,POST,PUT,DELETE,OPTIONS"
 	CORSAllowOrigin      = "*"
 	CORSAllowCredentials = "true"
@@ -35,28 +35,41 @@ func Redirect(w http.ResponseWriter, req *http.Request) {
 	http.Redirect(w, req, target, http.StatusTemporaryRedirect)
 }
 
+// ResponseRESTError responds {"error_key": ..., "message": ...} or RFC 7807 problem details (according to ErrorSettings
+// of the request), the status (if it isn't set) and the message (in language accepted by the client) are taken from the error
+// key registry, the error text is added as "details" (or "detail" for problem details) if it's allowed for the current ENV
 func ResponseRESTError(status int, err error, req *http.Request) (server.Response, error) {
 	commonErr := errors.CommonError(err)
 
 	key := commonErr.Key()
-	data := common.Map{server.ErrorKey: key}
 
 	if status == 0 || status == http.StatusOK {
-		if key == common.NoCredsKey || key == common.InvalidCredsKey {
-			status = http.StatusUnauthorized
-		} else if key == common.NoUserKey || key == common.NoRightsKey {
-			status = http.StatusForbidden
-		} else if status == 0 || status == http.StatusOK {
-			status = http.StatusInternalServerError
-
-		} else {
-			status = http.StatusInternalServerError
-		}
+		status = errors.Status(commonErr)
+	}
+
+	var message string
+	if description, ok := errors.Describe(key); ok {
+		message = description.Message(Languages(req)...)
 	}
 
-	//if !strlib.In(s.secretENVsToLower, strings.ToLower(os.Getenv("ENV"))) {
-	//	data["details"] = commonErr.Error()
-	//}
+	errorSettings := ErrorSettingsFromRequest(req)
+
+	var data common.Map
+	var mimeType string
+	if errorSettings.ProblemJSON {
+		data, mimeType = Problem(status, commonErr, RequestID(req), errorSettings), server.MIMETypeProblemJSON
+		if message != "" {
+			data["title"] = message
+		}
+	} else {
+		data = common.Map{server.ErrorKey: key}
+		if message != "" {
+			data["message"] = message
+		}
+		if errorSettings.ExposeDetails && commonErr != nil {
+			data["details"] = commonErr.Error()
+		}
+	}
 
 	jsonBytes, errJSON := json.Marshal(data)
 	if errJSON != nil {
@@ -67,7 +80,22 @@ func ResponseRESTError(status int, err error, req *http.Request) (server.Respons
 		commonErr = commonErr.Append(fmt.Errorf("on %s %s", req.Method, req.URL))
 	}
 
-	return server.Response{Status: status, Data: jsonBytes}, commonErr
+	return server.Response{Status: status, Data: jsonBytes, MIMEType: mimeType}, commonErr
+}
+
+// SetVaryNegotiated marks the response as depending on Accept (the format chosen by ResponseRESTOk) and Accept-Language
+// (the message chosen by ResponseRESTError) headers, so shared caches don't mix the representations
+func SetVaryNegotiated(w http.ResponseWriter) {
+	w.Header().Add("Vary", "Accept, Accept-Language")
+}
+
+// Languages returns languages from Accept-Language header of the request in the order of preference
+func Languages(req *http.Request) []string {
+	if req == nil {
+		return nil
+	}
+
+	return ParseAccept(req.Header.Get("Accept-Language"))
 }
 
 func ResponseRESTOk(status int, data interface{}, req *http.Request) (server.Response, error) {
@@ -95,16 +123,40 @@ func ResponseRESTOk(status int, data interface{}, req *http.Request) (server.Res
 			dataBytes = []byte(*v)
 		}
 	default:
+		var accept string
+		if req != nil {
+			accept = req.Header.Get("Accept")
+		}
+
+		offered := []string{MIMETypeJSON, MIMETypeYAML}
+		if IsList(data) {
+			offered = append(offered, MIMETypeCSV)
+		}
+
+		// JSON is used if nothing offered is acceptable
+		mimeType := NegotiateMIMEType(accept, offered...)
+
 		var err error
-		if dataBytes, err = json.Marshal(data); err != nil {
+		switch mimeType {
+		case MIMETypeYAML:
+			dataBytes, err = MarshalYAML(data)
+		case MIMETypeCSV:
+			dataBytes, err = MarshalCSV(data)
+		default:
+			mimeType = MIMETypeJSON
+			dataBytes, err = json.Marshal(data)
+		}
+		if err != nil {
 			if req != nil {
-				err = fmt.Errorf("on %s %s: can't marshal json (%#v), got %s", req.Method, req.URL, data, err)
+				err = fmt.Errorf("on %s %s: can't marshal %s (%#v), got %s", req.Method, req.URL, mimeType, data, err)
 			} else {
-				err = fmt.Errorf("can't marshal json (%#v): %s", data, err)
+				err = fmt.Errorf("can't marshal %s (%#v): %s", mimeType, data, err)
 			}
 
 			return server.Response{Status: http.StatusInternalServerError}, err
 		}
+
+		return server.Response{Status: status, Data: dataBytes, MIMEType: mimeType}, nil
 	}
 
 	return server.Response{Status: status, Data: dataBytes}, nil
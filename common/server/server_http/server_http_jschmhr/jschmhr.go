@@ -6,43 +6,52 @@ import (
 	"net/http"
 	"os"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/server"
 	"github.com/pavlo67/common/common/server/server_http"
 )
 
 var _ server_http.Operator = &serverHTTPJschmhr{}
+var _ http.Handler = &serverHTTPJschmhr{}
 
 type serverHTTPJschmhr struct {
 	httpServer   *http.Server
 	httpServeMux *httprouter.Router
 
-	port        int
-	tlsCertFile string
-	tlsKeyFile  string
+	config server.Config
 
-	onRequest server_http.OnRequestMiddleware
-
-	secretENVsToLower []string
+	handleSettings server_http.HandleSettings
 }
 
-func New(port int, tlsCertFile, tlsKeyFile string, onRequest server_http.OnRequestMiddleware, secretENVs []string) (server_http.Operator, error) {
-	if port <= 0 {
-		return nil, fmt.Errorf("on server_http_jschmhr.New(): wrong port = %d", port)
+// New creates the server, onAccess, idempotencyStore and responseCache are optional (they can be nil, in-memory implementations
+// are used without two last ones), secretENVs replace config.SecretENVs if they are set
+func New(config server.Config, onRequest server_http.OnRequestMiddleware, onAccess server_http.OnAccessMiddleware, idempotencyStore server_http.IdempotencyStore, responseCache server_http.ResponseCache, secretENVs []string) (server_http.Operator, error) {
+	if config.Port <= 0 {
+		return nil, fmt.Errorf("on server_http_jschmhr.New(): wrong port = %d", config.Port)
 	}
 
 	if onRequest == nil {
 		return nil, errors.New("on server_http_jschmhr.New(): no server_http.OnRequestMiddleware")
 	}
 
-	var secretENVsToLower []string
-	for _, secretENV := range secretENVs {
-		secretENVsToLower = append(secretENVsToLower, strings.ToLower(secretENV))
+	handleSettings, err := server_http.HandleSettingsFromConfig(config, onRequest, onAccess, nil)
+	if err != nil {
+		return nil, errors.CommonError(err, "on server_http_jschmhr.New()")
+	}
+	if idempotencyStore != nil {
+		handleSettings.IdempotencyStore = idempotencyStore
+	}
+	if responseCache != nil {
+		handleSettings.ResponseCache = responseCache
+	}
+
+	if len(secretENVs) > 0 {
+		handleSettings.Errors.ExposeDetails = server_http.ExposeErrorDetails(secretENVs)
 	}
 
 	router := httprouter.New()
@@ -55,34 +64,27 @@ func New(port int, tlsCertFile, tlsKeyFile string, onRequest server_http.OnReque
 			MaxHeaderBytes: 1 << 20,
 		},
 		httpServeMux: router,
-		port:         port,
-		tlsCertFile:  tlsCertFile,
-		tlsKeyFile:   tlsKeyFile,
-
-		onRequest: onRequest,
+		config:       config,
 
-		secretENVsToLower: secretENVsToLower,
+		handleSettings: handleSettings,
 	}, nil
 }
 
-// start wraps and verbalizes http.Server.ListenAndServe method.
 func (s *serverHTTPJschmhr) Start() error {
 	if s == nil {
 		return errors.New("no serverOp to start")
 	}
 
-	s.httpServer.Addr = ":" + strconv.Itoa(s.port)
-	l.Info("Server is starting on address ", s.httpServer.Addr)
-
-	if s.tlsCertFile != "" && s.tlsKeyFile != "" {
-		return s.httpServer.ListenAndServeTLS(s.tlsCertFile, s.tlsKeyFile)
-	}
-
-	return s.httpServer.ListenAndServe()
+	return server_http.ListenAndServe(s.httpServer, s.config, l)
 }
 
 func (s *serverHTTPJschmhr) Addr() (port int, https bool) {
-	return s.port, s.tlsCertFile != "" && s.tlsKeyFile != ""
+	return s.config.Port, s.config.HTTPS()
+}
+
+// ServeHTTP allows to use the server routing in-process (with httptest.Server, etc.)
+func (s *serverHTTPJschmhr) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.httpServeMux.ServeHTTP(w, r)
 }
 
 //func (s *serverHTTPJschmhr) ServerHTTP() *http.Server {
@@ -96,18 +98,16 @@ func (s *serverHTTPJschmhr) HandleEndpoint(key server_http.EndpointKey, serverPa
 	method := strings.ToUpper(endpoint.Method)
 	path := endpoint.PathTemplate(serverPath)
 
-	if endpoint.WorkerHTTP == nil {
-		return errors.New(onHandleEndpoint + ": " + method + ": " + path + "\t!!! NULL workerHTTP ISN'T DISPATCHED !!!")
+	if err := endpoint.Check(); err != nil {
+		return errors.New(onHandleEndpoint + ": " + method + ": " + path + "\t!!! " + err.Error() + " !!!")
 	}
 
 	s.HandleOptions(key, path)
 
+	handleSettings := s.handleSettings
+	handleSettings.Logger = l
+	handle := server_http.Handle(s, key, endpoint, handleSettings)
 	handler := func(w http.ResponseWriter, r *http.Request, paramsHR httprouter.Params) {
-		options, err := s.onRequest.Identity(r)
-		if err != nil {
-			l.Error(err)
-		}
-
 		var params server_http.PathParams
 		if len(paramsHR) > 0 {
 			params = server_http.PathParams{}
@@ -116,33 +116,7 @@ func (s *serverHTTPJschmhr) HandleEndpoint(key server_http.EndpointKey, serverPa
 			}
 		}
 
-		w.Header().Set("Access-Control-Allow-Origin", server_http.CORSAllowOrigin)
-		w.Header().Set("Access-Control-Allow-Headers", server_http.CORSAllowHeaders)
-		w.Header().Set("Access-Control-Allow-Methods", server_http.CORSAllowMethods)
-		w.Header().Set("Access-Control-Allow-Credentials", server_http.CORSAllowCredentials)
-
-		responseData, err := endpoint.WorkerHTTP(s, r, params, options)
-		if err != nil {
-			l.Error(err)
-		}
-
-		if responseData.MIMEType != "" {
-			w.Header().Set("Content-Type", responseData.MIMEType)
-		}
-		w.Header().Set("Content-Length", strconv.Itoa(len(responseData.Data)))
-		if responseData.FileName != "" {
-			w.Header().Set("Content-Disposition", "attachment; filename="+responseData.FileName)
-		}
-
-		if responseData.Status > 0 {
-			w.WriteHeader(responseData.Status)
-		} else {
-			w.WriteHeader(http.StatusOK)
-		}
-
-		if _, err := w.Write(responseData.Data); err != nil {
-			l.Error("can't write response", err)
-		}
+		handle(w, r, params)
 	}
 
 	l.Infof("%-10s: %s %s", key, method, path)
@@ -170,10 +144,7 @@ func (s *serverHTTPJschmhr) HandleOptions(key server_http.EndpointKey, serverPat
 
 	s.httpServeMux.OPTIONS(serverPath, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		l.Infof("%-10s: OPTIONS %s", key, serverPath)
-		w.Header().Set("Access-Control-Allow-Origin", server_http.CORSAllowOrigin)
-		w.Header().Set("Access-Control-Allow-Headers", server_http.CORSAllowHeaders)
-		w.Header().Set("Access-Control-Allow-Methods", server_http.CORSAllowMethods)
-		w.Header().Set("Access-Control-Allow-Credentials", server_http.CORSAllowCredentials)
+		server_http.SetCORSHeaders(w)
 	})
 
 	//s.handledOptions = append(s.handledOptions, serverPath)
@@ -184,39 +155,41 @@ var reHTMLExt = regexp.MustCompile(`\.html?$`)
 func (s *serverHTTPJschmhr) HandleFiles(key server_http.EndpointKey, serverPath string, staticPath server_http.StaticPath) error {
 	l.Infof("%-10s: FILES %s <-- %s", key, serverPath, staticPath.LocalPath)
 
-	// TODO: check localPath
+	fileSystem := server_http.StaticFileSystem(staticPath.LocalPath)
 
 	if staticPath.MIMEType == nil {
 		// TODO!!! CORS
 
-		s.httpServeMux.ServeFiles(serverPath, http.Dir(staticPath.LocalPath))
+		s.httpServeMux.ServeFiles(serverPath, fileSystem)
 		return nil
 	}
 
 	s.HandleOptions(key, serverPath)
 
-	//fileServer := http.FileServer(http.Dir(localPath))
 	s.httpServeMux.GET(serverPath, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		w.Header().Set("Access-Control-Allow-Origin", server_http.CORSAllowOrigin)
-		w.Header().Set("Access-Control-Allow-Headers", server_http.CORSAllowHeaders)
-		w.Header().Set("Access-Control-Allow-Methods", server_http.CORSAllowMethods)
-		w.Header().Set("Access-Control-Allow-Credentials", server_http.CORSAllowCredentials)
+		server_http.SetCORSHeaders(w)
 
-		if staticPath.MIMEType != nil && *staticPath.MIMEType != "" {
+		if *staticPath.MIMEType != "" {
 			w.Header().Set("Content-Type", *staticPath.MIMEType)
 		}
 
-		OpenFile, err := os.Open(staticPath.LocalPath + "/" + p.ByName("filepath"))
-		defer OpenFile.Close()
-		if err != nil {
+		file, err := fileSystem.Open(p.ByName("filepath"))
+		if os.IsPermission(err) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		} else if err != nil {
 			l.Error(err)
-		} else {
-			io.Copy(w, OpenFile)
+			http.NotFound(w, r)
+			return
+		}
+		defer file.Close()
+
+		if fileInfo, err := file.Stat(); err != nil || fileInfo.IsDir() {
+			http.NotFound(w, r)
+			return
 		}
 
-		//if mimeType != nil {
-		//}
-		//fileServer.ServeHTTP(w, r)
+		io.Copy(w, file)
 	})
 
 	return nil
@@ -0,0 +1,25 @@
+package server_http_jschmhr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/logger/logger_zap"
+	"github.com/pavlo67/common/common/server"
+	"github.com/pavlo67/common/common/server/server_http"
+)
+
+func TestServerHTTPJschmhr(t *testing.T) {
+	var err error
+	l, err = logger_zap.New(logger.Config{})
+	require.NoError(t, err)
+	require.NotNil(t, l)
+
+	newOperator := func(onRequest server_http.OnRequestMiddleware) (server_http.Operator, error) {
+		return New(server.Config{Port: 1}, onRequest, nil, nil, nil, nil)
+	}
+
+	server_http.OperatorTestScenario(t, newOperator, l)
+}
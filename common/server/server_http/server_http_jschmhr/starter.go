@@ -52,10 +52,16 @@ func (ss *server_http_jschmhrStarter) Run(joinerOp joiner.Operator) error {
 		return fmt.Errorf("no server_http.OnRequestMiddleware with key %s", server_http.OnRequestMiddlewareInterfaceKey)
 	}
 
-	// TODO!!! customize it
-	var secretENVs []string
+	// optional, it should be joined before the server is started (e.g. by metrics_prometheus.Starter())
+	onAccess, _ := joinerOp.Interface(server_http.OnAccessMiddlewareInterfaceKey).(server_http.OnAccessMiddleware)
 
-	srvOp, err := New(ss.config.Port, ss.config.TLSCertFile, ss.config.TLSKeyFile, onRequest, secretENVs)
+	// optional, it should be joined before the server is started (e.g. by idempotency_sqlite.Starter())
+	idempotencyStore, _ := joinerOp.Interface(server_http.IdempotencyStoreInterfaceKey).(server_http.IdempotencyStore)
+
+	// optional, it should be joined before the server is started (to invalidate cached responses outside of endpoint workers)
+	responseCache, _ := joinerOp.Interface(server_http.ResponseCacheInterfaceKey).(server_http.ResponseCache)
+
+	srvOp, err := New(ss.config, onRequest, onAccess, idempotencyStore, responseCache, ss.config.SecretENVs)
 	if err != nil {
 		return errors.Wrap(err, "on server_http_jschmhr.New()")
 	}
@@ -0,0 +1,222 @@
+package server_http_servemux
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/server"
+	"github.com/pavlo67/common/common/server/server_http"
+)
+
+var _ server_http.Operator = &serverHTTPServeMux{}
+var _ http.Handler = &serverHTTPServeMux{}
+
+type serverHTTPServeMux struct {
+	httpServer   *http.Server
+	httpServeMux *http.ServeMux
+
+	config server.Config
+
+	handleSettings server_http.HandleSettings
+
+	handledOptions map[string]bool
+	mutex          sync.Mutex
+}
+
+// New creates the server, onAccess, idempotencyStore and responseCache are optional (they can be nil, in-memory implementations
+// are used without two last ones), secretENVs replace config.SecretENVs if they are set
+func New(config server.Config, onRequest server_http.OnRequestMiddleware, onAccess server_http.OnAccessMiddleware, idempotencyStore server_http.IdempotencyStore, responseCache server_http.ResponseCache, secretENVs []string) (server_http.Operator, error) {
+	if config.Port <= 0 {
+		return nil, fmt.Errorf("on server_http_servemux.New(): wrong port = %d", config.Port)
+	}
+
+	if onRequest == nil {
+		return nil, errors.New("on server_http_servemux.New(): no server_http.OnRequestMiddleware")
+	}
+
+	handleSettings, err := server_http.HandleSettingsFromConfig(config, onRequest, onAccess, nil)
+	if err != nil {
+		return nil, errors.CommonError(err, "on server_http_servemux.New()")
+	}
+	if idempotencyStore != nil {
+		handleSettings.IdempotencyStore = idempotencyStore
+	}
+	if responseCache != nil {
+		handleSettings.ResponseCache = responseCache
+	}
+
+	if len(secretENVs) > 0 {
+		handleSettings.Errors.ExposeDetails = server_http.ExposeErrorDetails(secretENVs)
+	}
+
+	serveMux := http.NewServeMux()
+
+	return &serverHTTPServeMux{
+		httpServer: &http.Server{
+			Handler:        serveMux,
+			ReadTimeout:    60 * time.Second,
+			WriteTimeout:   60 * time.Second,
+			MaxHeaderBytes: 1 << 20,
+		},
+		httpServeMux: serveMux,
+		config:       config,
+
+		handleSettings: handleSettings,
+
+		handledOptions: map[string]bool{},
+	}, nil
+}
+
+func (s *serverHTTPServeMux) Start() error {
+	if s == nil {
+		return errors.New("no serverOp to start")
+	}
+
+	return server_http.ListenAndServe(s.httpServer, s.config, l)
+}
+
+func (s *serverHTTPServeMux) Addr() (port int, https bool) {
+	return s.config.Port, s.config.HTTPS()
+}
+
+// ServeHTTP allows to use the server routing in-process (with httptest.Server, etc.)
+func (s *serverHTTPServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.httpServeMux.ServeHTTP(w, r)
+}
+
+// handle registers the handler converting http.ServeMux panic on conflicting pattern into error
+func (s *serverHTTPServeMux) handle(pattern string, handler http.HandlerFunc) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("can't handle %s: %v", pattern, rec)
+		}
+	}()
+
+	s.httpServeMux.HandleFunc(pattern, handler)
+	return nil
+}
+
+const onHandleEndpoint = "on serverHTTPServeMux.HandleEndpoint()"
+
+func (s *serverHTTPServeMux) HandleEndpoint(key server_http.EndpointKey, serverPath string, endpoint server_http.Endpoint) error {
+
+	method := strings.ToUpper(endpoint.Method)
+	path := endpoint.PathPattern(serverPath)
+
+	if err := endpoint.Check(); err != nil {
+		return errors.New(onHandleEndpoint + ": " + method + ": " + path + "\t!!! " + err.Error() + " !!!")
+	}
+
+	switch method {
+	case "GET", "POST", "PUT", "DELETE":
+	default:
+		return fmt.Errorf(onHandleEndpoint+": method (%s) isn't supported", method)
+	}
+
+	handleSettings := s.handleSettings
+	handleSettings.Logger = l
+	handle := server_http.Handle(s, key, endpoint, handleSettings)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var params server_http.PathParams
+		if len(endpoint.PathParams) > 0 {
+			params = server_http.PathParams{}
+			for _, pp := range endpoint.PathParams {
+				if len(pp) > 0 && pp[0] == '*' {
+					// the same value as julienschmidt/httprouter catch-all param has
+					params[pp[1:]] = "/" + r.PathValue(pp[1:])
+				} else {
+					params[pp] = r.PathValue(pp)
+				}
+			}
+		}
+
+		handle(w, r, params)
+	}
+
+	l.Infof("%-10s: %s %s", key, method, path)
+	if err := s.handle(method+" "+path, handler); err != nil {
+		return errors.CommonError(err, onHandleEndpoint)
+	}
+
+	return s.HandleOptions(key, path)
+}
+
+func (s *serverHTTPServeMux) HandleOptions(key server_http.EndpointKey, serverPath string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.handledOptions[serverPath] {
+		return nil
+	}
+
+	if err := s.handle("OPTIONS "+serverPath, func(w http.ResponseWriter, r *http.Request) {
+		l.Infof("%-10s: OPTIONS %s", key, serverPath)
+		server_http.SetCORSHeaders(w)
+	}); err != nil {
+		return err
+	}
+
+	s.handledOptions[serverPath] = true
+	return nil
+}
+
+const onHandleFiles = "on serverHTTPServeMux.HandleFiles()"
+
+// HandleFiles serves files from staticPath.LocalPath, serverPath should end with "/*filepath" (like for julienschmidt/httprouter)
+func (s *serverHTTPServeMux) HandleFiles(key server_http.EndpointKey, serverPath string, staticPath server_http.StaticPath) error {
+	l.Infof("%-10s: FILES %s <-- %s", key, serverPath, staticPath.LocalPath)
+
+	if len(serverPath) < 10 || serverPath[len(serverPath)-10:] != "/*filepath" {
+		return errors.New(onHandleFiles + ": path must end with /*filepath in path " + serverPath)
+	}
+	prefix := serverPath[:len(serverPath)-len("*filepath")]
+	path := prefix + "{filepath...}"
+
+	if err := s.HandleOptions(key, path); err != nil {
+		return errors.CommonError(err, onHandleFiles)
+	}
+
+	fileSystem := server_http.StaticFileSystem(staticPath.LocalPath)
+	fileServer := http.StripPrefix(strings.TrimSuffix(prefix, "/"), http.FileServer(fileSystem))
+
+	if err := s.handle("GET "+path, func(w http.ResponseWriter, r *http.Request) {
+		server_http.SetCORSHeaders(w)
+
+		if staticPath.MIMEType == nil {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		if *staticPath.MIMEType != "" {
+			w.Header().Set("Content-Type", *staticPath.MIMEType)
+		}
+
+		file, err := fileSystem.Open("/" + r.PathValue("filepath"))
+		if os.IsPermission(err) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		} else if err != nil {
+			l.Error(err)
+			http.NotFound(w, r)
+			return
+		}
+		defer file.Close()
+
+		fileInfo, err := file.Stat()
+		if err != nil || fileInfo.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+
+		http.ServeContent(w, r, fileInfo.Name(), fileInfo.ModTime(), file)
+	}); err != nil {
+		return errors.CommonError(err, onHandleFiles)
+	}
+
+	return nil
+}
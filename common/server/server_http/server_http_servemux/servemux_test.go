@@ -0,0 +1,57 @@
+package server_http_servemux
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/logger/logger_zap"
+	"github.com/pavlo67/common/common/server"
+	"github.com/pavlo67/common/common/server/server_http"
+)
+
+func TestServerHTTPServeMux(t *testing.T) {
+	var err error
+	l, err = logger_zap.New(logger.Config{})
+	require.NoError(t, err)
+	require.NotNil(t, l)
+
+	newOperator := func(onRequest server_http.OnRequestMiddleware) (server_http.Operator, error) {
+		return New(server.Config{Port: 1}, onRequest, nil, nil, nil, nil)
+	}
+
+	server_http.OperatorTestScenario(t, newOperator, l)
+}
+
+// onRequestNone identifies nobody, endpoints are registered only here
+type onRequestNone struct{}
+
+func (*onRequestNone) Identity(*http.Request) (*auth.Identity, error) {
+	return nil, nil
+}
+
+func TestHandleEndpointConflict(t *testing.T) {
+	var err error
+	l, err = logger_zap.New(logger.Config{})
+	require.NoError(t, err)
+
+	srvOp, err := New(server.Config{Port: 1}, &onRequestNone{}, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	endpoint := server_http.Endpoint{
+		EndpointDescription: server_http.EndpointDescription{Method: "GET", PathParams: []string{"id"}},
+		WorkerHTTP: func(_ context.Context, _ server_http.Operator, req *http.Request, _ server_http.PathParams, _ *auth.Identity) (server.Response, error) {
+			return server_http.ResponseRESTOk(0, nil, req)
+		},
+	}
+
+	require.NoError(t, srvOp.HandleEndpoint("test", "/test", endpoint))
+	require.Error(t, srvOp.HandleEndpoint("test_conflict", "/test", endpoint))
+
+	endpoint.Method = "POST"
+	require.NoError(t, srvOp.HandleEndpoint("test_post", "/test", endpoint))
+}
@@ -0,0 +1,75 @@
+package server_http_servemux
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/config"
+	"github.com/pavlo67/common/common/joiner"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/server"
+	"github.com/pavlo67/common/common/server/server_http"
+	"github.com/pavlo67/common/common/starter"
+)
+
+func Starter() starter.Operator {
+	return &server_http_servemuxStarter{}
+}
+
+var l logger.Operator
+var _ starter.Operator = &server_http_servemuxStarter{}
+
+type server_http_servemuxStarter struct {
+	config server.Config
+
+	interfaceKey joiner.InterfaceKey
+}
+
+func (ss *server_http_servemuxStarter) Name() string {
+	return logger.GetCallInfo().PackageName
+}
+
+func (ss *server_http_servemuxStarter) Prepare(cfg *config.Config, options common.Map) error {
+	ss.interfaceKey = joiner.InterfaceKey(options.StringDefault("interface_key", string(server_http.InterfaceKey)))
+
+	configKey := options.StringDefault("config_key", "server_http")
+	if err := cfg.Value(configKey, &ss.config); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ss *server_http_servemuxStarter) Run(joinerOp joiner.Operator) error {
+	if l, _ = joinerOp.Interface(logger.InterfaceKey).(logger.Operator); l == nil {
+		return fmt.Errorf("no logger.Operator with key %s", logger.InterfaceKey)
+	}
+
+	onRequest, _ := joinerOp.Interface(server_http.OnRequestMiddlewareInterfaceKey).(server_http.OnRequestMiddleware)
+	if onRequest == nil {
+		return fmt.Errorf("no server_http.OnRequestMiddleware with key %s", server_http.OnRequestMiddlewareInterfaceKey)
+	}
+
+	// optional, it should be joined before the server is started (e.g. by metrics_prometheus.Starter())
+	onAccess, _ := joinerOp.Interface(server_http.OnAccessMiddlewareInterfaceKey).(server_http.OnAccessMiddleware)
+
+	// optional, it should be joined before the server is started (e.g. by idempotency_sqlite.Starter())
+	idempotencyStore, _ := joinerOp.Interface(server_http.IdempotencyStoreInterfaceKey).(server_http.IdempotencyStore)
+
+	// optional, it should be joined before the server is started (to invalidate cached responses outside of endpoint workers)
+	responseCache, _ := joinerOp.Interface(server_http.ResponseCacheInterfaceKey).(server_http.ResponseCache)
+
+	srvOp, err := New(ss.config, onRequest, onAccess, idempotencyStore, responseCache, ss.config.SecretENVs)
+	if err != nil {
+		return errors.Wrap(err, "on server_http_servemux.New()")
+	}
+
+	if err = joinerOp.Join(srvOp, ss.interfaceKey); err != nil {
+		return errors.Wrapf(err, "can't join *serverHTTPServeMux{} as server_http.Operator with key '%s'", ss.interfaceKey)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,206 @@
+package server_http
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/errors"
+)
+
+// Server-Sent Events -------------------------------------------------------------------------------------------------
+
+const DefaultSSEKeepAlive = 15 * time.Second
+
+const SSEMIMEType = "text/event-stream"
+const LastEventIDHeader = "Last-Event-ID"
+
+type Event struct {
+	ID    string
+	Event string
+	Data  []byte
+	Retry time.Duration
+}
+
+type EventSink interface {
+	Send(event Event) error
+
+	// LastEventID returns the ID of last event received by client before reconnect (if any)
+	LastEventID() string
+}
+
+// WorkerSSE sends events until it returns, ctx is canceled on client disconnect
+// (be careful: the stream duration is limited with the server's write timeout)
+type WorkerSSE func(context.Context, Operator, *http.Request, PathParams, *auth.Identity, EventSink) error
+
+var _ EventSink = &eventSink{}
+
+type eventSink struct {
+	ctx         context.Context
+	w           http.ResponseWriter
+	flusher     http.Flusher
+	lastEventID string
+
+	mutex sync.Mutex
+	bytes int
+}
+
+var sseNewLinesReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+func (es *eventSink) Send(event Event) error {
+	var text string
+	if event.ID != "" {
+		text += "id: " + sseNewLinesReplacer.Replace(event.ID) + "\n"
+	}
+	if event.Event != "" {
+		text += "event: " + sseNewLinesReplacer.Replace(event.Event) + "\n"
+	}
+	if event.Retry > 0 {
+		text += "retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n"
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(string(event.Data), "\r\n", "\n"), "\n") {
+		text += "data: " + line + "\n"
+	}
+
+	return es.write(text + "\n")
+}
+
+func (es *eventSink) LastEventID() string {
+	return es.lastEventID
+}
+
+func (es *eventSink) write(text string) error {
+	if err := es.ctx.Err(); err != nil {
+		return err
+	}
+
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+
+	n, err := es.w.Write([]byte(text))
+	es.bytes += n
+	if err != nil {
+		return err
+	}
+	es.flusher.Flush()
+
+	return nil
+}
+
+const onServeSSE = "on server_http.ServeSSE()"
+
+// ServeSSE writes the event stream headers, runs workerSSE and keeps the connection alive with comments
+// (it's used by server_http.Operator implementations)
+func ServeSSE(ctx context.Context, serverOp Operator, w http.ResponseWriter, req *http.Request, params PathParams, identity *auth.Identity,
+	workerSSE WorkerSSE, keepAlive time.Duration) (status, bytes int, err error) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming isn't supported", http.StatusInternalServerError)
+		return http.StatusInternalServerError, 0, fmt.Errorf(onServeSSE+": %T isn't http.Flusher", w)
+	}
+
+	w.Header().Set("Content-Type", SSEMIMEType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sink := &eventSink{ctx: ctx, w: w, flusher: flusher, lastEventID: req.Header.Get(LastEventIDHeader)}
+
+	if keepAlive <= 0 {
+		keepAlive = DefaultSSEKeepAlive
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := sink.write(": keep-alive\n\n"); err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err = workerSSE(ctx, serverOp, req, params, identity, sink)
+	cancel()
+	wg.Wait()
+
+	if err != nil && err != context.Canceled {
+		err = errors.CommonError(err, onServeSSE)
+	} else {
+		err = nil
+	}
+
+	return http.StatusOK, sink.bytes, err
+}
+
+// ReadEvents parses the event stream until n events are read (or until the stream end if n <= 0),
+// it's the client side counterpart of ServeSSE (comments are skipped)
+func ReadEvents(r io.Reader, n int) ([]Event, error) {
+	var events []Event
+	var event Event
+	var data []string
+	var hasData bool
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if hasData {
+				event.Data = []byte(strings.Join(data, "\n"))
+				events = append(events, event)
+				if n > 0 && len(events) >= n {
+					return events, nil
+				}
+			}
+			event, data, hasData = Event{}, nil, false
+			continue
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := line, ""
+		if pos := strings.IndexByte(line, ':'); pos >= 0 {
+			field, value = line[:pos], strings.TrimPrefix(line[pos+1:], " ")
+		}
+
+		switch field {
+		case "id":
+			event.ID = value
+		case "event":
+			event.Event = value
+		case "retry":
+			if retry, err := strconv.ParseInt(value, 10, 64); err == nil {
+				event.Retry = time.Duration(retry) * time.Millisecond
+			}
+		case "data":
+			data, hasData = append(data, value), true
+		}
+	}
+
+	return events, scanner.Err()
+}
@@ -0,0 +1,30 @@
+package server_http
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pavlo67/common/common/filelib"
+)
+
+var _ http.FileSystem = staticFileSystem("")
+
+// staticFileSystem is http.Dir confined with filelib.Confine (so symlinks can't lead outside of the served directory)
+type staticFileSystem string
+
+// StaticFileSystem is used by HandleFiles implementations to serve files from localPath
+func StaticFileSystem(localPath string) http.FileSystem {
+	return staticFileSystem(localPath)
+}
+
+// Open gets the URL path (starting with "/"), escaping paths are reported with os.ErrPermission (so http.FileServer
+// responds with 403)
+func (sfs staticFileSystem) Open(name string) (http.File, error) {
+	filePath, err := filelib.Confine(string(sfs), strings.TrimLeft(name, "/"))
+	if err != nil {
+		return nil, os.ErrPermission
+	}
+
+	return os.Open(filePath)
+}
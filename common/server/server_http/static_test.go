@@ -0,0 +1,40 @@
+package server_http
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticFileSystem(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "static")
+	require.NoError(t, os.MkdirAll(root, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "index.html"), []byte("index"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0644))
+
+	fileSystem := StaticFileSystem(root)
+
+	file, err := fileSystem.Open("/index.html")
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(file)
+	require.NoError(t, err)
+	require.Equal(t, "index", string(data))
+	require.NoError(t, file.Close())
+
+	_, err = fileSystem.Open("/nothing.html")
+	require.True(t, os.IsNotExist(err))
+
+	escaping := []string{"/../secret", "/a/../../secret", `/..\secret`, "/secret\x00"}
+	if err = os.Symlink(filepath.Join(dir, "secret"), filepath.Join(root, "link")); err == nil {
+		escaping = append(escaping, "/link")
+	}
+
+	for _, name := range escaping {
+		_, err = fileSystem.Open(name)
+		require.Truef(t, os.IsPermission(err), "%q: %v", name, err)
+	}
+}
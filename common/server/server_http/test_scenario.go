@@ -0,0 +1,753 @@
+package server_http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/pavlo67/common/common"
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/server"
+)
+
+// NewOperator creates the tested server_http.Operator implementation (it's never started, only its http.Handler is used)
+type NewOperator func(onRequest OnRequestMiddleware) (Operator, error)
+
+// TestCase describes a single in-process call to the endpoint settled with Key
+type TestCase struct {
+	Name     string
+	Key      EndpointKey
+	Params   []string
+	Query    string
+	Header   http.Header
+	Body     interface{}
+	Identity *auth.Identity
+
+	ExpectedStatus int
+	ExpectedJSON   interface{}
+	Check          func(t *testing.T, resp *http.Response, body []byte)
+}
+
+// TestServer serves the completed Config on httptest.Server with the tested server_http.Operator
+type TestServer struct {
+	*httptest.Server
+	Config Config
+}
+
+// on request middleware for tests --------------------------------------------------------------------
+
+const testIdentityHeader = "X-Test-Identity"
+
+var _ OnRequestMiddleware = &onRequestTest{}
+
+type onRequestTest struct{}
+
+// onRequestMiddlewareTest trusts the identity sent in X-Test-Identity header without any check, so it must never be used
+// outside of tests (it's an authentication bypass)
+func onRequestMiddlewareTest() OnRequestMiddleware {
+	return &onRequestTest{}
+}
+
+func (*onRequestTest) Identity(r *http.Request) (*auth.Identity, error) {
+	identityJSON := r.Header.Get(testIdentityHeader)
+	if identityJSON == "" {
+		return nil, nil
+	}
+
+	var identity auth.Identity
+	if err := json.Unmarshal([]byte(identityJSON), &identity); err != nil {
+		return nil, errors.CommonError(err, fmt.Sprintf("can't unmarshal test identity (%s)", identityJSON))
+	}
+
+	return &identity, nil
+}
+
+// test server ----------------------------------------------------------------------------------------
+
+const TestRouteHeader = "X-Test-Route"
+
+func StartTestServer(t *testing.T, newOperator NewOperator, cfg Config, endpoints Endpoints, l logger.Operator) *TestServer {
+	require.NotNil(t, newOperator)
+
+	endpointsSettled := EndpointsSettled{}
+	for key, ep := range cfg.EndpointsSettled {
+		endpointsSettled[key] = ep
+	}
+	cfg.EndpointsSettled = endpointsSettled
+
+	err := cfg.CompleteDirectly(endpoints, "", 0, cfg.Prefix)
+	require.NoError(t, err)
+
+	srvOp, err := newOperator(onRequestMiddlewareTest())
+	require.NoError(t, err)
+	require.NotNil(t, srvOp)
+
+	handler, _ := srvOp.(http.Handler)
+	require.NotNilf(t, handler, "%T doesn't implement http.Handler", srvOp)
+
+	cfgToHandle := cfg
+	cfgToHandle.EndpointsSettled = EndpointsSettled{}
+	for key, ep := range cfg.EndpointsSettled {
+		switch {
+		case ep.WorkerSSE != nil:
+			ep.WorkerSSE = routeCheckedSSE(key, ep.WorkerSSE)
+		case ep.WorkerWebSocket != nil:
+			ep.WorkerWebSocket = routeCheckedWebSocket(key, ep.WorkerWebSocket)
+		case ep.WorkerHTTP != nil:
+			ep.WorkerHTTP = routeChecked(key, ep.WorkerHTTP)
+		}
+		cfgToHandle.EndpointsSettled[key] = ep
+	}
+
+	err = cfgToHandle.HandleEndpoints(srvOp, l)
+	require.NoError(t, err)
+
+	return &TestServer{Server: httptest.NewServer(handler), Config: cfg}
+}
+
+// routeChecked allows to verify routing without the original worker call (to avoid any side effects)
+func routeChecked(key EndpointKey, workerHTTP WorkerHTTP) WorkerHTTP {
+	return func(ctx context.Context, serverOp Operator, req *http.Request, params PathParams, identity *auth.Identity) (server.Response, error) {
+		if req.Header.Get(TestRouteHeader) != "" {
+			return server.Response{Status: http.StatusOK, Data: []byte(key)}, nil
+		}
+
+		return workerHTTP(ctx, serverOp, req, params, identity)
+	}
+}
+
+const testRouteEvent = "route"
+
+func routeCheckedSSE(key EndpointKey, workerSSE WorkerSSE) WorkerSSE {
+	return func(ctx context.Context, serverOp Operator, req *http.Request, params PathParams, identity *auth.Identity, sink EventSink) error {
+		if req.Header.Get(TestRouteHeader) != "" {
+			return sink.Send(Event{Event: testRouteEvent, Data: []byte(key)})
+		}
+
+		return workerSSE(ctx, serverOp, req, params, identity, sink)
+	}
+}
+
+func routeCheckedWebSocket(key EndpointKey, workerWebSocket WorkerWebSocket) WorkerWebSocket {
+	return func(ctx context.Context, serverOp Operator, req *http.Request, params PathParams, identity *auth.Identity, conn WebSocketConn) error {
+		if req.Header.Get(TestRouteHeader) != "" {
+			return conn.Write(MessageText, []byte(key))
+		}
+
+		return workerWebSocket(ctx, serverOp, req, params, identity, conn)
+	}
+}
+
+func (ts *TestServer) request(t *testing.T, tc TestCase) (method, urlStr string, header http.Header) {
+	method, path, err := ts.Config.EP(tc.Key, tc.Params, false)
+	require.NoErrorf(t, err, "%#v", tc)
+
+	urlStr = ts.URL + path
+	if tc.Query != "" {
+		urlStr += "?" + strings.TrimPrefix(tc.Query, "?")
+	}
+
+	header = http.Header{}
+	for k, values := range tc.Header {
+		for _, v := range values {
+			header.Add(k, v)
+		}
+	}
+	if tc.Identity != nil {
+		identityJSON, err := json.Marshal(tc.Identity)
+		require.NoError(t, err)
+		header.Set(testIdentityHeader, string(identityJSON))
+	}
+
+	return method, urlStr, header
+}
+
+func (ts *TestServer) Do(t *testing.T, tc TestCase) (*http.Response, []byte) {
+	method, urlStr, header := ts.request(t, tc)
+
+	var body io.Reader
+	var err error
+	if tc.Body != nil {
+		var bodyBytes []byte
+		switch v := tc.Body.(type) {
+		case []byte:
+			bodyBytes = v
+		case string:
+			bodyBytes = []byte(v)
+		default:
+			bodyBytes, err = json.Marshal(v)
+			require.NoErrorf(t, err, "%#v", tc.Body)
+		}
+		body = bytes.NewReader(bodyBytes)
+	}
+
+	req, err := http.NewRequest(method, urlStr, body)
+	require.NoError(t, err)
+	req.Header = header
+
+	resp, err := ts.Client().Do(req)
+	require.NoErrorf(t, err, "%s %s", method, urlStr)
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, respBody
+}
+
+// Events reads n events from SSE endpoint (or all of them until the stream end if n <= 0)
+func (ts *TestServer) Events(t *testing.T, tc TestCase, n int) (*http.Response, []Event) {
+	method, urlStr, header := ts.request(t, tc)
+
+	req, err := http.NewRequest(method, urlStr, nil)
+	require.NoError(t, err)
+	req.Header = header
+
+	resp, err := ts.Client().Do(req)
+	require.NoErrorf(t, err, "%s %s", method, urlStr)
+	defer resp.Body.Close()
+
+	events, err := ReadEvents(resp.Body, n)
+	require.NoError(t, err)
+
+	return resp, events
+}
+
+// WebSocket connects to WebSocket endpoint (the connection should be closed by caller)
+func (ts *TestServer) WebSocket(t *testing.T, tc TestCase) WebSocketConn {
+	_, urlStr, header := ts.request(t, tc)
+
+	conn, _, err := DialWebSocket(context.Background(), urlStr, header)
+	require.NoErrorf(t, err, "%s", urlStr)
+
+	return conn
+}
+
+func (ts *TestServer) Run(t *testing.T, testCases []TestCase) {
+	for i, tc := range testCases {
+		name := tc.Name
+		if name == "" {
+			name = string(tc.Key)
+		}
+
+		t.Run(name, func(t *testing.T) {
+			resp, body := ts.Do(t, tc)
+
+			expectedStatus := tc.ExpectedStatus
+			if expectedStatus == 0 {
+				expectedStatus = http.StatusOK
+			}
+			require.Equalf(t, expectedStatus, resp.StatusCode, "test case #%d: %s", i, body)
+
+			if tc.ExpectedJSON != nil {
+				expectedJSON, err := json.Marshal(tc.ExpectedJSON)
+				require.NoError(t, err)
+				require.JSONEqf(t, string(expectedJSON), string(body), "test case #%d", i)
+			}
+
+			if tc.Check != nil {
+				tc.Check(t, resp, body)
+			}
+		})
+	}
+}
+
+var reSwaggerParam = regexp.MustCompile(`{[^}]+}`)
+
+// CheckSwagger verifies Swagger description of the server is the same as its real routing
+func (ts *TestServer) CheckSwagger(t *testing.T) {
+	swaggerJSON, err := ts.Config.SwaggerV2(false)
+	require.NoError(t, err)
+
+	var swagger struct {
+		Paths map[string]map[string]struct {
+			OperationID EndpointKey                `json:"operationId"`
+			Responses   map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+	}
+	err = json.Unmarshal(swaggerJSON, &swagger)
+	require.NoErrorf(t, err, "%s", swaggerJSON)
+
+	described := map[EndpointKey]bool{}
+
+	for path, methods := range swagger.Paths {
+		for method, operation := range methods {
+			ep, ok := ts.Config.EndpointsSettled[operation.OperationID]
+			require.Truef(t, ok, "no endpoint settled for %s %s (%s)", method, path, operation.OperationID)
+			require.Equalf(t, strings.ToUpper(ep.Method), strings.ToUpper(method), "wrong method for %s", operation.OperationID)
+			for _, errorKey := range ep.ErrorKeys {
+				status := strconv.Itoa(errors.Status(errors.CommonError(errorKey)))
+				require.Containsf(t, string(operation.Responses[status]), string(errorKey), "no %s response for %s", status, operation.OperationID)
+			}
+
+			urlStr := ts.URL + reSwaggerParam.ReplaceAllString(path, "test")
+
+			if ep.WorkerWebSocket != nil {
+				conn, _, err := DialWebSocket(context.Background(), urlStr, http.Header{TestRouteHeader: {"1"}})
+				require.NoErrorf(t, err, "%s %s isn't routed", method, path)
+				_, data, err := conn.Read()
+				require.NoError(t, err)
+				conn.Close(CloseNormal, "")
+
+				require.Equalf(t, string(operation.OperationID), string(data), "%s %s is routed to another endpoint", method, path)
+				described[operation.OperationID] = true
+				continue
+			}
+
+			req, err := http.NewRequest(strings.ToUpper(method), urlStr, nil)
+			require.NoError(t, err)
+			req.Header.Set(TestRouteHeader, "1")
+
+			resp, err := ts.Client().Do(req)
+			require.NoErrorf(t, err, "%s %s", method, path)
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			require.NoError(t, err)
+
+			require.Equalf(t, http.StatusOK, resp.StatusCode, "%s %s isn't routed: %s", method, path, body)
+
+			if ep.WorkerSSE != nil {
+				events, err := ReadEvents(bytes.NewReader(body), 1)
+				require.NoError(t, err)
+				require.Equalf(t, 1, len(events), "%s %s: %s", method, path, body)
+				body = events[0].Data
+			}
+			require.Equalf(t, string(operation.OperationID), string(body), "%s %s is routed to another endpoint", method, path)
+
+			described[operation.OperationID] = true
+		}
+	}
+
+	for key := range ts.Config.EndpointsSettled {
+		require.Truef(t, described[key], "endpoint %s isn't described in swagger", key)
+	}
+}
+
+// test scenario for server_http.Operator implementations ---------------------------------------------
+
+const testEchoKey EndpointKey = "test_echo"
+const testEchoPostKey EndpointKey = "test_echo_post"
+const testErrorKey EndpointKey = "test_error"
+const testPanicKey EndpointKey = "test_panic"
+const testTimeoutKey EndpointKey = "test_timeout"
+const testSSEKey EndpointKey = "test_sse"
+const testWebSocketKey EndpointKey = "test_websocket"
+const testWebSocketOrigin = "https://allowed.example.com"
+const testListKey EndpointKey = "test_list"
+const testIdempotentKey EndpointKey = "test_idempotent"
+
+const testEventsNumber = 3
+
+type testEcho struct {
+	RequestID string         `json:",omitempty"`
+	Method    string         `json:",omitempty"`
+	Params    PathParams     `json:",omitempty"`
+	Query     string         `json:",omitempty"`
+	Body      string         `json:",omitempty"`
+	Identity  *auth.Identity `json:",omitempty"`
+}
+
+func testEchoWorker(_ context.Context, _ Operator, req *http.Request, params PathParams, identity *auth.Identity) (server.Response, error) {
+	echo := testEcho{RequestID: RequestID(req), Method: req.Method, Params: params, Query: req.URL.RawQuery, Identity: identity}
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return ResponseRESTError(http.StatusBadRequest, err, req)
+		}
+		echo.Body = string(body)
+	}
+
+	return ResponseRESTOk(0, echo, req)
+}
+
+var testIdempotentCalls int64
+
+func testErrorJSON(key common.ErrorKey) map[string]string {
+	errorJSON := map[string]string{server.ErrorKey: string(key)}
+	if description, ok := errors.Describe(key); ok {
+		errorJSON["message"] = description.Message()
+	}
+
+	return errorJSON
+}
+
+var testList = []testEcho{{Method: "GET", Params: PathParams{"p": "1"}}, {Method: "POST", Body: "a,b"}}
+
+var testEndpoints = Endpoints{
+	{
+		EndpointDescription: EndpointDescription{InternalKey: testEchoKey, Method: "GET", PathParams: []string{"p1", "p2"}, QueryParams: []string{"q"}},
+		WorkerHTTP:          testEchoWorker,
+	},
+	{
+		EndpointDescription: EndpointDescription{InternalKey: testEchoPostKey, Method: "POST"},
+		WorkerHTTP:          testEchoWorker,
+	},
+	{
+		EndpointDescription: EndpointDescription{InternalKey: testErrorKey, Method: "DELETE", PathParams: []string{"id"}, ErrorKeys: []common.ErrorKey{common.NoCredsKey, common.NotFoundKey}},
+		WorkerHTTP: func(_ context.Context, _ Operator, req *http.Request, params PathParams, identity *auth.Identity) (server.Response, error) {
+			if identity == nil {
+				return ResponseRESTError(0, errors.CommonError(common.NoCredsKey, auth.ErrNoCreds), req)
+			}
+			return ResponseRESTError(http.StatusNotFound, errors.CommonError(common.NotFoundKey, common.Map{"params": params}), req)
+		},
+	},
+	{
+		EndpointDescription: EndpointDescription{InternalKey: testPanicKey, Method: "GET"},
+		WorkerHTTP: func(_ context.Context, _ Operator, req *http.Request, params PathParams, identity *auth.Identity) (server.Response, error) {
+			panic("test panic")
+		},
+	},
+	{
+		EndpointDescription: EndpointDescription{InternalKey: testTimeoutKey, Method: "GET", Timeout: 10 * time.Millisecond},
+		WorkerHTTP: func(ctx context.Context, _ Operator, req *http.Request, params PathParams, identity *auth.Identity) (server.Response, error) {
+			select {
+			case <-ctx.Done():
+				return ResponseRESTError(http.StatusGatewayTimeout, errors.CommonError(common.CantPerformKey, ctx.Err()), req)
+			case <-time.After(time.Second):
+				return ResponseRESTOk(0, nil, req)
+			}
+		},
+	},
+	{
+		EndpointDescription: EndpointDescription{InternalKey: testListKey, Method: "GET"},
+		WorkerHTTP: func(_ context.Context, _ Operator, req *http.Request, params PathParams, identity *auth.Identity) (server.Response, error) {
+			return ResponseRESTOk(0, testList, req)
+		},
+	},
+	{
+		EndpointDescription: EndpointDescription{InternalKey: testIdempotentKey, Method: "POST", Idempotent: true},
+		WorkerHTTP: func(_ context.Context, _ Operator, req *http.Request, params PathParams, identity *auth.Identity) (server.Response, error) {
+			return ResponseRESTOk(0, atomic.AddInt64(&testIdempotentCalls, 1), req)
+		},
+	},
+	{
+		EndpointDescription: EndpointDescription{InternalKey: testSSEKey, Method: "GET"},
+		WorkerSSE:           testSSEWorker,
+	},
+	{
+		EndpointDescription: EndpointDescription{InternalKey: testWebSocketKey, Method: "GET", AllowedOrigins: []string{testWebSocketOrigin}},
+		WorkerWebSocket:     testWebSocketWorker,
+	},
+}
+
+func testSSEWorker(ctx context.Context, _ Operator, req *http.Request, params PathParams, identity *auth.Identity, sink EventSink) error {
+	var lastID int
+	if lastEventID := sink.LastEventID(); lastEventID != "" {
+		var err error
+		if lastID, err = strconv.Atoi(lastEventID); err != nil {
+			return errors.CommonError(err, "wrong last event id: "+lastEventID)
+		}
+	}
+
+	for id := lastID + 1; id <= testEventsNumber; id++ {
+		if err := sink.Send(Event{ID: strconv.Itoa(id), Event: "test", Data: []byte("event\n" + strconv.Itoa(id))}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func testWebSocketWorker(ctx context.Context, _ Operator, req *http.Request, params PathParams, identity *auth.Identity, conn WebSocketConn) error {
+	for {
+		messageType, data, err := conn.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = conn.Write(messageType, data); err != nil {
+			return err
+		}
+	}
+}
+
+var testConfig = Config{
+	ConfigCommon: ConfigCommon{Title: "server_http test", Version: "0.0.1", Prefix: "/test"},
+	EndpointsSettled: EndpointsSettled{
+		testEchoKey:       {Path: "/echo"},
+		testEchoPostKey:   {Path: "/echo_post"},
+		testErrorKey:      {Path: "/error"},
+		testPanicKey:      {Path: "/panic"},
+		testTimeoutKey:    {Path: "/timeout"},
+		testSSEKey:        {Path: "/sse"},
+		testWebSocketKey:  {Path: "/websocket"},
+		testListKey:       {Path: "/list"},
+		testIdempotentKey: {Path: "/idempotent"},
+	},
+}
+
+func OperatorTestScenario(t *testing.T, newOperator NewOperator, l logger.Operator) {
+	ts := StartTestServer(t, newOperator, testConfig, testEndpoints, l)
+	defer ts.Close()
+
+	identity := &auth.Identity{ID: "test_id", Nickname: "test_nickname"}
+
+	ts.Run(t, []TestCase{
+		{
+			Key:          testEchoKey,
+			Params:       []string{"a", "b c"},
+			Query:        "q=1",
+			Header:       http.Header{RequestIDHeader: {"test_request_id"}},
+			ExpectedJSON: testEcho{RequestID: "test_request_id", Method: "GET", Params: PathParams{"p1": "a", "p2": "b c"}, Query: "q=1"},
+		},
+		{
+			Name:   "test_echo with generated request id",
+			Key:    testEchoKey,
+			Params: []string{"a", "b"},
+			Header: http.Header{RequestIDHeader: {"wrong request id"}},
+			Check: func(t *testing.T, resp *http.Response, body []byte) {
+				var echo testEcho
+				require.NoError(t, json.Unmarshal(body, &echo))
+				require.NotEmpty(t, echo.RequestID)
+				require.NotEqual(t, "wrong request id", echo.RequestID)
+				require.Equal(t, echo.RequestID, resp.Header.Get(RequestIDHeader))
+			},
+		},
+		{
+			Name:         "test_echo_post with identity",
+			Key:          testEchoPostKey,
+			Body:         "body",
+			Header:       http.Header{RequestIDHeader: {"test_request_id"}},
+			Identity:     identity,
+			ExpectedJSON: testEcho{RequestID: "test_request_id", Method: "POST", Body: "body", Identity: identity},
+		},
+		{
+			Key:            testErrorKey,
+			Params:         []string{"1"},
+			ExpectedStatus: http.StatusUnauthorized,
+			ExpectedJSON:   testErrorJSON(common.NoCredsKey),
+		},
+		{
+			Name:           "test_error with identity",
+			Key:            testErrorKey,
+			Params:         []string{"1"},
+			Identity:       identity,
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedJSON:   testErrorJSON(common.NotFoundKey),
+		},
+		{
+			Name:   "test_echo as yaml",
+			Key:    testEchoKey,
+			Params: []string{"a", "b"},
+			Header: http.Header{"Accept": {"application/x-yaml;q=0.9, text/html;q=0.1"}},
+			Check: func(t *testing.T, resp *http.Response, body []byte) {
+				require.Equal(t, MIMETypeYAML, resp.Header.Get("Content-Type"))
+				var echo map[string]interface{}
+				require.NoError(t, yaml.Unmarshal(body, &echo))
+				require.Equal(t, "GET", echo["Method"])
+			},
+		},
+		{
+			Name:   "test_list as csv",
+			Key:    testListKey,
+			Header: http.Header{"Accept": {"text/csv"}},
+			Check: func(t *testing.T, resp *http.Response, body []byte) {
+				require.Equal(t, MIMETypeCSV, resp.Header.Get("Content-Type"))
+				require.Equal(t, "Method,Params,Body\nGET,\"{\"\"p\"\":\"\"1\"\"}\",\nPOST,,\"a,b\"\n", string(body))
+			},
+		},
+		{
+			Name:         "test_list as json if nothing is acceptable",
+			Key:          testListKey,
+			Header:       http.Header{"Accept": {"text/html"}},
+			ExpectedJSON: testList,
+		},
+		{
+			Name:   "test_echo_post gzipped",
+			Key:    testEchoPostKey,
+			Body:   strings.Repeat("body", DefaultCompressionMinSize),
+			Header: http.Header{"Accept-Encoding": {"deflate, gzip"}},
+			Check: func(t *testing.T, resp *http.Response, body []byte) {
+				require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+				require.Equal(t, strconv.Itoa(len(body)), resp.Header.Get("Content-Length"))
+				gr, err := gzip.NewReader(bytes.NewReader(body))
+				require.NoError(t, err)
+				var echo testEcho
+				require.NoError(t, json.NewDecoder(gr).Decode(&echo))
+				require.Equal(t, strings.Repeat("body", DefaultCompressionMinSize), echo.Body)
+			},
+		},
+		{
+			Name:   "test_echo_post not gzipped",
+			Key:    testEchoPostKey,
+			Body:   strings.Repeat("body", DefaultCompressionMinSize),
+			Header: http.Header{"Accept-Encoding": {"gzip;q=0, *"}},
+			Check: func(t *testing.T, resp *http.Response, body []byte) {
+				require.Empty(t, resp.Header.Get("Content-Encoding"))
+				require.Contains(t, resp.Header.Values("Vary"), "Accept-Encoding")
+			},
+		},
+		{
+			Key:            testPanicKey,
+			ExpectedStatus: http.StatusInternalServerError,
+			ExpectedJSON:   testErrorJSON(common.CantPerformKey),
+		},
+		{
+			Key:            testTimeoutKey,
+			ExpectedStatus: http.StatusGatewayTimeout,
+			ExpectedJSON:   testErrorJSON(common.CantPerformKey),
+		},
+	})
+
+	t.Run("test_list not modified", func(t *testing.T) {
+		resp, body := ts.Do(t, TestCase{Key: testListKey})
+		require.Equalf(t, http.StatusOK, resp.StatusCode, "%s", body)
+		etag := resp.Header.Get("ETag")
+		require.Equal(t, ETag(body), etag)
+		require.Contains(t, resp.Header.Values("Vary"), "Accept, Accept-Language")
+
+		resp, body = ts.Do(t, TestCase{Key: testListKey, Header: http.Header{"If-None-Match": {`"another", ` + etag}}})
+		require.Equal(t, http.StatusNotModified, resp.StatusCode)
+		require.Empty(t, body)
+		require.Equal(t, etag, resp.Header.Get("ETag"))
+		require.Contains(t, resp.Header.Values("Vary"), "Accept, Accept-Language")
+	})
+
+	t.Run("test_idempotent", func(t *testing.T) {
+		call := func(t *testing.T, tc TestCase) (*http.Response, int64) {
+			resp, body := ts.Do(t, tc)
+			require.Equalf(t, http.StatusOK, resp.StatusCode, "%s", body)
+			var n int64
+			require.NoError(t, json.Unmarshal(body, &n))
+			return resp, n
+		}
+
+		header := http.Header{IdempotencyHeader: {"test_idempotency_key"}}
+
+		_, n := call(t, TestCase{Key: testIdempotentKey, Body: "body", Header: header, Identity: identity})
+
+		resp, nReplayed := call(t, TestCase{Key: testIdempotentKey, Body: "body", Header: header, Identity: identity})
+		require.Equal(t, n, nReplayed)
+		require.Equal(t, "true", resp.Header.Get(IdempotencyReplayedHeader))
+
+		resp, nOther := call(t, TestCase{Key: testIdempotentKey, Body: "body", Header: header})
+		require.Equal(t, n+1, nOther)
+		require.Empty(t, resp.Header.Get(IdempotencyReplayedHeader))
+
+		_, nWithout := call(t, TestCase{Key: testIdempotentKey, Body: "body", Identity: identity})
+		require.Equal(t, n+2, nWithout)
+
+		ts.Run(t, []TestCase{{
+			Name:           "test_idempotent with reused key",
+			Key:            testIdempotentKey,
+			Body:           "another body",
+			Header:         header,
+			Identity:       identity,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+			ExpectedJSON:   testErrorJSON(IdempotencyKeyReusedKey),
+		}})
+	})
+
+	t.Run("test_sse", func(t *testing.T) {
+		resp, events := ts.Events(t, TestCase{Key: testSSEKey}, 0)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, SSEMIMEType, resp.Header.Get("Content-Type"))
+		require.Equal(t, testEventsNumber, len(events))
+		for i, event := range events {
+			require.Equal(t, Event{ID: strconv.Itoa(i + 1), Event: "test", Data: []byte("event\n" + strconv.Itoa(i+1))}, event)
+		}
+
+		_, events = ts.Events(t, TestCase{Key: testSSEKey, Header: http.Header{LastEventIDHeader: {"1"}}}, 0)
+		require.Equal(t, testEventsNumber-1, len(events))
+		require.Equal(t, "2", events[0].ID)
+	})
+
+	t.Run("test_websocket", func(t *testing.T) {
+		conn := ts.WebSocket(t, TestCase{Key: testWebSocketKey})
+
+		for _, messageType := range []MessageType{MessageText, MessageBinary} {
+			data := bytes.Repeat([]byte{'x'}, 70000)
+			require.NoError(t, conn.Write(messageType, data))
+
+			messageTypeRead, dataRead, err := conn.Read()
+			require.NoError(t, err)
+			require.Equal(t, messageType, messageTypeRead)
+			require.Equal(t, data, dataRead)
+		}
+
+		require.Error(t, conn.Write(MessageText, []byte{0xFF, 0xFE}))
+		require.NoError(t, conn.Close(CloseNormal, ""))
+
+		// the server closes the connection with CloseInvalidData after the text message isn't valid UTF-8
+		conn = ts.WebSocket(t, TestCase{Key: testWebSocketKey})
+		require.NoError(t, conn.Write(MessageBinary, []byte{0xFF, 0xFE}))
+		_, _, err := conn.Read()
+		require.NoError(t, err)
+		require.NoError(t, conn.(*webSocketConn).writeFrame(opText, []byte{0xFF, 0xFE}))
+		_, _, err = conn.Read()
+		require.Equal(t, io.EOF, err)
+		conn.Close(CloseNormal, "")
+
+		// cross-site connections are rejected
+		conn = ts.WebSocket(t, TestCase{Key: testWebSocketKey, Header: http.Header{"Origin": {testWebSocketOrigin}}})
+		require.NoError(t, conn.Close(CloseNormal, ""))
+
+		_, urlStr, header := ts.request(t, TestCase{Key: testWebSocketKey, Header: http.Header{"Origin": {"https://evil.example.com"}}})
+		_, resp, err := DialWebSocket(context.Background(), urlStr, header)
+		require.Error(t, err)
+		require.NotNil(t, resp)
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	ts.CheckSwagger(t)
+}
+
+// test scenario for server_http.IdempotencyStore implementations -------------------------------------
+
+func IdempotencyStoreTestScenario(t *testing.T, store IdempotencyStore) {
+	require.NotNil(t, store)
+
+	key, bodyHash := "test_key", "test_body_hash"
+	response := server.Response{Status: http.StatusCreated, Data: []byte(`{"id":1}`), MIMEType: MIMETypeJSON}
+
+	record, err := store.Start(key, bodyHash, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	record, err = store.Start(key, "another_body_hash", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	require.Equal(t, bodyHash, record.BodyHash)
+	require.Nil(t, record.Response)
+
+	require.NoError(t, store.Finish(key, response))
+
+	record, err = store.Start(key, bodyHash, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	require.Equal(t, &response, record.Response)
+
+	require.Error(t, store.Finish("wrong_key", response))
+
+	require.NoError(t, store.Remove(key))
+	record, err = store.Start(key, bodyHash, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	expiredKey := "test_expired_key"
+	record, err = store.Start(expiredKey, bodyHash, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	record, err = store.Start(expiredKey, bodyHash, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Nil(t, record)
+}
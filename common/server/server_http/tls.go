@@ -0,0 +1,214 @@
+package server_http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pavlo67/common/common/errors"
+	"github.com/pavlo67/common/common/logger"
+	"github.com/pavlo67/common/common/server"
+)
+
+// TLSReloadCheckInterval limits how often certificate files are checked for changes
+var TLSReloadCheckInterval = time.Second
+
+const onTLSConfig = "on server_http.TLSConfig()"
+
+// TLSConfig creates *tls.Config with the certificate reloaded on its files change (without a server restart),
+// reload errors are passed to onError (if it's not nil) and the previous certificate is still used
+func TLSConfig(cfg server.Config, onError func(error)) (*tls.Config, error) {
+	if !cfg.HTTPS() {
+		return nil, errors.New(onTLSConfig + ": no TLS certificate is configured")
+	}
+
+	reloader, err := newCertificateReloader(cfg.FilePath(cfg.TLSCertFile), cfg.FilePath(cfg.TLSKeyFile), onError)
+	if err != nil {
+		return nil, errors.CommonError(err, onTLSConfig)
+	}
+
+	tlsConfig := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	switch cfg.TLSMinVersion {
+	case "", "1.2":
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf(onTLSConfig+": wrong TLS min version (%s)", cfg.TLSMinVersion)
+	}
+
+	if len(cfg.TLSCipherSuites) > 0 {
+		cipherSuites := map[string]uint16{}
+		for _, cs := range tls.CipherSuites() {
+			cipherSuites[cs.Name] = cs.ID
+		}
+		for _, name := range cfg.TLSCipherSuites {
+			id, ok := cipherSuites[name]
+			if !ok {
+				return nil, fmt.Errorf(onTLSConfig+": unknown or insecure cipher suite (%s)", name)
+			}
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+		}
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		caPEM, err := ioutil.ReadFile(cfg.FilePath(cfg.TLSClientCAFile))
+		if err != nil {
+			return nil, errors.CommonError(err, onTLSConfig+": can't read client CA bundle")
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New(onTLSConfig + ": no certificates in client CA bundle " + cfg.TLSClientCAFile)
+		}
+
+		switch cfg.TLSClientAuth {
+		case "", server.TLSClientAuthRequire:
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		case server.TLSClientAuthVerifyIfGiven:
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf(onTLSConfig+": wrong TLS client auth mode (%s)", cfg.TLSClientAuth)
+		}
+	} else if cfg.TLSClientAuth != "" {
+		return nil, errors.New(onTLSConfig + ": TLS client auth requires client CA bundle")
+	}
+
+	return tlsConfig, nil
+}
+
+type certificateReloader struct {
+	certFile, keyFile string
+	onError           func(error)
+
+	mutex       sync.Mutex
+	certificate *tls.Certificate
+	modTime     time.Time
+	checked     time.Time
+}
+
+func newCertificateReloader(certFile, keyFile string, onError func(error)) (*certificateReloader, error) {
+	cr := &certificateReloader{certFile: certFile, keyFile: keyFile, onError: onError}
+	if err := cr.reload(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+// reload must be called under the mutex (or before the reloader is shared)
+func (cr *certificateReloader) reload(now time.Time) error {
+	cr.checked = now
+
+	modTime, err := cr.lastModTime()
+	if err != nil {
+		return err
+	}
+	if cr.certificate != nil && modTime.Equal(cr.modTime) {
+		return nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return errors.CommonError(err, fmt.Sprintf("can't load TLS certificate (%s, %s)", cr.certFile, cr.keyFile))
+	}
+
+	cr.certificate, cr.modTime = &certificate, modTime
+	return nil
+}
+
+func (cr *certificateReloader) lastModTime() (time.Time, error) {
+	var modTime time.Time
+	for _, file := range []string{cr.certFile, cr.keyFile} {
+		fileInfo, err := os.Stat(file)
+		if err != nil {
+			return modTime, errors.CommonError(err, "can't stat TLS file "+file)
+		}
+		if fileInfo.ModTime().After(modTime) {
+			modTime = fileInfo.ModTime()
+		}
+	}
+
+	return modTime, nil
+}
+
+func (cr *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	if now := time.Now(); now.Sub(cr.checked) >= TLSReloadCheckInterval {
+		if err := cr.reload(now); err != nil && cr.onError != nil {
+			cr.onError(err)
+		}
+	}
+
+	return cr.certificate, nil
+}
+
+// RedirectHandler redirects requests to HTTPS server listening on httpsPort (using Redirect)
+func RedirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		}
+
+		req.Host = host
+		Redirect(w, req)
+	})
+}
+
+const onListenAndServe = "on server_http.ListenAndServe()"
+
+// ListenAndServe starts httpServer with TLS settings from cfg (if they are set) and with the HTTP to HTTPS redirecting listener
+// (if cfg.HTTPRedirectPort is set), it's used by server_http.Operator implementations
+func ListenAndServe(httpServer *http.Server, cfg server.Config, l logger.Operator) error {
+	if httpServer == nil {
+		return errors.New(onListenAndServe + ": no http.Server to start")
+	}
+
+	httpServer.Addr = ":" + strconv.Itoa(cfg.Port)
+
+	if !cfg.HTTPS() {
+		l.Info("Server is starting on address ", httpServer.Addr)
+		return httpServer.ListenAndServe()
+	}
+
+	tlsConfig, err := TLSConfig(cfg, func(err error) { l.Error(err) })
+	if err != nil {
+		return errors.CommonError(err, onListenAndServe)
+	}
+	httpServer.TLSConfig = tlsConfig
+
+	if cfg.HTTPRedirectPort > 0 {
+		redirectServer := &http.Server{
+			Addr:           ":" + strconv.Itoa(cfg.HTTPRedirectPort),
+			Handler:        RedirectHandler(cfg.Port),
+			ReadTimeout:    10 * time.Second,
+			WriteTimeout:   10 * time.Second,
+			MaxHeaderBytes: 1 << 20,
+		}
+		go func() {
+			l.Info("HTTP to HTTPS redirect is starting on address ", redirectServer.Addr)
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				l.Error(err)
+			}
+		}()
+		httpServer.RegisterOnShutdown(func() { redirectServer.Close() })
+	}
+
+	l.Info("Server is starting with TLS on address ", httpServer.Addr)
+	return httpServer.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,492 @@
+package server_http
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pavlo67/common/common/auth"
+	"github.com/pavlo67/common/common/errors"
+)
+
+// WebSocket (RFC 6455) -----------------------------------------------------------------------------------------------
+
+type MessageType int
+
+const (
+	MessageText   MessageType = 1
+	MessageBinary MessageType = 2
+)
+
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseInvalidData   = 1007
+	CloseTooBig        = 1009
+	CloseInternalError = 1011
+)
+
+const WebSocketMaxMessageSize = 1 << 20
+
+type WebSocketConn interface {
+	// Read returns io.EOF after the close message is received
+	Read() (MessageType, []byte, error)
+	Write(messageType MessageType, data []byte) error
+	Close(code int, reason string) error
+}
+
+// WorkerWebSocket works with the connection until it returns (then the connection is closed), ctx is canceled when the connection is closed
+type WorkerWebSocket func(context.Context, Operator, *http.Request, PathParams, *auth.Identity, WebSocketConn) error
+
+var ErrWebSocketProtocol = errors.New("websocket protocol error")
+var ErrWebSocketTooBig = errors.New("websocket message is too big")
+var ErrWebSocketInvalidUTF8 = errors.New("websocket text message isn't valid UTF-8")
+
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+var _ WebSocketConn = &webSocketConn{}
+
+type webSocketConn struct {
+	conn     net.Conn
+	br       *bufio.Reader
+	isClient bool
+	onClose  func()
+
+	writeMutex sync.Mutex
+	closeOnce  sync.Once
+	bytes      int
+}
+
+func (wsc *webSocketConn) Read() (MessageType, []byte, error) {
+	var messageType MessageType
+	var message []byte
+
+	for {
+		fin, opcode, payload, err := wsc.readFrame()
+		if err != nil {
+			if err == ErrWebSocketTooBig {
+				wsc.Close(CloseTooBig, "")
+			} else if err == ErrWebSocketProtocol {
+				wsc.Close(CloseProtocolError, "")
+			} else {
+				wsc.close()
+			}
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err = wsc.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			code := CloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+				if !utf8.Valid(payload[2:]) {
+					wsc.Close(CloseInvalidData, "")
+					return 0, nil, ErrWebSocketInvalidUTF8
+				}
+			}
+			wsc.Close(code, "")
+			return 0, nil, io.EOF
+		case opText, opBinary:
+			if messageType != 0 {
+				wsc.Close(CloseProtocolError, "")
+				return 0, nil, ErrWebSocketProtocol
+			}
+			messageType = MessageType(opcode)
+		case opContinuation:
+			if messageType == 0 {
+				wsc.Close(CloseProtocolError, "")
+				return 0, nil, ErrWebSocketProtocol
+			}
+		default:
+			wsc.Close(CloseProtocolError, "")
+			return 0, nil, ErrWebSocketProtocol
+		}
+
+		if len(message)+len(payload) > WebSocketMaxMessageSize {
+			wsc.Close(CloseTooBig, "")
+			return 0, nil, ErrWebSocketTooBig
+		}
+		message = append(message, payload...)
+
+		if fin {
+			if messageType == MessageText && !utf8.Valid(message) {
+				wsc.Close(CloseInvalidData, "")
+				return 0, nil, ErrWebSocketInvalidUTF8
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+func (wsc *webSocketConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(wsc.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin, opcode = header[0]&0x80 != 0, header[0]&0x0F
+	masked, length := header[1]&0x80 != 0, uint64(header[1]&0x7F)
+
+	if header[0]&0x70 != 0 || masked == wsc.isClient {
+		// no extensions are negotiated, client frames must be masked and server ones mustn't
+		return false, 0, nil, ErrWebSocketProtocol
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(wsc.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(wsc.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if opcode >= opClose && (length > 125 || !fin) {
+		return false, 0, nil, ErrWebSocketProtocol
+	}
+	if length > WebSocketMaxMessageSize {
+		return false, 0, nil, ErrWebSocketTooBig
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(wsc.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(wsc.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return fin, opcode, payload, nil
+}
+
+func (wsc *webSocketConn) Write(messageType MessageType, data []byte) error {
+	if messageType != MessageText && messageType != MessageBinary {
+		return fmt.Errorf("wrong websocket message type: %d", messageType)
+	} else if messageType == MessageText && !utf8.Valid(data) {
+		return ErrWebSocketInvalidUTF8
+	}
+
+	return wsc.writeFrame(byte(messageType), data)
+}
+
+func (wsc *webSocketConn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode, 0}
+
+	switch length := len(payload); {
+	case length <= 125:
+		frame[1] = byte(length)
+	case length <= 0xFFFF:
+		frame[1] = 126
+		frame = append(frame, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame[1] = 127
+		frame = append(frame, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+
+	if wsc.isClient {
+		frame[1] |= 0x80
+
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ mask[i%4]
+		}
+		payload = masked
+	}
+
+	wsc.writeMutex.Lock()
+	defer wsc.writeMutex.Unlock()
+
+	n, err := wsc.conn.Write(append(frame, payload...))
+	wsc.bytes += n
+
+	return err
+}
+
+func (wsc *webSocketConn) Close(code int, reason string) error {
+	var err error
+
+	wsc.closeOnce.Do(func() {
+		payload := make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, reason...)
+		if len(payload) > 125 {
+			payload = payload[:125]
+		}
+
+		err = wsc.writeFrame(opClose, payload)
+		if errClose := wsc.conn.Close(); err == nil {
+			err = errClose
+		}
+		if wsc.onClose != nil {
+			wsc.onClose()
+		}
+	})
+
+	return err
+}
+
+func (wsc *webSocketConn) close() {
+	wsc.closeOnce.Do(func() {
+		wsc.conn.Close()
+		if wsc.onClose != nil {
+			wsc.onClose()
+		}
+	})
+}
+
+func webSocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func headerContains(header http.Header, name, value string) bool {
+	for _, v := range header.Values(name) {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// server side --------------------------------------------------------------------------------------------------------
+
+// CheckOrigin allows requests without Origin header (browsers always send it), same-origin requests and requests from
+// allowedOrigins ("scheme://host[:port]", "*" allows any origin), so other sites can't open WebSocket connection with
+// the credentials of the user's browser (cross-site WebSocket hijacking)
+func CheckOrigin(req *http.Request, allowedOrigins []string) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	} else if strings.EqualFold(u.Host, req.Host) {
+		return true
+	}
+
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+const onServeWebSocket = "on server_http.ServeWebSocket()"
+
+// ServeWebSocket upgrades the connection and runs workerWebSocket until it returns, requests from other origins than
+// allowedOrigins are rejected with 403 status (see CheckOrigin())
+// (it's used by server_http.Operator implementations, headers set on w before are sent with the handshake response)
+func ServeWebSocket(ctx context.Context, serverOp Operator, w http.ResponseWriter, req *http.Request, params PathParams, identity *auth.Identity,
+	workerWebSocket WorkerWebSocket, allowedOrigins []string) (status, bytes int, err error) {
+
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if req.Method != "GET" || !headerContains(req.Header, "Connection", "upgrade") || !headerContains(req.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "websocket handshake is expected", http.StatusBadRequest)
+		return http.StatusBadRequest, 0, errors.New(onServeWebSocket + ": websocket handshake is expected")
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return http.StatusUpgradeRequired, 0, errors.New(onServeWebSocket + ": unsupported websocket version")
+	}
+	if !CheckOrigin(req, allowedOrigins) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return http.StatusForbidden, 0, errors.New(onServeWebSocket + ": origin isn't allowed: " + req.Header.Get("Origin"))
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket isn't supported", http.StatusInternalServerError)
+		return http.StatusInternalServerError, 0, fmt.Errorf(onServeWebSocket+": %T isn't http.Hijacker", w)
+	}
+
+	header := w.Header().Clone()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", webSocketAccept(key))
+
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return http.StatusInternalServerError, 0, errors.CommonError(err, onServeWebSocket+": can't hijack the connection")
+	}
+
+	// the server's timeouts aren't suitable for the long-lived connection
+	if err = conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return http.StatusInternalServerError, 0, errors.CommonError(err, onServeWebSocket+": can't reset the connection deadline")
+	}
+
+	if _, err = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n"); err == nil {
+		if err = header.Write(brw); err == nil {
+			if _, err = brw.WriteString("\r\n"); err == nil {
+				err = brw.Flush()
+			}
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return http.StatusSwitchingProtocols, 0, errors.CommonError(err, onServeWebSocket+": can't write handshake response")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wsc := &webSocketConn{conn: conn, br: brw.Reader, onClose: cancel}
+
+	if err = workerWebSocket(ctx, serverOp, req, params, identity, wsc); err != nil {
+		wsc.Close(CloseInternalError, "")
+		return http.StatusSwitchingProtocols, wsc.bytes, errors.CommonError(err, onServeWebSocket)
+	}
+
+	wsc.Close(CloseNormal, "")
+	return http.StatusSwitchingProtocols, wsc.bytes, nil
+}
+
+// client side --------------------------------------------------------------------------------------------------------
+
+const onDialWebSocket = "on server_http.DialWebSocket()"
+
+// DialWebSocket connects to ws://, wss://, http:// or https:// URL (it's useful for tests and service-to-service calls)
+func DialWebSocket(ctx context.Context, urlStr string, header http.Header) (WebSocketConn, *http.Response, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onDialWebSocket+": wrong url "+urlStr)
+	}
+
+	var useTLS bool
+	switch u.Scheme {
+	case "ws", "http":
+		u.Scheme = "http"
+	case "wss", "https":
+		u.Scheme, useTLS = "https", true
+	default:
+		return nil, nil, fmt.Errorf(onDialWebSocket+": wrong url scheme (%s)", urlStr)
+	}
+
+	address := u.Host
+	if u.Port() == "" {
+		if useTLS {
+			address += ":443"
+		} else {
+			address += ":80"
+		}
+	}
+
+	var conn net.Conn
+	if useTLS {
+		conn, err = (&tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, nil, errors.CommonError(err, onDialWebSocket+": can't connect to "+address)
+	}
+
+	keyBytes := make([]byte, 16)
+	if _, err = rand.Read(keyBytes); err != nil {
+		conn.Close()
+		return nil, nil, errors.CommonError(err, onDialWebSocket)
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		conn.Close()
+		return nil, nil, errors.CommonError(err, onDialWebSocket)
+	}
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, errors.CommonError(err, onDialWebSocket+": can't write handshake request")
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, errors.CommonError(err, onDialWebSocket+": can't read handshake response")
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		conn.Close()
+		return nil, resp, fmt.Errorf(onDialWebSocket+": wrong handshake response, status = %d", resp.StatusCode)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return &webSocketConn{conn: conn, br: br, isClient: true}, resp, nil
+}
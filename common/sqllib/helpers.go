@@ -1,6 +1,7 @@
 package sqllib
 
 import (
+	"context"
 	"database/sql"
 	"strconv"
 	"strings"
@@ -125,13 +126,30 @@ func Prepare(dbh *sql.DB, sqlQuery string, stmt **sql.Stmt) error {
 	return nil
 }
 
+func PrepareContext(ctx context.Context, dbh *sql.DB, sqlQuery string, stmt **sql.Stmt) error {
+	var err error
+
+	*stmt, err = dbh.PrepareContext(ctx, sqlQuery)
+	if err != nil {
+		return errors.Wrapf(err, "can't dbh.PrepareContext(%s)", sqlQuery)
+	}
+
+	return nil
+}
+
 func Exec(dbh *sql.DB, sqlQuery string, values ...interface{}) (*sql.Result, error) {
-	stmt, err := dbh.Prepare(sqlQuery)
+	return ExecContext(context.Background(), dbh, sqlQuery, values...)
+}
+
+// ExecContext stops the database work when ctx is canceled or its deadline is exceeded
+func ExecContext(ctx context.Context, dbh *sql.DB, sqlQuery string, values ...interface{}) (*sql.Result, error) {
+	stmt, err := dbh.PrepareContext(ctx, sqlQuery)
 	if err != nil {
 		return nil, errors.Wrapf(err, CantPrepare, sqlQuery)
 	}
+	defer stmt.Close()
 
-	res, err := stmt.Exec(values...)
+	res, err := stmt.ExecContext(ctx, values...)
 	if err != nil {
 		return nil, errors.Wrapf(err, CantExec, sqlQuery, values)
 	}
@@ -140,21 +158,26 @@ func Exec(dbh *sql.DB, sqlQuery string, values ...interface{}) (*sql.Result, err
 }
 
 func Query(dbh *sql.DB, sqlQuery string, values ...interface{}) (*sql.Rows, error) {
-	stmt, err := dbh.Prepare(sqlQuery)
-	if err != nil {
-		return nil, errors.Wrapf(err, CantPrepare, sqlQuery)
-	}
+	return QueryContext(context.Background(), dbh, sqlQuery, values...)
+}
 
-	rows, err := stmt.Query(values...)
+// QueryContext stops the database work when ctx is canceled or its deadline is exceeded (the statement isn't prepared
+// explicitly, so nothing stays open besides the returned rows)
+func QueryContext(ctx context.Context, dbh *sql.DB, sqlQuery string, values ...interface{}) (*sql.Rows, error) {
+	rows, err := dbh.QueryContext(ctx, sqlQuery, values...)
 	if err != nil {
-		return nil, errors.Wrapf(err, CantExec, sqlQuery, values)
+		return nil, errors.Wrapf(err, CantQuery, sqlQuery, values)
 	}
 
 	return rows, nil
 }
 
 func QueryStrings(stmt *sql.Stmt, sql string, values ...interface{}) (results []string, err error) {
-	rows, err := stmt.Query(values...)
+	return QueryStringsContext(context.Background(), stmt, sql, values...)
+}
+
+func QueryStringsContext(ctx context.Context, stmt *sql.Stmt, sql string, values ...interface{}) (results []string, err error) {
+	rows, err := stmt.QueryContext(ctx, values...)
 	if err != nil {
 		return nil, errors.Wrapf(err, CantExec, sql, values)
 	}
@@ -1,6 +1,7 @@
 package sqllib_sqlite
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -40,3 +41,12 @@ func TestConnect(t *testing.T) {
 	sqllib.TestDB(t, db)
 
 }
+
+func TestConnectTempDir(t *testing.T) {
+	db, err := Connect(config.Access{Path: filepath.Join(t.TempDir(), "test_connect.sqlite")})
+	require.NoError(t, err)
+	require.NotNil(t, db)
+	defer db.Close()
+
+	sqllib.TestDB(t, db)
+}
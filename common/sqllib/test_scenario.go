@@ -1,6 +1,7 @@
 package sqllib
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 
@@ -106,6 +107,27 @@ func TestDB(t *testing.T, db *sql.DB) {
 	require.NoError(t, err)
 	require.Equal(t, 2, len(items))
 
+	// context -----------------------------------------------------
+
+	rowsContext, err := QueryContext(context.Background(), db, sqlSelect, "a1")
+	require.NoError(t, err)
+	require.True(t, rowsContext.Next())
+	var a string
+	require.NoError(t, rowsContext.Scan(&a))
+	require.Equal(t, "a1", a)
+	require.NoError(t, rowsContext.Close())
+
+	// canceled context --------------------------------------------
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = QueryContext(ctx, db, sqlSelect, "a1")
+	require.Error(t, err)
+
+	_, err = ExecContext(ctx, db, sqlDelete, "a1")
+	require.Error(t, err)
+
 	// count, delete, recount --------------------------------------
 
 	sqlCount3 := SQLCount("test", "a = 'a3'", nil)